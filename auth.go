@@ -0,0 +1,41 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// adminAuth guards the catalog management endpoints with a static bearer token.
+//
+// The token is read from the ADMIN_API_TOKEN environment variable. When it is empty every
+// admin request is rejected, so the write endpoints are closed unless explicitly configured.
+type adminAuth struct {
+	token string
+}
+
+// isAdmin reports whether the request carries a valid "Authorization: Bearer <token>" header.
+// The scheme is case-insensitive, and tokens sent without it are rejected.
+func (a adminAuth) isAdmin(r *http.Request) bool {
+	if a.token == "" {
+		return false
+	}
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) == 1
+}
+
+// require wraps an HTTP handler so it is only called for admin requests.
+// Any other request gets an HTTP 401 Unauthorized error.
+func (a adminAuth) require(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !a.isAdmin(r) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAdminAuthRequire(t *testing.T) {
+	tests := []struct {
+		name           string
+		token          string
+		header         string
+		expectedStatus int
+	}{
+		{name: "Valid token", token: "secret", header: "Bearer secret", expectedStatus: http.StatusNoContent},
+		{name: "Lowercase scheme", token: "secret", header: "bearer secret", expectedStatus: http.StatusNoContent},
+		{name: "Wrong token", token: "secret", header: "Bearer nope", expectedStatus: http.StatusUnauthorized},
+		{name: "Missing scheme", token: "secret", header: "secret", expectedStatus: http.StatusUnauthorized},
+		{name: "Other scheme", token: "secret", header: "Basic secret", expectedStatus: http.StatusUnauthorized},
+		{name: "Missing header", token: "secret", header: "", expectedStatus: http.StatusUnauthorized},
+		{name: "No token configured", token: "", header: "Bearer ", expectedStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth := adminAuth{token: tt.token}
+			handler := auth.require(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/products", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rr := httptest.NewRecorder()
+			handler(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, tt.expectedStatus)
+			}
+		})
+	}
+}
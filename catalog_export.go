@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Formats the catalog can be exported in. exportExcel is a CSV file that spreadsheet applications open
+// without mangling it (see newCSVProductWriter).
+const (
+	exportCSV   = "csv"
+	exportJSONL = "jsonl"
+	exportExcel = "excel"
+)
+
+// exportColumns are the columns of a CSV catalog export. The columns that are also in importColumns are written
+// like the import reads them, so a CSV export can be imported back; the others are ignored by the import.
+var exportColumns = []string{"id", "slug", "name", "price", "currency", "description", "categories", "images", "referenced_name", "status",
+	"publish_at", "unpublish_at", "date_added", "average_rating", "review_count", "available_quantity"}
+
+// exportContentTypes has the Content-Type of every export format.
+var exportContentTypes = map[string]string{
+	exportCSV:   "text/csv; charset=utf-8",
+	exportJSONL: "application/x-ndjson",
+	exportExcel: "text/csv; charset=utf-8",
+}
+
+// exportExtensions has the file extension of every export format.
+var exportExtensions = map[string]string{
+	exportCSV:   "csv",
+	exportJSONL: "jsonl",
+	exportExcel: "csv",
+}
+
+// parseExportFormat parses the format query parameter of a catalog export, csv by default.
+func parseExportFormat(format string) (string, error) {
+	switch strings.ToLower(format) {
+	case "", exportCSV:
+		return exportCSV, nil
+	case exportJSONL, "ndjson":
+		return exportJSONL, nil
+	case exportExcel:
+		return exportExcel, nil
+	}
+	return "", validationError{"format must be csv, jsonl or excel"}
+}
+
+// productWriter writes the products of a catalog export one at a time, so the catalog is never fully in memory.
+type productWriter interface {
+	write(p Product) error
+	// flush writes any buffered data, and must be called after the last product.
+	flush() error
+}
+
+// newProductWriter returns the productWriter of the given format.
+func newProductWriter(w io.Writer, format string) (productWriter, error) {
+	if format == exportJSONL {
+		return jsonlProductWriter{enc: json.NewEncoder(w)}, nil
+	}
+	return newCSVProductWriter(w, format == exportExcel)
+}
+
+// jsonlProductWriter writes every product as a line with its JSON object, as returned by the API.
+type jsonlProductWriter struct {
+	enc *json.Encoder
+}
+
+func (pw jsonlProductWriter) write(p Product) error {
+	return pw.enc.Encode(p)
+}
+
+func (pw jsonlProductWriter) flush() error {
+	return nil
+}
+
+// csvProductWriter writes every product as a row of exportColumns. Lists are joined with importListSeparator,
+// prices are decimal amounts in the currency column and dates are RFC 3339 timestamps, empty when not set.
+type csvProductWriter struct {
+	w *csv.Writer
+	// excel makes values that start like a formula be read as text, by prefixing them with a single quote.
+	excel bool
+}
+
+// newCSVProductWriter returns a csvProductWriter that has written the header. For spreadsheets, the file starts with
+// a UTF-8 byte order mark, so accents are read right, and lines end with CRLF.
+func newCSVProductWriter(w io.Writer, excel bool) (csvProductWriter, error) {
+	if excel {
+		if _, err := io.WriteString(w, "\ufeff"); err != nil {
+			return csvProductWriter{}, err
+		}
+	}
+	pw := csvProductWriter{w: csv.NewWriter(w), excel: excel}
+	pw.w.UseCRLF = excel
+	return pw, pw.w.Write(exportColumns)
+}
+
+func (pw csvProductWriter) write(p Product) error {
+	record := []string{
+		strconv.Itoa(p.ID),
+		p.Slug,
+		p.Name,
+		p.Price.String(),
+		p.Price.Currency,
+		p.Description,
+		strings.Join(p.Categories, importListSeparator),
+		strings.Join(p.Images, importListSeparator),
+		p.ReferencedName,
+		p.Status,
+		formatExportTime(p.PublishAt),
+		formatExportTime(p.UnpublishAt),
+		formatExportTime(&p.DateAdded),
+		strconv.FormatFloat(p.AverageRating, 'f', -1, 64),
+		strconv.Itoa(p.ReviewCount),
+		strconv.Itoa(p.AvailableQuantity),
+	}
+	if pw.excel {
+		for i, v := range record {
+			record[i] = escapeFormula(v)
+		}
+	}
+	return pw.w.Write(record)
+}
+
+func (pw csvProductWriter) flush() error {
+	pw.w.Flush()
+	return pw.w.Error()
+}
+
+// formatExportTime formats an optional date of a CSV export.
+func formatExportTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// escapeFormula prefixes the values that spreadsheets would evaluate as a formula with a single quote.
+func escapeFormula(v string) string {
+	if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+		return "'" + v
+	}
+	return v
+}
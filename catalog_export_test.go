@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// exportTestProduct returns a product with every field of a CSV export set.
+func exportTestProduct() Product {
+	publishAt := time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC)
+	return Product{ID: 3, Name: "Taza \"Azul\"", Slug: "taza-azul", Price: cop(3500000), Description: "Taza, esmaltada", Categories: []string{"mugs", "tableware"},
+		Images: []string{"a.jpg", "b.jpg"}, ReferencedName: "-Serie Mar", DateAdded: time.Date(2023, 6, 1, 12, 0, 0, 0, time.FixedZone("", -5*60*60)),
+		Status: statusPublished, PublishAt: &publishAt, AverageRating: 4.5, ReviewCount: 2, AvailableQuantity: 7}
+}
+
+func TestCSVProductWriter(t *testing.T) {
+	var buf bytes.Buffer
+	pw, err := newProductWriter(&buf, exportCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []Product{exportTestProduct(), {ID: 4, Name: "Plato", Price: cop(100), Categories: []string{}, Images: []string{}, Status: statusDraft}} {
+		if err := pw.write(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := pw.flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "id,slug,name,price,currency,description,categories,images,referenced_name,status,publish_at,unpublish_at,date_added,average_rating,review_count,available_quantity\n" +
+		`3,taza-azul,"Taza ""Azul""",35000.00,COP,"Taza, esmaltada",mugs|tableware,a.jpg|b.jpg,-Serie Mar,published,2023-07-01T00:00:00Z,,2023-06-01T17:00:00Z,4.5,2,7` + "\n" +
+		"4,,Plato,1.00,COP,,,,,draft,,,0001-01-01T00:00:00Z,0,0,0\n"
+	if buf.String() != expected {
+		t.Errorf("unexpected file:\n got %s\nwant %s", buf.String(), expected)
+	}
+}
+
+func TestCSVProductWriter_Excel(t *testing.T) {
+	var buf bytes.Buffer
+	pw, err := newProductWriter(&buf, exportExcel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pw.write(exportTestProduct()); err != nil {
+		t.Fatal(err)
+	}
+	if err := pw.flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The file starts with a byte order mark, lines end with CRLF and the referenced name is not read as a formula
+	if !strings.HasPrefix(buf.String(), "\ufeffid,slug,") {
+		t.Errorf("missing byte order mark: %q", buf.String())
+	}
+	if !strings.Contains(buf.String(), "available_quantity\r\n3,taza-azul,") || !strings.Contains(buf.String(), ",'-Serie Mar,") {
+		t.Errorf("unexpected file: %q", buf.String())
+	}
+}
+
+func TestJSONLProductWriter(t *testing.T) {
+	var buf bytes.Buffer
+	pw, err := newProductWriter(&buf, exportJSONL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := exportTestProduct()
+	if err := pw.write(p); err != nil {
+		t.Fatal(err)
+	}
+	if err := pw.flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected, _ := marshalLine(p)
+	if buf.String() != expected {
+		t.Errorf("unexpected file:\n got %s\nwant %s", buf.String(), expected)
+	}
+}
+
+func TestCSVExportCanBeImported(t *testing.T) {
+	var buf bytes.Buffer
+	pw, err := newProductWriter(&buf, exportCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := exportTestProduct()
+	if err := pw.write(p); err != nil {
+		t.Fatal(err)
+	}
+	if err := pw.flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, rowErrors, err := parseImportFile(&buf, importCSV)
+	if err != nil || len(rowErrors) != 0 {
+		t.Fatalf("unexpected errors: %v %v", err, rowErrors)
+	}
+	expected := []importRow{{Line: 2, productInput: productInput{Name: p.Name, Slug: p.Slug, Price: p.Price, Description: p.Description, Categories: p.Categories,
+		Images: p.Images, ReferencedName: p.ReferencedName, Status: p.Status, PublishAt: p.PublishAt}}}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Errorf("unexpected rows:\n got %+v\nwant %+v", rows, expected)
+	}
+}
+
+func TestParseExportFormat(t *testing.T) {
+	tests := []struct {
+		format   string
+		expected string
+	}{
+		{format: "", expected: exportCSV},
+		{format: "CSV", expected: exportCSV},
+		{format: "jsonl", expected: exportJSONL},
+		{format: "excel", expected: exportExcel},
+		{format: "xlsx", expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			format, err := parseExportFormat(tt.format)
+			if format != tt.expected {
+				t.Errorf("unexpected format: got %q want %q", format, tt.expected)
+			}
+			if tt.expected == "" && (err == nil || err.Error() != "format must be csv, jsonl or excel") {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,748 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// maxImportSize is the maximum size of a catalog file.
+const maxImportSize = 10 << 20
+
+// Formats of the catalog files that can be imported.
+const (
+	importCSV   = "csv"
+	importJSONL = "jsonl"
+)
+
+// importListSeparator separates the values of the list columns of a CSV catalog file, e.g. "mugs|tableware".
+const importListSeparator = "|"
+
+// importColumns are the columns a CSV catalog file can have. Every row is a product, identified by its slug,
+// and optionally one of its variants, identified by its sku.
+var importColumns = append(append([]string{"slug"}, importProductColumns...), "sku", "options", "variant_price", "stock")
+
+// importProductColumns are the columns of a product that an import sets. The ones that are not in a file keep
+// their values in existing products.
+var importProductColumns = []string{"name", "price", "description", "categories", "images", "referenced_name", "status", "publish_at", "unpublish_at"}
+
+// missingImportColumns returns the product columns for which has is false, or nil if there are none.
+func missingImportColumns(has func(column string) bool) map[string]bool {
+	var missing map[string]bool
+	for _, c := range importProductColumns {
+		if !has(c) {
+			if missing == nil {
+				missing = map[string]bool{}
+			}
+			missing[c] = true
+		}
+	}
+	return missing
+}
+
+// importRow is a row of a catalog file: a product and, when the row has a SKU, one of its variants.
+// In JSON Lines files every line is an object with these fields.
+type importRow struct {
+	// Line is the line of the file the row starts at.
+	Line int `json:"-"`
+	productInput
+	SKU          string            `json:"sku"`
+	Options      map[string]string `json:"options"`
+	VariantPrice *Money            `json:"variant_price"`
+	Stock        int               `json:"stock"`
+	// slugGenerated is true when the row has no slug and got the one of its name.
+	slugGenerated bool
+	// missing are the product columns that are not in the file (see importProductColumns).
+	missing map[string]bool
+}
+
+// has reports whether the file has a product column for the row.
+func (row importRow) has(column string) bool {
+	return !row.missing[column]
+}
+
+// hasVariant reports whether the row also has a variant of the product.
+func (row importRow) hasVariant() bool {
+	return row.SKU != ""
+}
+
+// variant returns the variant of the row.
+func (row importRow) variant() variantInput {
+	return variantInput{SKU: row.SKU, Options: row.Options, Price: row.VariantPrice, Images: []string{}, Stock: row.Stock}
+}
+
+// validate checks every field of the row, like productInput.validate but only the name and price of the file.
+// Rows without a slug need a name, or a SKU to find their product, and get the slug of their name. The status is
+// left empty when it's not set, so existing products keep theirs.
+func (row *importRow) validate() error {
+	if row.has("name") || (row.Slug == "" && !row.hasVariant()) {
+		if err := validateName(row.Name); err != nil {
+			return err
+		}
+	}
+	if row.has("price") {
+		if err := validatePrice(row.Price); err != nil {
+			return err
+		}
+	}
+	if err := validateCategories(row.Categories); err != nil {
+		return err
+	}
+	if err := validateImages(row.Images); err != nil {
+		return err
+	}
+	if row.Slug != "" {
+		if err := validateSlug(row.Slug); err != nil {
+			return err
+		}
+	}
+	if row.Status != "" {
+		if err := validateStatus(row.Status); err != nil {
+			return err
+		}
+	}
+	if err := validatePublishWindow(row.PublishAt, row.UnpublishAt); err != nil {
+		return err
+	}
+	if row.hasVariant() {
+		if err := row.variant().validate(); err != nil {
+			return err
+		}
+	} else if len(row.Options) > 0 || row.VariantPrice != nil || row.Stock != 0 {
+		return validationError{"options, variant_price and stock require a sku"}
+	}
+	if row.Slug == "" && row.Name != "" {
+		row.Slug = slugify(row.Name)
+		row.slugGenerated = true
+	}
+	if row.Categories == nil {
+		row.Categories = []string{}
+	}
+	if row.Images == nil {
+		row.Images = []string{}
+	}
+	if row.Options == nil {
+		row.Options = map[string]string{}
+	}
+	return nil
+}
+
+// importRowError is the error of a row of a catalog file.
+type importRowError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// importCounts is the number of products or variants that were created and updated by an import.
+type importCounts struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+}
+
+// importReport is the result of importing a catalog file.
+type importReport struct {
+	// DryRun is true when the import was rolled back after checking every row.
+	DryRun bool `json:"dry_run"`
+	Rows   int  `json:"rows"`
+	// Products and Variants count every product and variant once, even if it is in several rows.
+	Products importCounts `json:"products"`
+	Variants importCounts `json:"variants"`
+	// Errors has the errors of every invalid row. Nothing is imported when there are errors.
+	Errors []importRowError `json:"errors"`
+	// productIDs are the IDs of the imported products.
+	productIDs []int
+}
+
+// failed reports whether any row of the import had an error.
+func (rep importReport) failed() bool {
+	return len(rep.Errors) > 0
+}
+
+// parseImportFormat returns the format of a catalog file from a format name or a media type, such as
+// "csv", "text/csv", "jsonl" or "application/x-ndjson". It returns a validationError for other formats.
+func parseImportFormat(format string) (string, error) {
+	mediaType, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(format)), ";")
+	switch strings.TrimSpace(mediaType) {
+	case "csv", "text/csv":
+		return importCSV, nil
+	case "jsonl", "ndjson", "application/jsonl", "application/x-ndjson", "application/x-jsonlines":
+		return importJSONL, nil
+	}
+	return "", validationError{"format must be csv or jsonl"}
+}
+
+// parseImportFile reads the rows of a catalog file in the given format.
+// Rows that can't be parsed are returned as row errors, and the rest of the file is still read.
+// It only returns an error if the file can't be read.
+func parseImportFile(r io.Reader, format string) ([]importRow, []importRowError, error) {
+	if format == importCSV {
+		return parseImportCSV(r)
+	}
+	return parseImportJSONL(r)
+}
+
+// parseImportCSV reads the rows of a CSV catalog file. The first line is the header, with the names of the
+// columns (see importColumns) in any order, and the columns of a catalog export that are not imported are ignored
+// (see exportColumns). List columns separate their values with importListSeparator,
+// and options are written as name=value pairs, e.g. "glaze=celadon|size=large".
+func parseImportCSV(r io.Reader) ([]importRow, []importRowError, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, []importRowError{{Line: 1, Error: "the file is empty"}}, nil
+	} else if err != nil {
+		return nil, nil, err
+	}
+	// The columns of a catalog export that can't be imported are skipped
+	columns := map[string]int{}
+	known := map[string]bool{}
+	for _, c := range exportColumns {
+		known[c] = false
+	}
+	for _, c := range importColumns {
+		known[c] = true
+	}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		imported, ok := known[name]
+		if !ok {
+			return nil, []importRowError{{Line: 1, Error: fmt.Sprintf("unknown column %q", name)}}, nil
+		}
+		if imported {
+			columns[name] = i
+		}
+	}
+	missing := missingImportColumns(func(column string) bool {
+		_, ok := columns[column]
+		return ok
+	})
+
+	rows := []importRow{}
+	rowErrors := []importRowError{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			rowErrors = append(rowErrors, importRowError{Line: parseErr.StartLine, Error: parseErr.Err.Error()})
+			continue
+		} else if err != nil {
+			return nil, nil, err
+		}
+		line, _ := reader.FieldPos(0)
+
+		row, err := parseImportRecord(record, columns)
+		row.Line = line
+		row.missing = missing
+		if err != nil {
+			rowErrors = append(rowErrors, importRowError{Line: line, Error: err.Error()})
+			continue
+		}
+		rows = append(rows, row)
+	}
+	return rows, rowErrors, nil
+}
+
+// parseImportRecord converts a record of a CSV catalog file to a row. columns has the index of every column.
+func parseImportRecord(record []string, columns map[string]int) (importRow, error) {
+	row := importRow{}
+	get := func(name string) string {
+		if i, ok := columns[name]; ok {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	row.Slug = get("slug")
+	row.Name = get("name")
+	row.Description = get("description")
+	row.ReferencedName = get("referenced_name")
+	row.Status = get("status")
+	row.Categories = splitImportList(get("categories"))
+	row.Images = splitImportList(get("images"))
+	row.SKU = get("sku")
+
+	if s := get("price"); s != "" {
+		price, err := parseMoney(s, storeCurrency)
+		if err != nil {
+			return row, validationError{fmt.Sprintf("price must be an amount with at most %d decimals", priceScale)}
+		}
+		row.Price = price
+	}
+	if s := get("variant_price"); s != "" {
+		price, err := parseMoney(s, storeCurrency)
+		if err != nil {
+			return row, validationError{fmt.Sprintf("variant_price must be an amount with at most %d decimals", priceScale)}
+		}
+		row.VariantPrice = &price
+	}
+	for _, t := range []struct {
+		name string
+		dest **time.Time
+	}{{"publish_at", &row.PublishAt}, {"unpublish_at", &row.UnpublishAt}} {
+		if s := get(t.name); s != "" {
+			parsed, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				return row, validationError{t.name + " must be an RFC 3339 timestamp"}
+			}
+			*t.dest = &parsed
+		}
+	}
+	if s := get("stock"); s != "" {
+		stock, err := strconv.Atoi(s)
+		if err != nil {
+			return row, validationError{"stock must be a whole number"}
+		}
+		row.Stock = stock
+	}
+	if s := get("options"); s != "" {
+		row.Options = map[string]string{}
+		for _, option := range splitImportList(s) {
+			name, value, ok := strings.Cut(option, "=")
+			if !ok {
+				return row, validationError{"options must be name=value pairs"}
+			}
+			row.Options[strings.TrimSpace(name)] = strings.TrimSpace(value)
+		}
+	}
+	return row, nil
+}
+
+// splitImportList splits the values of a list column, dropping the spaces around them.
+func splitImportList(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	values := strings.Split(s, importListSeparator)
+	for i, v := range values {
+		values[i] = strings.TrimSpace(v)
+	}
+	return values
+}
+
+// parseImportJSONL reads the rows of a JSON Lines catalog file, where every non-blank line is a JSON object
+// with the fields of importRow. Lists are JSON arrays, options an object and prices are written like in the API.
+// The product fields that an object doesn't have are missing columns of its row.
+func parseImportJSONL(r io.Reader) ([]importRow, []importRowError, error) {
+	// Lines can be as long as the whole file, so that a file that is too big fails reading it and not scanning a line
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxImportSize+1)
+
+	rows := []importRow{}
+	rowErrors := []importRowError{}
+	line := 0
+	for scanner.Scan() {
+		line++
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		row := importRow{Line: line}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err := dec.Decode(&row)
+		if err == nil && dec.More() {
+			err = errors.New("unexpected data after JSON object")
+		}
+		var fields map[string]json.RawMessage
+		if err == nil {
+			err = json.Unmarshal(data, &fields)
+		}
+		if err != nil {
+			rowErrors = append(rowErrors, importRowError{Line: line, Error: "invalid JSON: " + err.Error()})
+			continue
+		}
+		// Fields match case-insensitively, like when decoding the row
+		names := map[string]bool{}
+		for name := range fields {
+			names[strings.ToLower(name)] = true
+		}
+		row.missing = missingImportColumns(func(column string) bool { return names[column] })
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to read line %d: %w", line+1, err)
+	}
+	return rows, rowErrors, nil
+}
+
+// validateImportRows validates every row, normalizing the valid ones, and returns the errors of the invalid ones.
+// Besides the checks of the API, the categories of every row must exist, a SKU can't be in several rows and the
+// products of the rows are found by their SKUs and slugs (see resolveImportProducts).
+func validateImportRows(db *sql.DB, rows []importRow) ([]importRowError, error) {
+	rowErrors := []importRowError{}
+	valid := make([]bool, len(rows))
+	skuLines := map[string]int{}
+	slugs := []string{}
+	for i := range rows {
+		row := &rows[i]
+		if err := row.validate(); err != nil {
+			rowErrors = append(rowErrors, importRowError{Line: row.Line, Error: err.Error()})
+			continue
+		}
+		if row.hasVariant() {
+			if line, ok := skuLines[row.SKU]; ok {
+				rowErrors = append(rowErrors, importRowError{Line: row.Line, Error: fmt.Sprintf("sku %q is repeated, it is also in line %d", row.SKU, line)})
+				continue
+			}
+			skuLines[row.SKU] = row.Line
+		}
+		valid[i] = true
+		slugs = append(slugs, row.Categories...)
+	}
+
+	// Check the categories of every valid row with a single query
+	if len(slugs) > 0 {
+		dbRows, err := db.Query("SELECT slug FROM categories WHERE slug = ANY($1)", textArray(slugs))
+		if err != nil {
+			return nil, err
+		}
+		defer dbRows.Close()
+		found := map[string]bool{}
+		for dbRows.Next() {
+			var slug string
+			if err := dbRows.Scan(&slug); err != nil {
+				return nil, err
+			}
+			found[slug] = true
+		}
+		if err := dbRows.Err(); err != nil {
+			return nil, err
+		}
+		for i, row := range rows {
+			if !valid[i] {
+				continue
+			}
+			if err := unknownCategoriesError(row.Categories, func(slug string) bool { return found[slug] }); err != nil {
+				rowErrors = append(rowErrors, importRowError{Line: row.Line, Error: err.Error()})
+				valid[i] = false
+			}
+		}
+	}
+
+	productErrors, err := resolveImportProducts(db, rows, valid)
+	if err != nil {
+		return nil, err
+	}
+	return append(rowErrors, productErrors...), nil
+}
+
+// importSKUsQuery finds the variants with any of the SKUs, with the slug of their product.
+const importSKUsQuery = "SELECT product_variants.sku, products.slug FROM product_variants JOIN products ON products.id = product_variants.product_id " +
+	"WHERE product_variants.sku = ANY($1)"
+
+// importSlugsQuery finds the products that have any of the slugs now or had them before, with their current slug.
+const importSlugsQuery = "SELECT slug, slug FROM products WHERE slug = ANY($1) " +
+	"UNION ALL SELECT product_slug_history.slug, products.slug FROM product_slug_history JOIN products ON products.id = product_slug_history.product_id " +
+	"WHERE product_slug_history.slug = ANY($1)"
+
+// queryImportSlugs runs a query of importSKUsQuery or importSlugsQuery with the given values, and maps the first
+// column of the results to the slug in the second one.
+func queryImportSlugs(db *sql.DB, query string, values textArray) (map[string]string, error) {
+	slugs := map[string]string{}
+	if len(values) == 0 {
+		return slugs, nil
+	}
+	dbRows, err := db.Query(query, values)
+	if err != nil {
+		return nil, err
+	}
+	defer dbRows.Close()
+	for dbRows.Next() {
+		var value, slug string
+		if err := dbRows.Scan(&value, &slug); err != nil {
+			return nil, err
+		}
+		slugs[value] = slug
+	}
+	return slugs, dbRows.Err()
+}
+
+// resolveImportProducts finds the products of the valid rows, first by their SKUs and then by their slugs, with
+// a query each, and returns the errors of the rows whose product can't be used.
+//
+// Rows with the SKU of an existing variant update its product, so they can only set a slug that product has or
+// had. Other rows with an old slug of a product get its current slug, so they update that product. A slug generated
+// from the name of a row is meant for a new product: unless a row of the file names it explicitly, it can't be the
+// slug of another product, now or in the past, nor be generated from a different name in another row. Rows of new
+// products need a name and a price.
+func resolveImportProducts(db *sql.DB, rows []importRow, valid []bool) ([]importRowError, error) {
+	skus := textArray{}
+	slugs := textArray{}
+	seen := map[string]bool{}
+	for i, row := range rows {
+		if !valid[i] {
+			continue
+		}
+		if row.hasVariant() {
+			skus = append(skus, row.SKU)
+		}
+		if row.Slug != "" && !seen[row.Slug] {
+			seen[row.Slug] = true
+			slugs = append(slugs, row.Slug)
+		}
+	}
+	skuSlugs, err := queryImportSlugs(db, importSKUsQuery, skus)
+	if err != nil {
+		return nil, err
+	}
+	current, err := queryImportSlugs(db, importSlugsQuery, slugs)
+	if err != nil {
+		return nil, err
+	}
+	existing := map[string]bool{}
+	for _, slug := range current {
+		existing[slug] = true
+	}
+	for _, slug := range skuSlugs {
+		existing[slug] = true
+	}
+
+	rowErrors := []importRowError{}
+	failed := make([]bool, len(rows))
+	fail := func(i int, err string) {
+		rowErrors = append(rowErrors, importRowError{Line: rows[i].Line, Error: err})
+		failed[i] = true
+	}
+
+	// Rows with the SKU of a variant update its product, and name it for the other rows
+	named := map[string]bool{}
+	for i := range rows {
+		row := &rows[i]
+		slug, ok := skuSlugs[row.SKU]
+		if !valid[i] || !row.hasVariant() || !ok {
+			continue
+		}
+		if row.Slug != "" && !row.slugGenerated && row.Slug != slug && current[row.Slug] != slug {
+			fail(i, fmt.Sprintf("sku %q belongs to the product with slug %q", row.SKU, slug))
+			continue
+		}
+		row.Slug = slug
+		row.slugGenerated = false
+		named[slug] = true
+	}
+
+	// Rows with a slug update the product that has or had it
+	for i := range rows {
+		row := &rows[i]
+		if !valid[i] || failed[i] || row.Slug == "" || row.slugGenerated {
+			continue
+		}
+		named[row.Slug] = true
+		if slug, ok := current[row.Slug]; ok {
+			row.Slug = slug
+			named[slug] = true
+		}
+	}
+
+	generated := map[string]importRow{}
+	for i := range rows {
+		row := &rows[i]
+		if !valid[i] || !row.slugGenerated {
+			continue
+		}
+		if named[row.Slug] {
+			// The file names the product, which may have another slug now
+			if slug, ok := current[row.Slug]; ok {
+				row.Slug = slug
+			}
+			continue
+		}
+		if _, ok := current[row.Slug]; ok {
+			fail(i, fmt.Sprintf("slug %q generated from the name belongs to another product, set a slug", row.Slug))
+		} else if first, ok := generated[row.Slug]; ok && first.Name != row.Name {
+			fail(i, fmt.Sprintf("slug %q generated from the name is also generated from another name in line %d, set a slug", row.Slug, first.Line))
+		} else if !ok {
+			generated[row.Slug] = *row
+		}
+	}
+
+	for i, row := range rows {
+		if !valid[i] || failed[i] {
+			continue
+		}
+		if row.Slug == "" {
+			fail(i, fmt.Sprintf("sku %q is new, set the slug or the name of its product", row.SKU))
+		} else if !existing[row.Slug] && (!row.has("name") || !row.has("price")) {
+			fail(i, fmt.Sprintf("product %q is new, it needs a name and a price", row.Slug))
+		}
+	}
+	return rowErrors, nil
+}
+
+// importProductQuery returns the query that inserts the product of a row, or updates the product with its slug,
+// and returns its ID and whether it was inserted. New products are published unless the row has a status.
+// Existing products only get the columns of the file, and an empty status keeps theirs. The date added of existing
+// products is kept.
+func importProductQuery(row importRow) string {
+	sets := []string{}
+	for _, c := range importProductColumns {
+		if row.has(c) && (c != "status" || row.Status != "") {
+			sets = append(sets, c+" = EXCLUDED."+c)
+		}
+	}
+	// The product is still returned when the file has none of its columns
+	if len(sets) == 0 {
+		sets = append(sets, "slug = EXCLUDED.slug")
+	}
+	return "INSERT INTO products (name, slug, price, description, categories, images, referenced_name, status, publish_at, unpublish_at, date_added) " +
+		"VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NOW()) " +
+		"ON CONFLICT (slug) DO UPDATE SET " + strings.Join(sets, ", ") + " RETURNING id, xmax = 0"
+}
+
+// importStatus returns the status a row inserts a product with.
+func importStatus(row importRow) string {
+	if row.Status == "" {
+		return statusPublished
+	}
+	return row.Status
+}
+
+// importVariantStockQuery locks the variant with a SKU, if there is one, and returns its product and its stock.
+const importVariantStockQuery = "SELECT product_id, stock FROM product_variants WHERE sku = $1 FOR UPDATE"
+
+// importVariantQuery inserts the variant of a row, or updates the variant with its SKU if it belongs to the
+// same product, and returns its ID and whether it was inserted. The images of existing variants are kept.
+const importVariantQuery = "INSERT INTO product_variants (product_id, sku, options, price, images, stock) VALUES ($1, $2, $3, $4, $5, $6) " +
+	"ON CONFLICT (sku) DO UPDATE SET options = EXCLUDED.options, price = EXCLUDED.price, stock = EXCLUDED.stock " +
+	"WHERE product_variants.product_id = EXCLUDED.product_id RETURNING id, xmax = 0"
+
+// importAdjustmentQuery records the units a variant got from an import as received.
+const importAdjustmentQuery = "INSERT INTO inventory_adjustments (product_id, variant_id, reason, quantity, note) VALUES ($1, $2, 'received', $3, $4)"
+
+// importAdjustmentNote is the note of the inventory adjustments recorded by imports.
+const importAdjustmentNote = "catalog import"
+
+// importCatalog upserts the products and variants of the rows, which must be valid, in a single transaction.
+//
+// Products are matched by the slugs that validateImportRows resolved and variants by SKU, and existing products
+// only get the columns of the file (see importProductQuery). The stock of a variant can only go up, and the units it
+// gets are recorded as a received inventory adjustment, so the stock keeps matching its adjustments. If a row fails,
+// for example because its SKU moved to another product since it was validated, the error is reported and nothing
+// is imported. With dryRun the transaction is always rolled back, so the report tells what the import would do. The
+// changes are recorded in the history of the products as made by actor.
+func importCatalog(ctx context.Context, db *sql.DB, actor string, rows []importRow, dryRun bool) (importReport, error) {
+	report := importReport{DryRun: dryRun, Rows: len(rows), Errors: []importRowError{}}
+
+	tx, err := beginAsActor(ctx, db, actor)
+	if err != nil {
+		return report, err
+	}
+	// Rolling back after a commit does nothing
+	defer tx.Rollback()
+
+	products := map[int]bool{}
+	for _, row := range rows {
+		var id int
+		var inserted bool
+		err := tx.QueryRowContext(ctx, importProductQuery(row), row.Name, row.Slug, row.Price, row.Description, textArray(row.Categories), textArray(row.Images),
+			row.ReferencedName, importStatus(row), row.PublishAt, row.UnpublishAt).Scan(&id, &inserted)
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) {
+			report.Errors = append(report.Errors, importRowError{Line: row.Line, Error: pqErr.Message})
+			return report, nil
+		} else if err != nil {
+			return report, err
+		}
+		if !products[id] {
+			products[id] = true
+			report.productIDs = append(report.productIDs, id)
+			if inserted {
+				report.Products.Created++
+			} else {
+				report.Products.Updated++
+			}
+		}
+
+		if !row.hasVariant() {
+			continue
+		}
+		options, err := json.Marshal(row.Options)
+		if err != nil {
+			return report, err
+		}
+		var owner, previousStock int
+		err = tx.QueryRowContext(ctx, importVariantStockQuery, row.SKU).Scan(&owner, &previousStock)
+		if err == sql.ErrNoRows {
+			owner = id
+		} else if err != nil {
+			return report, err
+		}
+		if owner != id {
+			report.Errors = append(report.Errors, importRowError{Line: row.Line, Error: fmt.Sprintf("sku %q belongs to another product", row.SKU)})
+			return report, nil
+		}
+		if row.Stock < previousStock {
+			report.Errors = append(report.Errors, importRowError{Line: row.Line,
+				Error: fmt.Sprintf("stock of sku %q can't go down from %d, record the units sold or broken as inventory adjustments", row.SKU, previousStock)})
+			return report, nil
+		}
+
+		var variantID int
+		err = tx.QueryRowContext(ctx, importVariantQuery, id, row.SKU, options, row.VariantPrice, textArray{}, row.Stock).Scan(&variantID, &inserted)
+		if err == sql.ErrNoRows {
+			report.Errors = append(report.Errors, importRowError{Line: row.Line, Error: fmt.Sprintf("sku %q belongs to another product", row.SKU)})
+			return report, nil
+		} else if errors.As(err, &pqErr) {
+			report.Errors = append(report.Errors, importRowError{Line: row.Line, Error: pqErr.Message})
+			return report, nil
+		} else if err != nil {
+			return report, err
+		}
+		if inserted {
+			report.Variants.Created++
+		} else {
+			report.Variants.Updated++
+		}
+
+		if received := row.Stock - previousStock; received > 0 {
+			_, err = tx.ExecContext(ctx, importAdjustmentQuery, id, variantID, received, importAdjustmentNote)
+			if err != nil {
+				return report, err
+			}
+		}
+	}
+
+	if dryRun {
+		return report, nil
+	}
+	return report, tx.Commit()
+}
+
+// runImport parses, validates and imports a catalog file in the given format (see parseImportFormat).
+// It is shared by the import endpoint and the import command. When any row is invalid nothing is imported,
+// and the report has the errors of every invalid row, sorted by line.
+// It only returns an error if the file can't be read or the database fails.
+func runImport(ctx context.Context, db *sql.DB, actor string, r io.Reader, format string, dryRun bool) (importReport, error) {
+	rows, rowErrors, err := parseImportFile(r, format)
+	if err != nil {
+		return importReport{}, err
+	}
+	validationErrors, err := validateImportRows(db, rows)
+	if err != nil {
+		return importReport{}, err
+	}
+	if len(rowErrors) > 0 || len(validationErrors) > 0 {
+		report := importReport{DryRun: dryRun, Rows: len(rows) + len(rowErrors), Errors: append(rowErrors, validationErrors...)}
+		sort.SliceStable(report.Errors, func(i, j int) bool { return report.Errors[i].Line < report.Errors[j].Line })
+		return report, nil
+	}
+	return importCatalog(ctx, db, actor, rows, dryRun)
+}
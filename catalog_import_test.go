@@ -0,0 +1,484 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+const importTestCSV = `slug,name,price,categories,images,sku,options,variant_price,stock,publish_at
+,Taza Azul,35000,mugs|tableware,taza.jpg,TAZA-AZUL-L,glaze=celadon|size=large,38000.50,4,2023-07-01T00:00:00Z
+plato-hondo,Plato Hondo,"42000.00",,,,,,,
+`
+
+func TestParseImportCSV(t *testing.T) {
+	rows, rowErrors, err := parseImportFile(strings.NewReader(importTestCSV), importCSV)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(rowErrors) != 0 {
+		t.Fatalf("unexpected row errors: %v", rowErrors)
+	}
+
+	publishAt := time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC)
+	variantPrice := cop(3800050)
+	// The product columns that are not in the header are missing in every row
+	missing := map[string]bool{"description": true, "referenced_name": true, "status": true, "unpublish_at": true}
+	expected := []importRow{
+		{Line: 2, productInput: productInput{Name: "Taza Azul", Price: cop(3500000), Categories: []string{"mugs", "tableware"}, Images: []string{"taza.jpg"}, PublishAt: &publishAt},
+			SKU: "TAZA-AZUL-L", Options: map[string]string{"glaze": "celadon", "size": "large"}, VariantPrice: &variantPrice, Stock: 4, missing: missing},
+		{Line: 3, productInput: productInput{Slug: "plato-hondo", Name: "Plato Hondo", Price: cop(4200000), Categories: []string{}, Images: []string{}}, missing: missing},
+	}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Errorf("unexpected rows:\n got %+v\nwant %+v", rows, expected)
+	}
+}
+
+func TestParseImportCSV_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		expected []importRowError
+	}{
+		{name: "Empty file", file: "", expected: []importRowError{{Line: 1, Error: "the file is empty"}}},
+		{name: "Unknown column", file: "name,price,color\n", expected: []importRowError{{Line: 1, Error: `unknown column "color"`}}},
+		{
+			name: "Invalid rows",
+			file: "name,price,stock,options,publish_at\nMug,cheap,,,\nMug,1,many,,\nMug,1,,glaze,\nMug,1,,,tomorrow\nMug,1\nMug,1,,,\n",
+			expected: []importRowError{
+				{Line: 2, Error: "price must be an amount with at most 2 decimals"},
+				{Line: 3, Error: "stock must be a whole number"},
+				{Line: 4, Error: "options must be name=value pairs"},
+				{Line: 5, Error: "publish_at must be an RFC 3339 timestamp"},
+				{Line: 6, Error: "wrong number of fields"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, rowErrors, err := parseImportFile(strings.NewReader(tt.file), importCSV)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(rowErrors, tt.expected) {
+				t.Errorf("unexpected row errors:\n got %v\nwant %v", rowErrors, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseImportJSONL(t *testing.T) {
+	file := `{"name":"Taza Azul","price":"35000","categories":["mugs"],"sku":"TAZA-AZUL-L","options":{"glaze":"celadon"},"stock":4}
+
+{"name":"Plato","price":{"amount":"42000.00","currency":"COP"},"color":"red"}
+{"name":
+`
+	rows, rowErrors, err := parseImportFile(strings.NewReader(file), importJSONL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expectedRows := []importRow{
+		{Line: 1, productInput: productInput{Name: "Taza Azul", Price: cop(3500000), Categories: []string{"mugs"}}, SKU: "TAZA-AZUL-L", Options: map[string]string{"glaze": "celadon"}, Stock: 4,
+			missing: map[string]bool{"description": true, "images": true, "referenced_name": true, "status": true, "publish_at": true, "unpublish_at": true}},
+	}
+	if !reflect.DeepEqual(rows, expectedRows) {
+		t.Errorf("unexpected rows:\n got %+v\nwant %+v", rows, expectedRows)
+	}
+	expectedErrors := []importRowError{
+		{Line: 3, Error: `invalid JSON: json: unknown field "color"`},
+		{Line: 4, Error: "invalid JSON: unexpected EOF"},
+	}
+	if !reflect.DeepEqual(rowErrors, expectedErrors) {
+		t.Errorf("unexpected row errors:\n got %v\nwant %v", rowErrors, expectedErrors)
+	}
+}
+
+func TestParseImportFormat(t *testing.T) {
+	tests := []struct {
+		format   string
+		expected string
+	}{
+		{format: "csv", expected: importCSV},
+		{format: "text/csv; charset=utf-8", expected: importCSV},
+		{format: "JSONL", expected: importJSONL},
+		{format: "application/x-ndjson", expected: importJSONL},
+		{format: "application/json", expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			format, err := parseImportFormat(tt.format)
+			if format != tt.expected {
+				t.Errorf("unexpected format: got %q want %q", format, tt.expected)
+			}
+			if tt.expected == "" && (err == nil || err.Error() != "format must be csv or jsonl") {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateImportRows(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	rows := []importRow{
+		{Line: 2, productInput: productInput{Name: "Taza Azul", Price: cop(100), Categories: []string{"mugs"}}, SKU: "TAZA-1"},
+		{Line: 3, productInput: productInput{Name: "Taza Roja", Price: cop(100)}, SKU: "TAZA-1"},
+		{Line: 4, productInput: productInput{Name: "Plato", Price: cop(100)}, Stock: 3},
+		{Line: 5, productInput: productInput{Price: cop(100)}},
+		{Line: 6, productInput: productInput{Name: "Jarrón", Price: cop(100), Categories: []string{"vasez"}}},
+	}
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT slug FROM categories WHERE slug = ANY($1)")).
+		WithArgs(textArray{"mugs", "vasez"}).
+		WillReturnRows(sqlmock.NewRows([]string{"slug"}).AddRow("mugs"))
+	expectImportSKUs(mock, textArray{"TAZA-1"}, nil)
+	expectImportSlugs(mock, textArray{"taza-azul"}, nil)
+
+	rowErrors, err := validateImportRows(db, rows)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []importRowError{
+		{Line: 3, Error: `sku "TAZA-1" is repeated, it is also in line 2`},
+		{Line: 4, Error: "options, variant_price and stock require a sku"},
+		{Line: 5, Error: "name is required"},
+		{Line: 6, Error: "unknown categories: vasez"},
+	}
+	if !reflect.DeepEqual(rowErrors, expected) {
+		t.Errorf("unexpected row errors:\n got %v\nwant %v", rowErrors, expected)
+	}
+	// Valid rows are normalized, with the slug of their name, and keep their empty status
+	if rows[0].Slug != "taza-azul" || rows[0].Status != "" || rows[0].Images == nil || rows[0].Options == nil {
+		t.Errorf("row was not normalized: %+v", rows[0])
+	}
+	checkMockExpectations(t, mock)
+}
+
+// expectImportSKUs sets the expectation of finding the variants with the SKUs of the valid rows, where products
+// maps the SKUs of existing variants to the slugs of their products.
+func expectImportSKUs(mock sqlmock.Sqlmock, skus textArray, products map[string]string) {
+	rows := sqlmock.NewRows([]string{"sku", "slug"})
+	for sku, slug := range products {
+		rows.AddRow(sku, slug)
+	}
+	mock.ExpectQuery(regexp.QuoteMeta(importSKUsQuery)).WithArgs(skus).WillReturnRows(rows)
+}
+
+// expectImportSlugs sets the expectation of resolving the slugs of the valid rows, where current maps the slugs
+// that products have or had to their current slugs.
+func expectImportSlugs(mock sqlmock.Sqlmock, slugs textArray, current map[string]string) {
+	rows := sqlmock.NewRows([]string{"slug", "slug"})
+	for slug, currentSlug := range current {
+		rows.AddRow(slug, currentSlug)
+	}
+	mock.ExpectQuery(regexp.QuoteMeta(importSlugsQuery)).WithArgs(slugs).WillReturnRows(rows)
+}
+
+func TestValidateImportRows_Slugs(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	rows := []importRow{
+		{Line: 2, productInput: productInput{Name: "Taza Azul", Slug: "taza-vieja", Price: cop(100)}},
+		{Line: 3, productInput: productInput{Name: "Plato", Price: cop(100)}},
+		{Line: 4, productInput: productInput{Name: "Jarrón", Price: cop(100)}},
+		{Line: 5, productInput: productInput{Name: "Jarron", Price: cop(100)}},
+		{Line: 6, productInput: productInput{Name: "Cuenco", Price: cop(100)}, SKU: "CUENCO-S"},
+		{Line: 7, productInput: productInput{Name: "Cuenco", Slug: "cuenco", Price: cop(100)}, SKU: "CUENCO-L"},
+		{Line: 8, productInput: productInput{Name: "Taza Azul", Price: cop(100)}},
+	}
+	expectImportSKUs(mock, textArray{"CUENCO-S", "CUENCO-L"}, nil)
+	expectImportSlugs(mock, textArray{"taza-vieja", "plato", "jarron", "cuenco", "taza-azul"},
+		map[string]string{"taza-vieja": "taza-azul", "plato": "plato", "cuenco": "cuenco", "taza-azul": "taza-azul"})
+
+	rowErrors, err := validateImportRows(db, rows)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The slugs generated from the names of lines 6 and 8 are named by the file, the one of line 8 by its old slug
+	expected := []importRowError{
+		{Line: 3, Error: `slug "plato" generated from the name belongs to another product, set a slug`},
+		{Line: 5, Error: `slug "jarron" generated from the name is also generated from another name in line 4, set a slug`},
+	}
+	if !reflect.DeepEqual(rowErrors, expected) {
+		t.Errorf("unexpected row errors:\n got %v\nwant %v", rowErrors, expected)
+	}
+	// Old slugs are replaced with the current ones
+	if rows[0].Slug != "taza-azul" || rows[6].Slug != "taza-azul" {
+		t.Errorf("old slug was not resolved: %q, %q", rows[0].Slug, rows[6].Slug)
+	}
+	checkMockExpectations(t, mock)
+}
+
+func TestValidateImportRows_MissingColumns(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	// A file with only the slug and price updates the price of existing products, and can't create products
+	rows, _, err := parseImportFile(strings.NewReader("slug,price\ntaza-azul,100\nplato,200\n"), importCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectImportSlugs(mock, textArray{"taza-azul", "plato"}, map[string]string{"taza-azul": "taza-azul"})
+
+	rowErrors, err := validateImportRows(db, rows)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []importRowError{{Line: 3, Error: `product "plato" is new, it needs a name and a price`}}
+	if !reflect.DeepEqual(rowErrors, expected) {
+		t.Errorf("unexpected row errors:\n got %v\nwant %v", rowErrors, expected)
+	}
+	checkMockExpectations(t, mock)
+}
+
+func TestValidateImportRows_SKUs(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	file := "slug,name,price,sku,stock\n,Taza Celeste,1,TAZA-AZUL-L,5\nplato,Plato,1,TAZA-AZUL-S,\n,Taza Azul,1,,\n"
+	rows, _, err := parseImportFile(strings.NewReader(file), importCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectImportSKUs(mock, textArray{"TAZA-AZUL-L", "TAZA-AZUL-S"}, map[string]string{"TAZA-AZUL-L": "taza-azul", "TAZA-AZUL-S": "taza-azul"})
+	expectImportSlugs(mock, textArray{"taza-celeste", "plato", "taza-azul"}, map[string]string{"plato": "plato", "taza-azul": "taza-azul"})
+
+	rowErrors, err := validateImportRows(db, rows)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []importRowError{{Line: 3, Error: `sku "TAZA-AZUL-S" belongs to the product with slug "taza-azul"`}}
+	if !reflect.DeepEqual(rowErrors, expected) {
+		t.Errorf("unexpected row errors:\n got %v\nwant %v", rowErrors, expected)
+	}
+	// The product of the SKU is updated with the new name, and names the product for the slug generated in line 4
+	if rows[0].Slug != "taza-azul" || rows[2].Slug != "taza-azul" {
+		t.Errorf("product of the sku was not found: %q, %q", rows[0].Slug, rows[2].Slug)
+	}
+	checkMockExpectations(t, mock)
+}
+
+func TestValidateImportRows_StockOnly(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	// A file without product columns finds the products by the SKUs
+	rows, _, err := parseImportFile(strings.NewReader("sku,stock\nTAZA-AZUL-L,5\nNUEVA-1,1\n"), importCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectImportSKUs(mock, textArray{"TAZA-AZUL-L", "NUEVA-1"}, map[string]string{"TAZA-AZUL-L": "taza-azul"})
+
+	rowErrors, err := validateImportRows(db, rows)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []importRowError{{Line: 3, Error: `sku "NUEVA-1" is new, set the slug or the name of its product`}}
+	if !reflect.DeepEqual(rowErrors, expected) {
+		t.Errorf("unexpected row errors:\n got %v\nwant %v", rowErrors, expected)
+	}
+	if rows[0].Slug != "taza-azul" {
+		t.Errorf("product of the sku was not found: %q", rows[0].Slug)
+	}
+	checkMockExpectations(t, mock)
+}
+
+func TestImportProductQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		expected string
+		status   string
+	}{
+		{name: "Every column", file: "slug,name,price,description,categories,images,referenced_name,status,publish_at,unpublish_at\nmug,Mug,1,,,,,draft,,\n",
+			expected: "name = EXCLUDED.name, price = EXCLUDED.price, description = EXCLUDED.description, categories = EXCLUDED.categories, images = EXCLUDED.images, " +
+				"referenced_name = EXCLUDED.referenced_name, status = EXCLUDED.status, publish_at = EXCLUDED.publish_at, unpublish_at = EXCLUDED.unpublish_at",
+			status: statusDraft},
+		// Existing products keep their status, and new ones are published
+		{name: "Empty status", file: "slug,price,status\nmug,1,\n", expected: "price = EXCLUDED.price", status: statusPublished},
+		{name: "No product columns", file: "slug,sku,stock\nmug,MUG-1,3\n", expected: "slug = EXCLUDED.slug", status: statusPublished},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, rowErrors, err := parseImportFile(strings.NewReader(tt.file), importCSV)
+			if err != nil || len(rowErrors) != 0 {
+				t.Fatalf("unexpected errors: %v %v", err, rowErrors)
+			}
+			if err := rows[0].validate(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if query := importProductQuery(rows[0]); !strings.Contains(query, "DO UPDATE SET "+tt.expected+" RETURNING") {
+				t.Errorf("unexpected query: %s", query)
+			}
+			if status := importStatus(rows[0]); status != tt.status {
+				t.Errorf("unexpected status: got %q want %q", status, tt.status)
+			}
+		})
+	}
+}
+
+// importTestRows returns two valid rows of the same product, each with a variant, from a file whose only product
+// columns are the name and the price.
+func importTestRows() []importRow {
+	rows := []importRow{
+		{Line: 2, productInput: productInput{Name: "Taza Azul", Price: cop(100)}, SKU: "TAZA-AZUL-S"},
+		{Line: 3, productInput: productInput{Name: "Taza Azul", Price: cop(100)}, SKU: "TAZA-AZUL-L", Options: map[string]string{"size": "large"}, Stock: 2},
+	}
+	missing := missingImportColumns(func(column string) bool { return column == "name" || column == "price" })
+	for i := range rows {
+		rows[i].missing = missing
+		if err := rows[i].validate(); err != nil {
+			panic(err)
+		}
+	}
+	return rows
+}
+
+// expectImportProduct sets the expectation of upserting the product of a row.
+func expectImportProduct(mock sqlmock.Sqlmock, row importRow, id int, inserted bool) {
+	mock.ExpectQuery(regexp.QuoteMeta(importProductQuery(row))).
+		WithArgs(row.Name, row.Slug, row.Price, row.Description, textArray(row.Categories), textArray(row.Images), row.ReferencedName, importStatus(row), row.PublishAt, row.UnpublishAt).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "inserted"}).AddRow(id, inserted))
+}
+
+// expectImportVariant sets the expectations of upserting the variant of a row, which updates the existing variant
+// if there is one and creates a variant with ID 20 otherwise, and of recording the units it gets as received.
+func expectImportVariant(mock sqlmock.Sqlmock, row importRow, productID int, existing *ProductVariant) {
+	stockRows := sqlmock.NewRows([]string{"product_id", "stock"})
+	variantID, previousStock := 20, 0
+	if existing != nil {
+		stockRows.AddRow(existing.ProductID, existing.Stock)
+		variantID, previousStock = existing.ID, existing.Stock
+	}
+	mock.ExpectQuery(regexp.QuoteMeta(importVariantStockQuery)).WithArgs(row.SKU).WillReturnRows(stockRows)
+	options, _ := json.Marshal(row.Options)
+	mock.ExpectQuery(regexp.QuoteMeta(importVariantQuery)).
+		WithArgs(productID, row.SKU, options, row.VariantPrice, textArray{}, row.Stock).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "inserted"}).AddRow(variantID, existing == nil))
+	if row.Stock > previousStock {
+		mock.ExpectExec(regexp.QuoteMeta(importAdjustmentQuery)).
+			WithArgs(productID, variantID, row.Stock-previousStock, importAdjustmentNote).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+	}
+}
+
+func TestImportCatalog(t *testing.T) {
+	for _, dryRun := range []bool{false, true} {
+		db, mock := getMockDB(t)
+		rows := importTestRows()
+		expectActor(mock, "admin")
+		expectImportProduct(mock, rows[0], 7, true)
+		expectImportVariant(mock, rows[0], 7, nil)
+		expectImportProduct(mock, rows[1], 7, false)
+		// The variant had one unit, so the import received one more
+		expectImportVariant(mock, rows[1], 7, &ProductVariant{ID: 21, ProductID: 7, Stock: 1})
+		if dryRun {
+			mock.ExpectRollback()
+		} else {
+			mock.ExpectCommit()
+		}
+
+		report, err := importCatalog(context.Background(), db, "admin", rows, dryRun)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		// The product is in both rows, and is only counted once
+		expected := importReport{DryRun: dryRun, Rows: 2, Products: importCounts{Created: 1}, Variants: importCounts{Created: 1, Updated: 1},
+			Errors: []importRowError{}, productIDs: []int{7}}
+		if !reflect.DeepEqual(report, expected) {
+			t.Errorf("unexpected report:\n got %+v\nwant %+v", report, expected)
+		}
+		checkMockExpectations(t, mock)
+		db.Close()
+	}
+}
+
+func TestImportCatalog_RowErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		setup         func(mock sqlmock.Sqlmock, rows []importRow)
+		expectedError importRowError
+	}{
+		{name: "SKU of another product", setup: func(mock sqlmock.Sqlmock, rows []importRow) {
+			expectImportProduct(mock, rows[0], 7, true)
+			mock.ExpectQuery(regexp.QuoteMeta(importVariantStockQuery)).WithArgs("TAZA-AZUL-S").
+				WillReturnRows(sqlmock.NewRows([]string{"product_id", "stock"}).AddRow(8, 0))
+		}, expectedError: importRowError{Line: 2, Error: `sku "TAZA-AZUL-S" belongs to another product`}},
+		{name: "Stock going down", setup: func(mock sqlmock.Sqlmock, rows []importRow) {
+			expectImportProduct(mock, rows[0], 7, false)
+			expectImportVariant(mock, rows[0], 7, &ProductVariant{ID: 21, ProductID: 7})
+			expectImportProduct(mock, rows[1], 7, false)
+			mock.ExpectQuery(regexp.QuoteMeta(importVariantStockQuery)).WithArgs("TAZA-AZUL-L").
+				WillReturnRows(sqlmock.NewRows([]string{"product_id", "stock"}).AddRow(7, 5))
+		}, expectedError: importRowError{Line: 3, Error: `stock of sku "TAZA-AZUL-L" can't go down from 5, record the units sold or broken as inventory adjustments`}},
+		{name: "Constraint", setup: func(mock sqlmock.Sqlmock, rows []importRow) {
+			mock.ExpectQuery(regexp.QuoteMeta(importProductQuery(rows[0]))).
+				WillReturnError(&pq.Error{Code: checkViolation, Message: `new row for relation "products" violates check constraint "products_publish_window"`})
+		}, expectedError: importRowError{Line: 2, Error: `new row for relation "products" violates check constraint "products_publish_window"`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := getMockDB(t)
+			defer db.Close()
+			rows := importTestRows()
+			expectActor(mock, "admin")
+			tt.setup(mock, rows)
+			mock.ExpectRollback()
+
+			report, err := importCatalog(context.Background(), db, "admin", rows, false)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(report.Errors, []importRowError{tt.expectedError}) {
+				t.Errorf("unexpected errors: %v", report.Errors)
+			}
+			checkMockExpectations(t, mock)
+		})
+	}
+}
+
+func TestImportCatalog_DBError(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	expectActor(mock, "admin")
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO products")).WillReturnError(errors.New("some error"))
+	mock.ExpectRollback()
+
+	if _, err := importCatalog(context.Background(), db, "admin", importTestRows(), false); err == nil {
+		t.Error("expected an error")
+	}
+	checkMockExpectations(t, mock)
+}
+
+func TestRunImport_InvalidRows(t *testing.T) {
+	// Parse and validation errors are reported together, sorted by line, and nothing is imported
+	db, mock := getMockDB(t)
+	defer db.Close()
+	expectImportSlugs(mock, textArray{"mug"}, nil)
+
+	file := "name,price,categories\n,1,\nMug,abc,\nMug,1,\n"
+	report, err := runImport(context.Background(), db, "admin", strings.NewReader(file), importCSV, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := importReport{Rows: 3, Errors: []importRowError{{Line: 2, Error: "name is required"}, {Line: 3, Error: "price must be an amount with at most 2 decimals"}}}
+	if !reflect.DeepEqual(report, expected) {
+		t.Errorf("unexpected report:\n got %+v\nwant %+v", report, expected)
+	}
+	checkMockExpectations(t, mock)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// categoryTreeQuery selects, for every category given by slug in $1, the slugs of the category itself and
+// all of its descendants. UNION instead of UNION ALL stops the recursion if the hierarchy has a cycle.
+const categoryTreeQuery = `WITH RECURSIVE tree AS (
+  SELECT slug AS root, id FROM categories WHERE slug = ANY($1)
+  UNION
+  SELECT tree.root, c.id FROM categories c JOIN tree ON c.parent_id = tree.id
+)
+SELECT tree.root, categories.slug FROM tree JOIN categories ON categories.id = tree.id ORDER BY tree.root, categories.slug`
+
+// expandCategories returns, for every category slug, the slugs of the category and all of its descendants.
+// Slugs that don't exist are not present in the returned map.
+func expandCategories(db *sql.DB, slugs []string) (map[string][]string, error) {
+	rows, err := db.Query(categoryTreeQuery, textArray(slugs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tree := map[string][]string{}
+	for rows.Next() {
+		var root, slug string
+		if err := rows.Scan(&root, &slug); err != nil {
+			return nil, err
+		}
+		tree[root] = append(tree[root], slug)
+	}
+	return tree, rows.Err()
+}
+
+// checkCategoriesExist returns a validationError listing the slugs that are not in the categories table.
+func checkCategoriesExist(db *sql.DB, slugs []string) error {
+	if len(slugs) == 0 {
+		return nil
+	}
+
+	rows, err := db.Query("SELECT slug FROM categories WHERE slug = ANY($1)", textArray(slugs))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	found := map[string]bool{}
+	for rows.Next() {
+		var slug string
+		if err := rows.Scan(&slug); err != nil {
+			return err
+		}
+		found[slug] = true
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	return unknownCategoriesError(slugs, func(slug string) bool { return found[slug] })
+}
+
+// unknownCategoriesError returns a validationError listing the slugs for which exists returns false, or nil.
+func unknownCategoriesError(slugs []string, exists func(slug string) bool) error {
+	unknown := []string{}
+	for _, slug := range slugs {
+		if !exists(slug) {
+			unknown = append(unknown, slug)
+		}
+	}
+	if len(unknown) > 0 {
+		return validationError{fmt.Sprintf("unknown categories: %s", strings.Join(unknown, ", "))}
+	}
+	return nil
+}
+
+// buildCategoryTree nests a flat list of categories under their parents. Categories are kept in the
+// order of the list at every level, and categories whose parent is not in the list are returned as roots.
+func buildCategoryTree(categories []Category) []Category {
+	byParent := map[int][]int{}
+	ids := map[int]bool{}
+	for _, c := range categories {
+		ids[c.ID] = true
+	}
+	roots := []int{}
+	for i, c := range categories {
+		if c.ParentID != nil && ids[*c.ParentID] && *c.ParentID != c.ID {
+			byParent[*c.ParentID] = append(byParent[*c.ParentID], i)
+		} else {
+			roots = append(roots, i)
+		}
+	}
+
+	// visited protects against cycles in the hierarchy
+	visited := map[int]bool{}
+	var build func(indexes []int) []Category
+	build = func(indexes []int) []Category {
+		nodes := []Category{}
+		for _, i := range indexes {
+			c := categories[i]
+			if visited[c.ID] {
+				continue
+			}
+			visited[c.ID] = true
+			if children := byParent[c.ID]; len(children) > 0 {
+				c.Children = build(children)
+			}
+			nodes = append(nodes, c)
+		}
+		return nodes
+	}
+	return build(roots)
+}
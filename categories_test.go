@@ -0,0 +1,120 @@
+package main
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// expectCategoryTree sets the mock expectation for expandCategories, where every category has no descendants.
+func expectCategoryTree(mock sqlmock.Sqlmock, slugs ...string) {
+	rows := sqlmock.NewRows([]string{"root", "slug"})
+	for _, slug := range slugs {
+		rows.AddRow(slug, slug)
+	}
+	mock.ExpectQuery(regexp.QuoteMeta(categoryTreeQuery)).WithArgs(textArray(slugs)).WillReturnRows(rows)
+}
+
+// expectCategoriesExist sets the mock expectation for checkCategoriesExist, where every category exists.
+func expectCategoriesExist(mock sqlmock.Sqlmock, slugs ...string) {
+	rows := sqlmock.NewRows([]string{"slug"})
+	for _, slug := range slugs {
+		rows.AddRow(slug)
+	}
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT slug FROM categories WHERE slug = ANY($1)")).WithArgs(textArray(slugs)).WillReturnRows(rows)
+}
+
+func TestCheckCategoriesExist(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT slug FROM categories WHERE slug = ANY($1)")).
+		WithArgs(textArray{"mugs", "vasez", "plates", "bowlz"}).
+		WillReturnRows(sqlmock.NewRows([]string{"slug"}).AddRow("mugs").AddRow("plates"))
+
+	err := checkCategoriesExist(db, []string{"mugs", "vasez", "plates", "bowlz"})
+	if err == nil || err.Error() != "unknown categories: vasez, bowlz" {
+		t.Errorf("unexpected error: %v", err)
+	}
+	checkMockExpectations(t, mock)
+
+	// No query is needed without categories
+	if err := checkCategoriesExist(db, nil); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestResolveCategories(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta(categoryTreeQuery)).
+		WithArgs(textArray{"tableware", "blue"}).
+		WillReturnRows(sqlmock.NewRows([]string{"root", "slug"}).
+			AddRow("blue", "blue").
+			AddRow("tableware", "mugs").
+			AddRow("tableware", "plates").
+			AddRow("tableware", "tableware"))
+
+	f := productFilter{Categories: []string{"tableware", "blue"}, AllCategories: true}
+	f, err := f.resolveCategories(db)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectedGroups := [][]string{{"mugs", "plates", "tableware"}, {"blue"}}
+	if !reflect.DeepEqual(f.CategoryGroups, expectedGroups) {
+		t.Errorf("unexpected groups: got %v want %v", f.CategoryGroups, expectedGroups)
+	}
+
+	qb := newQueryBuilder("SELECT id FROM products")
+	f.apply(qb)
+	query, args := qb.build()
+	if query != "SELECT id FROM products WHERE categories @> $1 AND categories && $2" {
+		t.Errorf("unexpected query: %q", query)
+	}
+	expectedArgs := []interface{}{textArray{"blue"}, textArray{"mugs", "plates", "tableware"}}
+	if !reflect.DeepEqual(args, expectedArgs) {
+		t.Errorf("unexpected args: got %v want %v", args, expectedArgs)
+	}
+	checkMockExpectations(t, mock)
+}
+
+func TestResolveCategories_Unknown(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta(categoryTreeQuery)).
+		WithArgs(textArray{"mugs", "teapots"}).
+		WillReturnRows(sqlmock.NewRows([]string{"root", "slug"}).AddRow("mugs", "mugs"))
+	_, err := productFilter{Categories: []string{"mugs", "teapots"}}.resolveCategories(db)
+	if err == nil || err.Error() != "unknown categories: teapots" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestBuildCategoryTree(t *testing.T) {
+	parent := func(id int) *int { return &id }
+	flat := []Category{
+		{ID: 1, Slug: "tableware"},
+		{ID: 2, Slug: "mugs", ParentID: parent(1)},
+		{ID: 3, Slug: "decor"},
+		{ID: 4, Slug: "espresso-cups", ParentID: parent(2)},
+		{ID: 5, Slug: "plates", ParentID: parent(1)},
+	}
+
+	expected := []Category{
+		{ID: 1, Slug: "tableware", Children: []Category{
+			{ID: 2, Slug: "mugs", ParentID: parent(1), Children: []Category{
+				{ID: 4, Slug: "espresso-cups", ParentID: parent(2)},
+			}},
+			{ID: 5, Slug: "plates", ParentID: parent(1)},
+		}},
+		{ID: 3, Slug: "decor"},
+	}
+
+	if tree := buildCategoryTree(flat); !reflect.DeepEqual(tree, expected) {
+		t.Errorf("unexpected tree: got %+v want %+v", tree, expected)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// catalogCacheControl lets browsers and proxies reuse catalog responses for a minute.
+// After that they are revalidated with their ETag or Last-Modified date.
+const catalogCacheControl = "public, max-age=60"
+
+// privateCacheControl keeps the responses to authorized requests, which can include products that are not
+// live, out of shared caches, and makes browsers revalidate them every time.
+const privateCacheControl = "private, no-cache"
+
+// encodeJSON returns the JSON encoding of v followed by a new line, as written by json.Encoder.
+func encodeJSON(v interface{}) ([]byte, error) {
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(v); err != nil {
+		return nil, err
+	}
+	return body.Bytes(), nil
+}
+
+// writeCacheableJSON writes a JSON response body that can be cached and revalidated.
+//
+// The response has a strong ETag, the SHA-256 of the body, and lastModified as its Last-Modified date
+// unless it is zero. Conditional requests (If-None-Match and If-Modified-Since) that match are answered
+// with an HTTP 304 Not Modified and no body. Responses to requests with an Authorization header are private.
+func writeCacheableJSON(w http.ResponseWriter, r *http.Request, body []byte, lastModified time.Time) {
+	sum := sha256.Sum256(body)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+	if r.Header.Get("Authorization") != "" {
+		w.Header().Set("Cache-Control", privateCacheControl)
+	} else {
+		w.Header().Set("Cache-Control", catalogCacheControl)
+	}
+	// ServeContent checks the ETag and Last-Modified preconditions and writes the body otherwise
+	http.ServeContent(w, r, "", lastModified, bytes.NewReader(body))
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWriteCacheableJSON(t *testing.T) {
+	lastModified := time.Date(2023, 6, 6, 12, 0, 0, 0, time.UTC)
+	serve := func(v interface{}, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/products/1", nil)
+		for k, values := range header {
+			req.Header[k] = values
+		}
+		body, err := encodeJSON(v)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		rr := httptest.NewRecorder()
+		writeCacheableJSON(rr, req, body, lastModified)
+		return rr
+	}
+
+	rr := serve(map[string]int{"id": 1}, nil)
+	checkResponseCode(t, rr.Code, http.StatusOK)
+	checkResponseBody(t, rr.Body.String(), "{\"id\":1}\n", nil)
+	etag := rr.Header().Get("ETag")
+	if etag == "" || etag[0] != '"' {
+		t.Errorf("expected a strong ETag, got %q", etag)
+	}
+	if got := rr.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("unexpected content type: %v", got)
+	}
+	if got := rr.Header().Get("Cache-Control"); got != catalogCacheControl {
+		t.Errorf("unexpected cache control: %v", got)
+	}
+	if got := rr.Header().Get("Last-Modified"); got != "Tue, 06 Jun 2023 12:00:00 GMT" {
+		t.Errorf("unexpected last modified: %v", got)
+	}
+
+	// The same content has the same ETag
+	rr = serve(map[string]int{"id": 1}, http.Header{"If-None-Match": {etag}})
+	checkResponseCode(t, rr.Code, http.StatusNotModified)
+	checkResponseBody(t, rr.Body.String(), "", nil)
+
+	// Changed content doesn't match the old ETag, even if the date does
+	rr = serve(map[string]int{"id": 2}, http.Header{"If-None-Match": {etag}, "If-Modified-Since": {"Tue, 06 Jun 2023 12:00:00 GMT"}})
+	checkResponseCode(t, rr.Code, http.StatusOK)
+	if rr.Header().Get("ETag") == etag {
+		t.Error("expected a different ETag for a different body")
+	}
+
+	rr = serve(map[string]int{"id": 1}, http.Header{"If-Modified-Since": {"Tue, 06 Jun 2023 12:00:00 GMT"}})
+	checkResponseCode(t, rr.Code, http.StatusNotModified)
+
+	// Admin responses stay out of shared caches
+	rr = serve(map[string]int{"id": 1}, http.Header{"Authorization": {"Bearer secret"}})
+	if got := rr.Header().Get("Cache-Control"); got != privateCacheControl {
+		t.Errorf("unexpected cache control: %v", got)
+	}
+}
@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE products ADD COLUMN search_vector tsvector;
+
+-- Names weigh the most, then the series and categories, then the description
+CREATE FUNCTION products_search_vector_update() RETURNS trigger AS $$
+BEGIN
+  NEW.search_vector :=
+    setweight(to_tsvector('spanish', coalesce(NEW.name, '')), 'A') ||
+    setweight(to_tsvector('spanish', coalesce(NEW.referenced_name, '')), 'B') ||
+    setweight(to_tsvector('spanish', coalesce(array_to_string(NEW.categories, ' '), '')), 'B') ||
+    setweight(to_tsvector('spanish', coalesce(NEW.description, '')), 'C');
+  RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER products_search_vector_trigger
+BEFORE INSERT OR UPDATE OF name, description, categories, referenced_name ON products
+FOR EACH ROW EXECUTE FUNCTION products_search_vector_update();
+
+-- Fill the column for the existing products
+UPDATE products SET name = name;
+
+CREATE INDEX products_search_vector_idx ON products USING GIN (search_vector);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS products_search_vector_idx;
+DROP TRIGGER IF EXISTS products_search_vector_trigger ON products;
+DROP FUNCTION IF EXISTS products_search_vector_update();
+ALTER TABLE products DROP COLUMN IF EXISTS search_vector;
+-- +goose StatementEnd
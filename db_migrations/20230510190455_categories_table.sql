@@ -0,0 +1,36 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE categories (
+  id SERIAL PRIMARY KEY,
+  slug TEXT NOT NULL UNIQUE CHECK (slug ~ '^[a-z0-9]+(-[a-z0-9]+)*$'),
+  name TEXT NOT NULL,
+  parent_id INT REFERENCES categories(id),
+  sort_order INT NOT NULL DEFAULT 0,
+  CHECK (parent_id <> id)
+);
+
+CREATE INDEX categories_parent_id_idx ON categories (parent_id);
+
+-- Create a category for every name already used by a product
+INSERT INTO categories (slug, name)
+SELECT DISTINCT ON (slug) slug, name FROM (
+  SELECT trim(both '-' from regexp_replace(translate(lower(c), 'áéíóúüñ', 'aeiouun'), '[^a-z0-9]+', '-', 'g')) AS slug, c AS name
+  FROM products, unnest(categories) c
+) names
+ORDER BY slug, name;
+
+-- Products refer to their categories by slug from now on
+UPDATE products SET categories = ARRAY(
+  SELECT trim(both '-' from regexp_replace(translate(lower(c), 'áéíóúüñ', 'aeiouun'), '[^a-z0-9]+', '-', 'g'))
+  FROM unnest(categories) c
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+UPDATE products SET categories = ARRAY(
+  SELECT coalesce(categories.name, c) FROM unnest(products.categories) c LEFT JOIN categories ON categories.slug = c
+);
+
+DROP TABLE IF EXISTS categories;
+-- +goose StatementEnd
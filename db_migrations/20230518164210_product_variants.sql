@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE product_variants (
+  id SERIAL PRIMARY KEY,
+  product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+  sku TEXT NOT NULL UNIQUE,
+  -- Option values that tell the variant apart, e.g. {"glaze": "celadon", "size": "large"}
+  options JSONB NOT NULL DEFAULT '{}',
+  -- NULL when the variant costs the same as its product
+  price DECIMAL(10,2),
+  images TEXT[] NOT NULL DEFAULT '{}',
+  stock INT NOT NULL DEFAULT 0 CHECK (stock >= 0)
+);
+
+CREATE INDEX product_variants_product_id_idx ON product_variants (product_id);
+
+-- Cart items can point to a specific variant of their product
+ALTER TABLE shopping_cart_items ADD COLUMN variant_id INT REFERENCES product_variants(id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE shopping_cart_items DROP COLUMN IF EXISTS variant_id;
+DROP TABLE IF EXISTS product_variants;
+-- +goose StatementEnd
@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE products ADD COLUMN stock_quantity INT NOT NULL DEFAULT 0 CHECK (stock_quantity >= 0);
+
+-- Every change of the stock of a product or variant, with the reason it happened
+CREATE TABLE inventory_adjustments (
+  id SERIAL PRIMARY KEY,
+  product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+  variant_id INT REFERENCES product_variants(id) ON DELETE CASCADE,
+  reason TEXT NOT NULL CHECK (reason IN ('received', 'sold', 'broken', 'returned')),
+  -- Positive when units were added to the stock, negative when they were taken out
+  quantity INT NOT NULL CHECK (quantity <> 0),
+  note TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX inventory_adjustments_product_id_idx ON inventory_adjustments (product_id, created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS inventory_adjustments;
+ALTER TABLE products DROP COLUMN IF EXISTS stock_quantity;
+-- +goose StatementEnd
@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Units of a product or variant held by a shopping cart until the cart expires
+CREATE TABLE stock_reservations (
+  id SERIAL PRIMARY KEY,
+  -- The Redis key of the shopping cart, its IP address
+  cart_key TEXT NOT NULL,
+  product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+  variant_id INT REFERENCES product_variants(id) ON DELETE CASCADE,
+  quantity INT NOT NULL CHECK (quantity > 0),
+  expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX stock_reservations_cart_key_idx ON stock_reservations (cart_key);
+CREATE INDEX stock_reservations_product_id_idx ON stock_reservations (product_id, variant_id, expires_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS stock_reservations;
+-- +goose StatementEnd
@@ -0,0 +1,45 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE products ADD COLUMN updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW();
+
+UPDATE products SET updated_at = date_added;
+
+CREATE FUNCTION products_touch_updated_at() RETURNS trigger AS $$
+BEGIN
+  NEW.updated_at := NOW();
+  RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER products_updated_at_update BEFORE UPDATE ON products
+FOR EACH ROW EXECUTE FUNCTION products_touch_updated_at();
+
+-- Variants and stock reservations are part of the product responses, so changing them touches the product too.
+-- Reservations that expire without being deleted don't, until the next cart that is saved cleans them up.
+CREATE FUNCTION products_touch_parent() RETURNS trigger AS $$
+BEGIN
+  IF TG_OP = 'DELETE' THEN
+    UPDATE products SET updated_at = NOW() WHERE id = OLD.product_id;
+    RETURN OLD;
+  END IF;
+  UPDATE products SET updated_at = NOW() WHERE id = NEW.product_id;
+  RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER product_variants_touch_product AFTER INSERT OR UPDATE OR DELETE ON product_variants
+FOR EACH ROW EXECUTE FUNCTION products_touch_parent();
+
+CREATE TRIGGER stock_reservations_touch_product AFTER INSERT OR DELETE ON stock_reservations
+FOR EACH ROW EXECUTE FUNCTION products_touch_parent();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS stock_reservations_touch_product ON stock_reservations;
+DROP TRIGGER IF EXISTS product_variants_touch_product ON product_variants;
+DROP FUNCTION IF EXISTS products_touch_parent();
+DROP TRIGGER IF EXISTS products_updated_at_update ON products;
+DROP FUNCTION IF EXISTS products_touch_updated_at();
+ALTER TABLE products DROP COLUMN IF EXISTS updated_at;
+-- +goose StatementEnd
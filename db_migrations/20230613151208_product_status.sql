@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Products are only shown to shoppers while they are published and inside their publishing window.
+-- Existing products were already public, so they start as published.
+ALTER TABLE products
+  ADD COLUMN status TEXT NOT NULL DEFAULT 'published' CHECK (status IN ('draft', 'published', 'archived')),
+  ADD COLUMN publish_at TIMESTAMP WITH TIME ZONE,
+  ADD COLUMN unpublish_at TIMESTAMP WITH TIME ZONE,
+  ADD CONSTRAINT products_publish_window CHECK (unpublish_at > publish_at);
+
+CREATE INDEX products_status_idx ON products (status);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS products_status_idx;
+ALTER TABLE products
+  DROP CONSTRAINT IF EXISTS products_publish_window,
+  DROP COLUMN IF EXISTS unpublish_at,
+  DROP COLUMN IF EXISTS publish_at,
+  DROP COLUMN IF EXISTS status;
+-- +goose StatementEnd
@@ -0,0 +1,46 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE products ADD COLUMN slug TEXT;
+
+-- Slugs of the existing products, from their names without accents. Repeated ones get the product id appended.
+UPDATE products SET slug = COALESCE(NULLIF(trim(BOTH '-' FROM regexp_replace(
+  lower(translate(name, 'áéíóúüñàèìòùâêîôûäëïöçÁÉÍÓÚÜÑÀÈÌÒÙÂÊÎÔÛÄËÏÖÇ', 'aeiouunaeiouaeiouaeiocAEIOUUNAEIOUAEIOUAEIOC')),
+  '[^a-z0-9]+', '-', 'g')), ''), 'product');
+
+UPDATE products SET slug = products.slug || '-' || products.id
+FROM (SELECT id, row_number() OVER (PARTITION BY slug ORDER BY id) AS n FROM products) numbered
+WHERE numbered.id = products.id AND numbered.n > 1;
+
+ALTER TABLE products ALTER COLUMN slug SET NOT NULL, ADD CONSTRAINT products_slug_key UNIQUE (slug);
+
+-- Old slugs of the products, so their links keep working
+CREATE TABLE product_slug_history (
+  slug TEXT PRIMARY KEY,
+  product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX product_slug_history_product_id_idx ON product_slug_history (product_id);
+
+-- Changing a slug keeps the old one in the history, and a slug that is in use is never an old one
+CREATE FUNCTION products_slug_history() RETURNS trigger AS $$
+BEGIN
+  DELETE FROM product_slug_history WHERE slug = NEW.slug;
+  IF TG_OP = 'UPDATE' AND OLD.slug <> NEW.slug THEN
+    INSERT INTO product_slug_history (slug, product_id) VALUES (OLD.slug, NEW.id);
+  END IF;
+  RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER products_slug_history AFTER INSERT OR UPDATE OF slug ON products
+FOR EACH ROW EXECUTE FUNCTION products_slug_history();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS products_slug_history ON products;
+DROP FUNCTION IF EXISTS products_slug_history();
+DROP TABLE IF EXISTS product_slug_history;
+ALTER TABLE products DROP COLUMN IF EXISTS slug;
+-- +goose StatementEnd
@@ -0,0 +1,55 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE product_reviews (
+  id SERIAL PRIMARY KEY,
+  product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+  rating SMALLINT NOT NULL CHECK (rating BETWEEN 1 AND 5),
+  title TEXT NOT NULL DEFAULT '',
+  body TEXT NOT NULL DEFAULT '',
+  author TEXT NOT NULL,
+  verified_purchase BOOLEAN NOT NULL DEFAULT FALSE,
+  -- Reviews are only shown, and counted in the product ratings, once they are approved
+  status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX product_reviews_product_id_idx ON product_reviews (product_id, status, created_at DESC, id DESC);
+
+ALTER TABLE products
+  ADD COLUMN average_rating NUMERIC(3, 2) NOT NULL DEFAULT 0,
+  ADD COLUMN review_count INT NOT NULL DEFAULT 0;
+
+CREATE INDEX products_average_rating_idx ON products (average_rating, id);
+
+-- Keep the rating of the products up to date with their approved reviews
+CREATE FUNCTION products_review_stats() RETURNS trigger AS $$
+DECLARE
+  pid INT;
+BEGIN
+  IF TG_OP = 'DELETE' THEN
+    pid := OLD.product_id;
+  ELSE
+    pid := NEW.product_id;
+  END IF;
+  UPDATE products SET average_rating = stats.average, review_count = stats.count
+  FROM (
+    SELECT COALESCE(ROUND(AVG(rating), 2), 0) AS average, COUNT(*) AS count
+    FROM product_reviews WHERE product_id = pid AND status = 'approved'
+  ) stats
+  WHERE products.id = pid;
+  RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER product_reviews_stats AFTER INSERT OR UPDATE OF status, rating OR DELETE ON product_reviews
+FOR EACH ROW EXECUTE FUNCTION products_review_stats();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS product_reviews_stats ON product_reviews;
+DROP FUNCTION IF EXISTS products_review_stats();
+DROP INDEX IF EXISTS products_average_rating_idx;
+ALTER TABLE products DROP COLUMN IF EXISTS review_count, DROP COLUMN IF EXISTS average_rating;
+DROP TABLE IF EXISTS product_reviews;
+-- +goose StatementEnd
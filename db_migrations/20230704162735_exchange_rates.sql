@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE exchange_rates (
+  currency CHAR(3) PRIMARY KEY,
+  -- rate is the price of one unit of the currency in the store currency, e.g. 4150.25 COP per USD
+  rate NUMERIC(20, 8) NOT NULL CHECK (rate > 0),
+  -- Converted prices are rounded to a multiple of rounding_increment, e.g. 0.05 or 1, with rounding_mode
+  rounding_increment NUMERIC(12, 4) NOT NULL DEFAULT 0.01 CHECK (rounding_increment > 0),
+  rounding_mode TEXT NOT NULL DEFAULT 'half_up' CHECK (rounding_mode IN ('half_up', 'up', 'down')),
+  updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS exchange_rates;
+-- +goose StatementEnd
@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+-- The products and categories tables have the content in the default locale (es), these tables have the other ones
+CREATE TABLE product_translations (
+  product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+  locale TEXT NOT NULL,
+  name TEXT NOT NULL,
+  -- A NULL description falls back to the description in the default locale
+  description TEXT,
+  PRIMARY KEY (product_id, locale)
+);
+
+CREATE TABLE category_translations (
+  category_id INT NOT NULL REFERENCES categories(id) ON DELETE CASCADE,
+  locale TEXT NOT NULL,
+  name TEXT NOT NULL,
+  PRIMARY KEY (category_id, locale)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS category_translations;
+DROP TABLE IF EXISTS product_translations;
+-- +goose StatementEnd
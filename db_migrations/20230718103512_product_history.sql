@@ -0,0 +1,59 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Every change to a product, with its values before and after it. The history of deleted products is kept.
+CREATE TABLE product_history (
+  id BIGSERIAL PRIMARY KEY,
+  product_id INT NOT NULL,
+  operation TEXT NOT NULL CHECK (operation IN ('create', 'update', 'delete')),
+  old_values JSONB,
+  new_values JSONB,
+  actor TEXT NOT NULL,
+  changed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX product_history_product_id_idx ON product_history (product_id, changed_at, id);
+
+-- The values of a product are its columns, without the ones that only follow the others. Changes are made by the
+-- actor set with set_config('app.actor', ...) in their transaction, or else by the database user.
+CREATE FUNCTION products_record_history() RETURNS trigger AS $$
+DECLARE
+  old_values JSONB;
+  new_values JSONB;
+BEGIN
+  IF TG_OP <> 'INSERT' THEN
+    old_values := to_jsonb(OLD) - 'search_vector' - 'updated_at';
+  END IF;
+  IF TG_OP <> 'DELETE' THEN
+    new_values := to_jsonb(NEW) - 'search_vector' - 'updated_at';
+  END IF;
+  -- Updates that only touch the product, like the ones of its variants, change nothing
+  IF TG_OP = 'UPDATE' AND old_values = new_values THEN
+    RETURN NULL;
+  END IF;
+
+  INSERT INTO product_history (product_id, operation, old_values, new_values, actor)
+  VALUES (
+    CASE TG_OP WHEN 'DELETE' THEN OLD.id ELSE NEW.id END,
+    CASE TG_OP WHEN 'INSERT' THEN 'create' WHEN 'UPDATE' THEN 'update' ELSE 'delete' END,
+    old_values,
+    new_values,
+    COALESCE(NULLIF(current_setting('app.actor', true), ''), current_user)
+  );
+  RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER products_record_history AFTER INSERT OR UPDATE OR DELETE ON products
+FOR EACH ROW EXECUTE FUNCTION products_record_history();
+
+-- The history of the existing products starts with their current values, since their last update
+INSERT INTO product_history (product_id, operation, new_values, actor, changed_at)
+SELECT id, 'update', to_jsonb(products) - 'search_vector' - 'updated_at', current_user, updated_at FROM products;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS products_record_history ON products;
+DROP FUNCTION IF EXISTS products_record_history();
+DROP TABLE IF EXISTS product_history;
+-- +goose StatementEnd
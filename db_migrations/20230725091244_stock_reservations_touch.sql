@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+-- The trigger touched the product of every reservation in any order, which could deadlock two carts. Saving a cart
+-- now touches the products whose reservations changed once, in the order of their IDs.
+DROP TRIGGER IF EXISTS stock_reservations_touch_product ON stock_reservations;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE TRIGGER stock_reservations_touch_product AFTER INSERT OR DELETE ON stock_reservations
+FOR EACH ROW EXECUTE FUNCTION products_touch_parent();
+-- +goose StatementEnd
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// foreignKeyViolation is the PostgreSQL error code raised when a row is still referenced by another table.
+const foreignKeyViolation = "23503"
+
+// deleteProduct handles the HTTP request for removing a product from the catalog.
+//
+// The product is archived instead of deleted, so it is hidden from shoppers while the shopping cart items
+// and inventory adjustments that refer to it stay valid. Admins can still see it and publish it again.
+// It expects the ID of the product as a URL parameter and returns an HTTP 204 No Content on success.
+// If the ID is not valid, it returns an HTTP 400 Bad Request error.
+// If the product does not exist, it returns an HTTP 404 Not Found error.
+// If there is an error while archiving the product, it returns an HTTP 500 Internal Server Error.
+func (ph ProductsHandler) deleteProduct(w http.ResponseWriter, r *http.Request) {
+	// Extract product ID from URL parameter
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+
+	var affected int64
+	err = runAsActor(r.Context(), ph.db, requestActor(r), func(tx *sql.Tx) error {
+		result, err := tx.Exec("UPDATE products SET status = 'archived' WHERE id = $1", id)
+		if err != nil {
+			return err
+		}
+		affected, err = result.RowsAffected()
+		return err
+	})
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if affected == 0 {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	}
+
+	// Drop the cached responses that include the product
+	ph.cache.invalidate(r.Context(), id)
+
+	w.WriteHeader(http.StatusNoContent)
+}
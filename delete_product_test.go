@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestDeleteProduct(t *testing.T) {
+	tests := []struct {
+		name           string
+		setup          func(mock sqlmock.Sqlmock)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "Archived",
+			setup: func(mock sqlmock.Sqlmock) {
+				expectActor(mock, "admin")
+				mock.ExpectExec(regexp.QuoteMeta("UPDATE products SET status = 'archived' WHERE id = $1")).WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			expectedStatus: http.StatusNoContent,
+			expectedBody:   "",
+		},
+		{
+			name: "Not found",
+			setup: func(mock sqlmock.Sqlmock) {
+				expectActor(mock, "admin")
+				mock.ExpectExec(regexp.QuoteMeta("UPDATE products SET status = 'archived' WHERE id = $1")).WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectCommit()
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   "Product not found\n",
+		},
+		{
+			name: "Database error",
+			setup: func(mock sqlmock.Sqlmock) {
+				expectActor(mock, "admin")
+				mock.ExpectExec(regexp.QuoteMeta("UPDATE products SET status = 'archived' WHERE id = $1")).WithArgs(1).WillReturnError(errors.New("some error"))
+				mock.ExpectRollback()
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   "Internal server error\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := getMockDB(t)
+			defer db.Close()
+			tt.setup(mock)
+
+			ph := ProductsHandler{db: db}
+			rr := serveProductRequest(t, ph.deleteProduct, http.MethodDelete, "/products/1", "", map[string]string{"id": "1"})
+
+			checkResponseCode(t, rr.Code, tt.expectedStatus)
+			checkResponseBody(t, rr.Body.String(), tt.expectedBody, nil)
+			checkMockExpectations(t, mock)
+		})
+	}
+}
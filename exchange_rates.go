@@ -0,0 +1,233 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Rounding modes of the prices converted to another currency.
+const (
+	roundHalfUp = "half_up"
+	roundUp     = "up"
+	roundDown   = "down"
+)
+
+// ExchangeRate converts the prices in the store currency to another currency.
+type ExchangeRate struct {
+	Currency string `json:"currency"`
+	// Rate is the price of one unit of the currency in the store currency, e.g. "4150.25" COP per USD.
+	Rate string `json:"rate"`
+	// Converted prices are rounded to a multiple of RoundingIncrement with RoundingMode.
+	RoundingIncrement string    `json:"rounding_increment"`
+	RoundingMode      string    `json:"rounding_mode"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// exchangeRateColumns is the list of columns selected for every ExchangeRate, in the order expected by scanExchangeRate.
+const exchangeRateColumns = "currency, rate, rounding_increment, rounding_mode, updated_at"
+
+// scanExchangeRate scans a row selected with exchangeRateColumns into er.
+func scanExchangeRate(rs rowScanner, er *ExchangeRate) error {
+	err := rs.Scan(&er.Currency, &er.Rate, &er.RoundingIncrement, &er.RoundingMode, &er.UpdatedAt)
+	er.Rate = trimDecimal(er.Rate)
+	er.RoundingIncrement = trimDecimal(er.RoundingIncrement)
+	return err
+}
+
+// trimDecimal removes the trailing zeros that NUMERIC columns are scanned with, e.g. "0.0500" becomes "0.05".
+func trimDecimal(s string) string {
+	if !strings.Contains(s, ".") {
+		return s
+	}
+	return strings.TrimSuffix(strings.TrimRight(s, "0"), ".")
+}
+
+// convert returns the amount m, in the store currency, in the currency of the exchange rate.
+// The amount is computed exactly and rounded once, to a multiple of the rounding increment.
+func (er ExchangeRate) convert(m Money) (Money, error) {
+	out := Money{Currency: er.Currency}
+	rate, ok := new(big.Rat).SetString(er.Rate)
+	if !ok || rate.Sign() <= 0 {
+		return out, fmt.Errorf("invalid exchange rate %q for %s", er.Rate, er.Currency)
+	}
+	increment, ok := new(big.Rat).SetString(er.RoundingIncrement)
+	if !ok || increment.Sign() <= 0 {
+		return out, fmt.Errorf("invalid rounding increment %q for %s", er.RoundingIncrement, er.Currency)
+	}
+
+	// The amount in minor units of the currency, and the increment in the same units
+	amount := new(big.Rat).SetFrac(big.NewInt(m.Amount), pow10(m.exponent()))
+	amount.Quo(amount, rate)
+	amount.Mul(amount, new(big.Rat).SetInt(pow10(out.exponent())))
+	increment.Mul(increment, new(big.Rat).SetInt(pow10(out.exponent())))
+	if !increment.IsInt() {
+		return out, fmt.Errorf("rounding increment %q for %s has more decimals than the currency", er.RoundingIncrement, er.Currency)
+	}
+
+	// Round the number of increments, amount / increment, with the rounding mode
+	steps := amount.Quo(amount, increment)
+	n, rem := new(big.Int).QuoRem(steps.Num(), steps.Denom(), new(big.Int))
+	if rem.Sign() != 0 && steps.Sign() > 0 {
+		switch er.RoundingMode {
+		case roundUp:
+			n.Add(n, big.NewInt(1))
+		case roundHalfUp:
+			if new(big.Int).Mul(rem, big.NewInt(2)).Cmp(steps.Denom()) >= 0 {
+				n.Add(n, big.NewInt(1))
+			}
+		}
+	}
+	n.Mul(n, increment.Num())
+	if !n.IsInt64() {
+		return out, fmt.Errorf("converted amount of %s is out of range", m)
+	}
+	out.Amount = n.Int64()
+	return out, nil
+}
+
+// pow10 returns 10 to the power of exp.
+func pow10(exp int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(exp)), nil)
+}
+
+// convertPrices converts the prices of the products and their variants with the exchange rate,
+// and sets the rate in every product.
+func convertPrices(products []Product, er ExchangeRate) error {
+	for i := range products {
+		p := &products[i]
+		price, err := er.convert(p.Price)
+		if err != nil {
+			return err
+		}
+		p.Price = price
+		for j := range p.Variants {
+			v := &p.Variants[j]
+			if v.Price == nil {
+				continue
+			}
+			price, err := er.convert(*v.Price)
+			if err != nil {
+				return err
+			}
+			v.Price = &price
+		}
+		rate := er
+		p.ExchangeRate = &rate
+	}
+	return nil
+}
+
+// supportedCurrencies returns the currencies prices can be shown in, sorted.
+func supportedCurrencies() []string {
+	currencies := make([]string, 0, len(currencyExponents))
+	for c := range currencyExponents {
+		currencies = append(currencies, c)
+	}
+	sort.Strings(currencies)
+	return currencies
+}
+
+// currencyRequest is the currency a response was asked in.
+type currencyRequest struct {
+	currency string
+	// explicit is true when the currency was asked with the currency query parameter, and false when
+	// it was negotiated with the Accept-Currency header, in which case the store currency can be used instead.
+	explicit bool
+}
+
+// parseCurrencyRequest reads the currency of the prices of a product response: the currency query parameter,
+// or else the first supported currency of the Accept-Currency header (e.g. "USD, EUR;q=0.5"), or else the store
+// currency. It returns a validationError if the currency query parameter is not a supported currency.
+func parseCurrencyRequest(r *http.Request) (currencyRequest, error) {
+	if c := r.URL.Query().Get("currency"); c != "" {
+		c = strings.ToUpper(c)
+		if _, ok := currencyExponents[c]; !ok {
+			return currencyRequest{}, validationError{"currency must be one of " + strings.Join(supportedCurrencies(), ", ")}
+		}
+		return currencyRequest{currency: c, explicit: true}, nil
+	}
+
+	for _, c := range strings.Split(r.Header.Get("Accept-Currency"), ",") {
+		c, _, _ = strings.Cut(c, ";")
+		c = strings.ToUpper(strings.TrimSpace(c))
+		if _, ok := currencyExponents[c]; ok {
+			return currencyRequest{currency: c}, nil
+		}
+	}
+	return currencyRequest{currency: storeCurrency}, nil
+}
+
+// converted reports whether the prices must be converted from the store currency.
+func (cr currencyRequest) converted() bool {
+	return cr.currency != storeCurrency
+}
+
+// loadExchangeRate returns the exchange rate of the requested currency, or nil for the store currency.
+// Currencies without a rate are a validationError when they were asked explicitly, and fall back to the
+// store currency when they were negotiated.
+func loadExchangeRate(db *sql.DB, cr currencyRequest) (*ExchangeRate, error) {
+	if !cr.converted() {
+		return nil, nil
+	}
+	er := ExchangeRate{}
+	err := scanExchangeRate(db.QueryRow("SELECT "+exchangeRateColumns+" FROM exchange_rates WHERE currency = $1", cr.currency), &er)
+	if err == sql.ErrNoRows {
+		if cr.explicit {
+			return nil, validationError{"there is no exchange rate for " + cr.currency}
+		}
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &er, nil
+}
+
+// exchangeRateInput is the request body accepted when setting the exchange rate of a currency.
+type exchangeRateInput struct {
+	Rate              json.Number `json:"rate"`
+	RoundingIncrement json.Number `json:"rounding_increment"`
+	RoundingMode      string      `json:"rounding_mode"`
+}
+
+// normalize sets the defaults of the optional fields: rounding to the minor unit of the currency, half up.
+func (in *exchangeRateInput) normalize(currency string) {
+	if in.RoundingIncrement == "" {
+		in.RoundingIncrement = json.Number(Money{Amount: 1, Currency: currency}.String())
+	}
+	if in.RoundingMode == "" {
+		in.RoundingMode = roundHalfUp
+	}
+}
+
+// validate checks every field of an exchange rate input for the given currency.
+func (in exchangeRateInput) validate(currency string) error {
+	if currency == storeCurrency {
+		return validationError{"the store currency has no exchange rate"}
+	}
+	if _, ok := currencyExponents[currency]; !ok {
+		return validationError{"currency must be one of " + strings.Join(supportedCurrencies(), ", ")}
+	}
+	if rate, ok := new(big.Rat).SetString(in.Rate.String()); !ok || rate.Sign() <= 0 || strings.ContainsAny(in.Rate.String(), "eE") {
+		return validationError{"rate must be a positive decimal number"}
+	}
+	// The increment must be a whole number of minor units of the currency
+	increment, ok := new(big.Rat).SetString(in.RoundingIncrement.String())
+	if ok {
+		increment.Mul(increment, new(big.Rat).SetInt(pow10(Money{Currency: currency}.exponent())))
+	}
+	if !ok || increment.Sign() <= 0 || !increment.IsInt() || strings.ContainsAny(in.RoundingIncrement.String(), "eE") {
+		return validationError{"rounding_increment must be a positive multiple of the minor unit of " + currency}
+	}
+	switch in.RoundingMode {
+	case roundHalfUp, roundUp, roundDown:
+	default:
+		return validationError{"rounding_mode must be half_up, up or down"}
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// getExchangeRateRows returns the rows of the given exchange rates as selected with exchangeRateColumns.
+func getExchangeRateRows(rates ...ExchangeRate) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"currency", "rate", "rounding_increment", "rounding_mode", "updated_at"})
+	for _, er := range rates {
+		rows.AddRow(er.Currency, er.Rate, er.RoundingIncrement, er.RoundingMode, er.UpdatedAt)
+	}
+	return rows
+}
+
+// expectExchangeRate sets the expectation of loading the exchange rate of its currency.
+func expectExchangeRate(mock sqlmock.Sqlmock, er ExchangeRate) {
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT " + exchangeRateColumns + " FROM exchange_rates WHERE currency = $1")).
+		WithArgs(er.Currency).
+		WillReturnRows(getExchangeRateRows(er))
+}
+
+func TestExchangeRateConvert(t *testing.T) {
+	tests := []struct {
+		name      string
+		amount    Money
+		rate      ExchangeRate
+		expected  string
+		expectErr bool
+	}{
+		{name: "Half up", amount: cop(4500000), rate: ExchangeRate{Currency: "USD", Rate: "4150.25", RoundingIncrement: "0.01", RoundingMode: roundHalfUp}, expected: "10.84"},
+		{name: "Up", amount: cop(4500000), rate: ExchangeRate{Currency: "USD", Rate: "4150.25", RoundingIncrement: "0.01", RoundingMode: roundUp}, expected: "10.85"},
+		{name: "Down", amount: cop(4500000), rate: ExchangeRate{Currency: "USD", Rate: "4150.25", RoundingIncrement: "0.01", RoundingMode: roundDown}, expected: "10.84"},
+		{name: "Five cents", amount: cop(4500000), rate: ExchangeRate{Currency: "EUR", Rate: "4150.25", RoundingIncrement: "0.05", RoundingMode: roundHalfUp}, expected: "10.85"},
+		{name: "Whole units up", amount: cop(4500000), rate: ExchangeRate{Currency: "USD", Rate: "4150.25", RoundingIncrement: "1", RoundingMode: roundUp}, expected: "11.00"},
+		{name: "Exact", amount: cop(4150250), rate: ExchangeRate{Currency: "USD", Rate: "4150.25", RoundingIncrement: "0.01", RoundingMode: roundUp}, expected: "10.00"},
+		{name: "Half rounds up", amount: cop(2025), rate: ExchangeRate{Currency: "USD", Rate: "1", RoundingIncrement: "0.5", RoundingMode: roundHalfUp}, expected: "20.50"},
+		{name: "Half rounds down", amount: cop(2025), rate: ExchangeRate{Currency: "USD", Rate: "1", RoundingIncrement: "0.5", RoundingMode: roundDown}, expected: "20.00"},
+		{name: "Invalid rate", amount: cop(100), rate: ExchangeRate{Currency: "USD", Rate: "0", RoundingIncrement: "0.01", RoundingMode: roundHalfUp}, expectErr: true},
+		{name: "Increment smaller than the minor unit", amount: cop(100), rate: ExchangeRate{Currency: "USD", Rate: "1", RoundingIncrement: "0.001", RoundingMode: roundHalfUp}, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := tt.rate.convert(tt.amount)
+			if tt.expectErr {
+				if err == nil {
+					t.Errorf("expected an error, got %v", m)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if m.String() != tt.expected || m.Currency != tt.rate.Currency {
+				t.Errorf("unexpected amount: got %v %s want %s %s", m, m.Currency, tt.expected, tt.rate.Currency)
+			}
+		})
+	}
+}
+
+func TestParseCurrencyRequest(t *testing.T) {
+	tests := []struct {
+		name           string
+		target         string
+		acceptCurrency string
+		expected       currencyRequest
+		expectedErr    string
+	}{
+		{name: "Default", target: "/products", expected: currencyRequest{currency: storeCurrency}},
+		{name: "Query parameter", target: "/products?currency=usd", acceptCurrency: "EUR", expected: currencyRequest{currency: "USD", explicit: true}},
+		{name: "Header", target: "/products", acceptCurrency: "GBP, eur;q=0.8, USD;q=0.5", expected: currencyRequest{currency: "EUR"}},
+		{name: "Unsupported header", target: "/products", acceptCurrency: "GBP", expected: currencyRequest{currency: storeCurrency}},
+		{name: "Unsupported query parameter", target: "/products?currency=GBP", expectedErr: "currency must be one of COP, EUR, USD"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, tt.target, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.acceptCurrency != "" {
+				req.Header.Set("Accept-Currency", tt.acceptCurrency)
+			}
+			cr, err := parseCurrencyRequest(req)
+			if tt.expectedErr != "" {
+				if err == nil || err.Error() != tt.expectedErr {
+					t.Errorf("unexpected error, expected %q but got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if cr != tt.expected {
+				t.Errorf("unexpected currency: got %+v want %+v", cr, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLoadExchangeRate_Missing(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	// A negotiated currency without a rate falls back to the store currency, an explicit one is an error
+	for _, explicit := range []bool{false, true} {
+		mock.ExpectQuery("FROM exchange_rates").WithArgs("EUR").WillReturnRows(getExchangeRateRows())
+		er, err := loadExchangeRate(db, currencyRequest{currency: "EUR", explicit: explicit})
+		if er != nil {
+			t.Errorf("unexpected exchange rate: %+v", er)
+		}
+		if explicit && (err == nil || err.Error() != "there is no exchange rate for EUR") {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if !explicit && err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	checkMockExpectations(t, mock)
+}
+
+func TestConvertPrices(t *testing.T) {
+	variantPrice := cop(8000)
+	products := []Product{{ID: 1, Price: cop(4000), Variants: []ProductVariant{{ID: 1}, {ID: 2, Price: &variantPrice}}}}
+	er := ExchangeRate{Currency: "USD", Rate: "40", RoundingIncrement: "0.01", RoundingMode: roundHalfUp, UpdatedAt: time.Date(2023, 7, 4, 0, 0, 0, 0, time.UTC)}
+
+	if err := convertPrices(products, er); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	p := products[0]
+	if p.Price != (Money{Amount: 100, Currency: "USD"}) {
+		t.Errorf("unexpected price: %+v", p.Price)
+	}
+	if p.Variants[0].Price != nil || *p.Variants[1].Price != (Money{Amount: 200, Currency: "USD"}) {
+		t.Errorf("unexpected variant prices: %+v", p.Variants)
+	}
+	if p.ExchangeRate == nil || *p.ExchangeRate != er {
+		t.Errorf("unexpected exchange rate: %+v", p.ExchangeRate)
+	}
+	// The variant price of the caller is not changed
+	if variantPrice != cop(8000) {
+		t.Errorf("variant price was modified: %v", variantPrice)
+	}
+}
+
+func TestExchangeRateInputValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		currency    string
+		input       exchangeRateInput
+		expectedErr string
+	}{
+		{name: "Valid", currency: "USD", input: exchangeRateInput{Rate: "4150.25"}, expectedErr: ""},
+		{name: "Five cents down", currency: "EUR", input: exchangeRateInput{Rate: "4500", RoundingIncrement: "0.05", RoundingMode: roundDown}, expectedErr: ""},
+		{name: "Store currency", currency: storeCurrency, input: exchangeRateInput{Rate: "1"}, expectedErr: "the store currency has no exchange rate"},
+		{name: "Unsupported currency", currency: "GBP", input: exchangeRateInput{Rate: "5000"}, expectedErr: "currency must be one of COP, EUR, USD"},
+		{name: "Missing rate", currency: "USD", input: exchangeRateInput{}, expectedErr: "rate must be a positive decimal number"},
+		{name: "Negative rate", currency: "USD", input: exchangeRateInput{Rate: "-1"}, expectedErr: "rate must be a positive decimal number"},
+		{name: "Exponent", currency: "USD", input: exchangeRateInput{Rate: "4e3"}, expectedErr: "rate must be a positive decimal number"},
+		{name: "Increment below the minor unit", currency: "USD", input: exchangeRateInput{Rate: "4000", RoundingIncrement: "0.001"}, expectedErr: "rounding_increment must be a positive multiple of the minor unit of USD"},
+		{name: "Unknown rounding mode", currency: "USD", input: exchangeRateInput{Rate: "4000", RoundingMode: "even"}, expectedErr: "rounding_mode must be half_up, up or down"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := tt.input
+			in.normalize(tt.currency)
+			err := in.validate(tt.currency)
+			if tt.expectedErr == "" && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if tt.expectedErr != "" && (err == nil || err.Error() != tt.expectedErr) {
+				t.Errorf("unexpected error, expected %q but got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestExchangeRateInput_AcceptsNumbers(t *testing.T) {
+	var in exchangeRateInput
+	if err := json.Unmarshal([]byte(`{"rate":4150.25,"rounding_increment":"0.05"}`), &in); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if in.Rate != "4150.25" || in.RoundingIncrement != "0.05" {
+		t.Errorf("unexpected input: %+v", in)
+	}
+}
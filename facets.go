@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// maxPriceBuckets is the maximum number of boundaries accepted in the price_buckets query parameter.
+const maxPriceBuckets = 20
+
+// defaultPriceBuckets are the price bucket boundaries used when price_buckets is not given, in COP.
+var defaultPriceBuckets = []Money{
+	{Amount: 0, Currency: storeCurrency},
+	{Amount: 5000000, Currency: storeCurrency},
+	{Amount: 10000000, Currency: storeCurrency},
+	{Amount: 20000000, Currency: storeCurrency},
+	{Amount: 50000000, Currency: storeCurrency},
+}
+
+// productFacets holds the number of products matching a listing's filters, grouped by category and price.
+type productFacets struct {
+	Categories []categoryFacet    `json:"categories,omitempty"`
+	Price      []priceBucketFacet `json:"price,omitempty"`
+}
+
+// categoryFacet is the number of matching products that belong to a category.
+type categoryFacet struct {
+	Category string `json:"category"`
+	Count    int    `json:"count"`
+}
+
+// priceBucketFacet is the number of matching products with a price in [Min, Max).
+// Min is nil for the bucket below the first boundary and Max is nil for the last bucket.
+type priceBucketFacet struct {
+	Min   *Money `json:"min,omitempty"`
+	Max   *Money `json:"max,omitempty"`
+	Count int    `json:"count"`
+}
+
+// facetRequest holds the facets query parameters of a products listing.
+type facetRequest struct {
+	categories   bool
+	price        bool
+	priceBuckets []Money
+}
+
+// requested reports whether any facet was requested.
+func (fr facetRequest) requested() bool {
+	return fr.categories || fr.price
+}
+
+// parseFacetRequest reads the facets and price_buckets query parameters.
+//
+// facets is a comma separated list with "categories" and/or "price". price_buckets is a comma separated
+// list of increasing amounts that are the boundaries of the price buckets, defaulting to defaultPriceBuckets.
+// It returns a validationError if any of the values is malformed.
+func parseFacetRequest(query url.Values) (facetRequest, error) {
+	fr := facetRequest{priceBuckets: defaultPriceBuckets}
+
+	for _, facet := range strings.Split(query.Get("facets"), ",") {
+		switch strings.TrimSpace(facet) {
+		case "":
+		case "categories":
+			fr.categories = true
+		case "price":
+			fr.price = true
+		default:
+			return fr, validationError{fmt.Sprintf("unknown facet %q: must be categories or price", facet)}
+		}
+	}
+
+	if v := query.Get("price_buckets"); v != "" {
+		bounds := strings.Split(v, ",")
+		if len(bounds) > maxPriceBuckets {
+			return fr, validationError{fmt.Sprintf("price_buckets must have at most %d values", maxPriceBuckets)}
+		}
+		fr.priceBuckets = make([]Money, 0, len(bounds))
+		for i, b := range bounds {
+			m, err := parseMoney(b, storeCurrency)
+			if err != nil || m.Amount < 0 || (i > 0 && m.Amount <= fr.priceBuckets[i-1].Amount) {
+				return fr, validationError{"price_buckets must be a comma separated list of increasing non-negative amounts"}
+			}
+			fr.priceBuckets = append(fr.priceBuckets, m)
+		}
+	}
+
+	return fr, nil
+}
+
+// productFacets counts the products matching filter for every requested facet.
+// The counts ignore pagination, so they describe the whole result set.
+func (ph ProductsHandler) productFacets(filter productFilter, fr facetRequest) (*productFacets, error) {
+	facets := &productFacets{}
+	var err error
+	if fr.categories {
+		facets.Categories, err = ph.categoryFacets(filter)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if fr.price {
+		facets.Price, err = ph.priceFacets(filter, fr.priceBuckets)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return facets, nil
+}
+
+// categoryFacets counts the products matching filter per category, the most common categories first.
+func (ph ProductsHandler) categoryFacets(filter productFilter) ([]categoryFacet, error) {
+	qb := newQueryBuilder("")
+	qb.base = "SELECT category, COUNT(*) FROM " + filter.fromProducts(qb) + ", unnest(categories) category"
+	filter.apply(qb)
+	qb.group("category")
+	qb.order("COUNT(*) DESC", "category ASC")
+	sqlQuery, args := qb.build()
+
+	rows, err := ph.db.Query(sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	facets := []categoryFacet{}
+	for rows.Next() {
+		f := categoryFacet{}
+		if err := rows.Scan(&f.Category, &f.Count); err != nil {
+			return nil, err
+		}
+		facets = append(facets, f)
+	}
+	return facets, rows.Err()
+}
+
+// priceFacets counts the products matching filter per price bucket. Every bucket is returned, even empty ones,
+// so they can be drawn as a histogram.
+func (ph ProductsHandler) priceFacets(filter productFilter, bounds []Money) ([]priceBucketFacet, error) {
+	// width_bucket returns 0 below the first boundary, i for [bounds[i-1], bounds[i]) and len(bounds) above the last one
+	buckets := make([]priceBucketFacet, len(bounds)+1)
+	for i := range bounds {
+		buckets[i].Max = &bounds[i]
+		buckets[i+1].Min = &bounds[i]
+	}
+
+	boundsArray := make(textArray, len(bounds))
+	for i, b := range bounds {
+		boundsArray[i] = b.String()
+	}
+
+	qb := newQueryBuilder("")
+	qb.base = "SELECT width_bucket(price, " + qb.bind(boundsArray) + "::numeric[]) bucket, COUNT(*) FROM " + filter.fromProducts(qb)
+	filter.apply(qb)
+	qb.group("bucket")
+	sqlQuery, args := qb.build()
+
+	rows, err := ph.db.Query(sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var bucket, count int
+		if err := rows.Scan(&bucket, &count); err != nil {
+			return nil, err
+		}
+		if bucket < 0 || bucket >= len(buckets) {
+			return nil, fmt.Errorf("unexpected price bucket %d", bucket)
+		}
+		buckets[bucket].Count = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// The bucket below the first boundary only matters if the boundaries don't start at zero
+	if bounds[0].Amount == 0 && buckets[0].Count == 0 {
+		buckets = buckets[1:]
+	}
+	return buckets, nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestParseFacetRequest(t *testing.T) {
+	tests := []struct {
+		name        string
+		query       url.Values
+		expected    facetRequest
+		expectedErr string
+	}{
+		{
+			name:     "No facets",
+			query:    url.Values{},
+			expected: facetRequest{priceBuckets: defaultPriceBuckets},
+		},
+		{
+			name:     "Both facets with custom buckets",
+			query:    url.Values{"facets": {"categories, price"}, "price_buckets": {"0,50000,100000.50"}},
+			expected: facetRequest{categories: true, price: true, priceBuckets: []Money{cop(0), cop(5000000), cop(10000050)}},
+		},
+		{
+			name:        "Unknown facet",
+			query:       url.Values{"facets": {"color"}},
+			expectedErr: `unknown facet "color": must be categories or price`,
+		},
+		{
+			name:        "Buckets not increasing",
+			query:       url.Values{"facets": {"price"}, "price_buckets": {"100,50"}},
+			expectedErr: "price_buckets must be a comma separated list of increasing non-negative amounts",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fr, err := parseFacetRequest(tt.query)
+			if tt.expectedErr != "" {
+				if err == nil || err.Error() != tt.expectedErr {
+					t.Errorf("unexpected error, expected %q but got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(fr, tt.expected) {
+				t.Errorf("unexpected facet request: got %+v want %+v", fr, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGetProducts_Facets(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	expectedProducts := getExpectedProducts()
+	expectCategoryTree(mock, "cat1")
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT "+productColumns+" FROM products WHERE categories && $1 AND "+liveCondition+" ORDER BY date_added DESC, id DESC LIMIT $2")).
+		WithArgs(textArray{"cat1"}, defaultPageLimit+1).
+		WillReturnRows(getMockRows(expectedProducts))
+	expectVariants(mock, expectedProducts)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT category, COUNT(*) FROM products, unnest(categories) category WHERE categories && $1 AND " + liveCondition + " GROUP BY category ORDER BY COUNT(*) DESC, category ASC")).
+		WithArgs(textArray{"cat1"}).
+		WillReturnRows(sqlmock.NewRows([]string{"category", "count"}).AddRow("cat1", 2).AddRow("cat2", 1).AddRow("cat3", 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT width_bucket(price, $1::numeric[]) bucket, COUNT(*) FROM products WHERE categories && $2 AND "+liveCondition+" GROUP BY bucket")).
+		WithArgs(textArray{"0.00", "15.00"}, textArray{"cat1"}).
+		WillReturnRows(sqlmock.NewRows([]string{"bucket", "count"}).AddRow(1, 1).AddRow(2, 1))
+
+	rr := makeRequest(t, db, "/products?categories=cat1&facets=categories,price&price_buckets=0,15")
+
+	bounds := []Money{cop(0), cop(1500)}
+	expected := productPage{
+		Products: expectedProducts,
+		Facets: &productFacets{
+			Categories: []categoryFacet{{Category: "cat1", Count: 2}, {Category: "cat2", Count: 1}, {Category: "cat3", Count: 1}},
+			Price:      []priceBucketFacet{{Min: &bounds[0], Max: &bounds[1], Count: 1}, {Min: &bounds[1], Count: 1}},
+		},
+	}
+	checkResponseCode(t, rr.Code, http.StatusOK)
+	checkResponseBody(t, rr.Body.String(), "", expected)
+	checkMockExpectations(t, mock)
+}
+
+func TestGetProducts_InvalidFacets(t *testing.T) {
+	rr := makeRequest(t, nil, "/products?facets=glaze")
+
+	checkResponseCode(t, rr.Code, http.StatusBadRequest)
+	checkResponseBody(t, rr.Body.String(), "unknown facet \"glaze\": must be categories or price\n", nil)
+}
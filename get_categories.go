@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// categoriesQuery selects every category with the number of live products that belong to it or to any of its descendants.
+const categoriesQuery = `WITH RECURSIVE tree AS (
+  SELECT id AS root_id, id, slug FROM categories
+  UNION
+  SELECT tree.root_id, c.id, c.slug FROM categories c JOIN tree ON c.parent_id = tree.id
+)
+SELECT c.id, c.slug, c.name, c.parent_id, c.sort_order,
+  (SELECT COUNT(*) FROM products WHERE products.categories && ARRAY(SELECT slug FROM tree WHERE tree.root_id = c.id) AND ` + liveCondition + `)
+FROM categories c
+ORDER BY c.sort_order, c.name`
+
+// getCategories retrieves the categories from the database and sends them as a JSON response.
+//
+// By default the categories are nested under their parent in the children field. With the query
+// parameter form=flat they are returned as a single list, where each category refers to its parent by parent_id.
+// Every category has the number of products visible to shoppers that belong to it or to any of its descendants.
+// Names are translated to the locale of the lang query parameter or the Accept-Language header (see parseLocale).
+//
+// If the form or lang query parameters are not valid, it returns a 400 Bad Request.
+// If there is an internal server error, it returns a 500 Internal Server Error.
+func (ch CategoriesHandler) getCategories(w http.ResponseWriter, r *http.Request) {
+	form := r.URL.Query().Get("form")
+	if form != "" && form != "tree" && form != "flat" {
+		http.Error(w, "form must be tree or flat", http.StatusBadRequest)
+		return
+	}
+	locale, err := parseLocale(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Execute query
+	rows, err := ch.db.Query(categoriesQuery)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	// Collect categories
+	categories := []Category{}
+	for rows.Next() {
+		c := Category{}
+		err := rows.Scan(&c.ID, &c.Slug, &c.Name, &c.ParentID, &c.SortOrder, &c.ProductCount)
+		if err != nil {
+			log.Println(err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		categories = append(categories, c)
+	}
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Translate the names
+	names, servedLocale, err := loadCategoryNames(ch.db, locale)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	for i := range categories {
+		if name, ok := names[categories[i].Slug]; ok {
+			categories[i].Name = name
+		}
+	}
+
+	if form != "flat" {
+		categories = buildCategoryTree(categories)
+	}
+
+	// Encode and send response
+	setLocaleHeaders(w, servedLocale)
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(categories)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
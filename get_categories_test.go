@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// getMockCategoryRows returns the rows of the categories query for a small hierarchy.
+func getMockCategoryRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "slug", "name", "parent_id", "sort_order", "count"}).
+		AddRow(1, "tableware", "Tableware", nil, 0, 3).
+		AddRow(2, "mugs", "Mugs", 1, 0, 2).
+		AddRow(3, "decor", "Decor", nil, 1, 0)
+}
+
+// serveCategoriesRequest calls getCategories with the given target and returns the recorded response.
+func serveCategoriesRequest(t *testing.T, ch CategoriesHandler, target string) *httptest.ResponseRecorder {
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		t.Fatalf("Failed to create HTTP request: %v", err)
+	}
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(ch.getCategories).ServeHTTP(rr, req)
+	return rr
+}
+
+func TestGetCategories(t *testing.T) {
+	parentID := 1
+	tableware := Category{ID: 1, Slug: "tableware", Name: "Tableware", ProductCount: 3}
+	mugs := Category{ID: 2, Slug: "mugs", Name: "Mugs", ParentID: &parentID, ProductCount: 2}
+	decor := Category{ID: 3, Slug: "decor", Name: "Decor", SortOrder: 1}
+
+	treeTableware := tableware
+	treeTableware.Children = []Category{mugs}
+
+	tests := []struct {
+		name     string
+		target   string
+		expected []Category
+	}{
+		{name: "Tree", target: "/categories", expected: []Category{treeTableware, decor}},
+		{name: "Flat", target: "/categories?form=flat", expected: []Category{tableware, mugs, decor}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := getMockDB(t)
+			defer db.Close()
+
+			mock.ExpectQuery(regexp.QuoteMeta(categoriesQuery)).WillReturnRows(getMockCategoryRows())
+			rr := serveCategoriesRequest(t, CategoriesHandler{db: db}, tt.target)
+
+			checkResponseCode(t, rr.Code, http.StatusOK)
+			checkResponseBody(t, rr.Body.String(), "", tt.expected)
+			checkMockExpectations(t, mock)
+		})
+	}
+}
+
+func TestGetCategories_Translated(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta(categoriesQuery)).WillReturnRows(getMockCategoryRows())
+	expectCategoryNames(mock, "en", map[string]string{"mugs": "Coffee mugs"})
+	rr := serveCategoriesRequest(t, CategoriesHandler{db: db}, "/categories?form=flat&lang=en")
+
+	// Decor has no translation and keeps its name
+	parentID := 1
+	expected := []Category{
+		{ID: 1, Slug: "tableware", Name: "Tableware", ProductCount: 3},
+		{ID: 2, Slug: "mugs", Name: "Coffee mugs", ParentID: &parentID, ProductCount: 2},
+		{ID: 3, Slug: "decor", Name: "Decor", SortOrder: 1},
+	}
+	checkResponseCode(t, rr.Code, http.StatusOK)
+	checkResponseBody(t, rr.Body.String(), "", expected)
+	if language := rr.Header().Get("Content-Language"); language != "en" {
+		t.Errorf("unexpected Content-Language: got %q want %q", language, "en")
+	}
+	checkMockExpectations(t, mock)
+}
+
+func TestGetCategories_Untranslated(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	// Without translations to the locale the names are served in the default locale
+	mock.ExpectQuery(regexp.QuoteMeta(categoriesQuery)).WillReturnRows(getMockCategoryRows())
+	expectCategoryNames(mock, "en", nil)
+	rr := serveCategoriesRequest(t, CategoriesHandler{db: db}, "/categories?form=flat&lang=en")
+
+	checkResponseCode(t, rr.Code, http.StatusOK)
+	if language := rr.Header().Get("Content-Language"); language != defaultLocale {
+		t.Errorf("unexpected Content-Language: got %q want %q", language, defaultLocale)
+	}
+	checkMockExpectations(t, mock)
+}
+
+func TestGetCategories_InvalidForm(t *testing.T) {
+	rr := serveCategoriesRequest(t, CategoriesHandler{db: nil}, "/categories?form=list")
+
+	checkResponseCode(t, rr.Code, http.StatusBadRequest)
+	checkResponseBody(t, rr.Body.String(), "form must be tree or flat\n", nil)
+}
+
+func TestGetCategories_DBError(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta(categoriesQuery)).WillReturnError(errors.New("some error"))
+	rr := serveCategoriesRequest(t, CategoriesHandler{db: db}, "/categories")
+
+	checkResponseCode(t, rr.Code, http.StatusInternalServerError)
+	checkResponseBody(t, rr.Body.String(), "Internal server error\n", nil)
+	checkMockExpectations(t, mock)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// getExchangeRates retrieves every exchange rate from the database and sends them as a JSON response,
+// sorted by currency, so clients can offer the currencies prices can be shown in.
+//
+// If there is an internal server error, it returns a 500 Internal Server Error.
+func (eh ExchangeRatesHandler) getExchangeRates(w http.ResponseWriter, r *http.Request) {
+	// Execute query
+	rows, err := eh.db.Query("SELECT " + exchangeRateColumns + " FROM exchange_rates ORDER BY currency")
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	// Collect exchange rates
+	rates := []ExchangeRate{}
+	for rows.Next() {
+		er := ExchangeRate{}
+		if err := scanExchangeRate(rows, &er); err != nil {
+			log.Println(err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		rates = append(rates, er)
+	}
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Encode and send response
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(rates)
+	if err != nil {
+		log.Println(err)
+	}
+}
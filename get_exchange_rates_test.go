@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestGetExchangeRates(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	updatedAt := time.Date(2023, 7, 4, 16, 0, 0, 0, time.UTC)
+	rows := getExchangeRateRows(
+		ExchangeRate{Currency: "EUR", Rate: "4500.00000000", RoundingIncrement: "0.0500", RoundingMode: roundDown, UpdatedAt: updatedAt},
+		ExchangeRate{Currency: "USD", Rate: "4150.25000000", RoundingIncrement: "1.0000", RoundingMode: roundHalfUp, UpdatedAt: updatedAt},
+	)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT " + exchangeRateColumns + " FROM exchange_rates ORDER BY currency")).WillReturnRows(rows)
+
+	eh := ExchangeRatesHandler{db: db}
+	rr := serveProductRequest(t, eh.getExchangeRates, http.MethodGet, "/exchange_rates", "", nil)
+
+	// The trailing zeros of the NUMERIC columns are trimmed
+	expected := []ExchangeRate{
+		{Currency: "EUR", Rate: "4500", RoundingIncrement: "0.05", RoundingMode: roundDown, UpdatedAt: updatedAt},
+		{Currency: "USD", Rate: "4150.25", RoundingIncrement: "1", RoundingMode: roundHalfUp, UpdatedAt: updatedAt},
+	}
+	checkResponseCode(t, rr.Code, http.StatusOK)
+	checkResponseBody(t, rr.Body.String(), "", expected)
+	checkMockExpectations(t, mock)
+}
+
+func TestGetExchangeRates_DBError(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	mock.ExpectQuery("FROM exchange_rates").WillReturnError(errors.New("some error"))
+
+	eh := ExchangeRatesHandler{db: db}
+	rr := serveProductRequest(t, eh.getExchangeRates, http.MethodGet, "/exchange_rates", "", nil)
+
+	checkResponseCode(t, rr.Code, http.StatusInternalServerError)
+	checkResponseBody(t, rr.Body.String(), "Internal server error\n", nil)
+	checkMockExpectations(t, mock)
+}
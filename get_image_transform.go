@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// getImageTransform serves a resized version of a stored JPEG or PNG image.
+//
+// It expects the name of the original image as a URL parameter, and the size, fit mode and format as
+// query parameters (see parseImageTransform). Transformed images are cached in the image storage, so
+// every variant is only computed once. Images can be cached like the original ones, and errors are not cached.
+// If the parameters are not valid or the image is not a JPEG or PNG, it returns an HTTP 400 Bad Request error.
+// If the image does not exist, it returns an HTTP 404 Not Found error.
+// If there is an error while transforming the image, it returns an HTTP 500 Internal Server Error.
+func (ih ImagesHandler) getImageTransform(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	t, err := parseImageTransform(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	format := t.outputFormat(name)
+	variant := t.variantName(name)
+
+	// Serve the cached variant if it was already computed
+	cached, modTime, err := ih.storage.Open(variant)
+	if err == nil {
+		defer cached.Close()
+		setImageVariantHeaders(w)
+		http.ServeContent(w, r, path.Base(variant), modTime, cached)
+		return
+	} else if err != errImageNotFound {
+		log.Println(err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	original, _, err := ih.storage.Open(name)
+	if err == errImageNotFound {
+		http.Error(w, "Image not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Println(err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer original.Close()
+
+	content, err := t.apply(original, format)
+	var vErr validationError
+	if errors.As(err, &vErr) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		log.Println(err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// A failure to cache the variant doesn't prevent serving it
+	if err := ih.storage.Save(variant, bytes.NewReader(content)); err != nil {
+		log.Println(err)
+	}
+
+	setImageVariantHeaders(w)
+	http.ServeContent(w, r, path.Base(variant), time.Now(), bytes.NewReader(content))
+}
+
+// setImageVariantHeaders sets the headers of a transformed image that is served. Errors are not cached, so they are
+// sent without them.
+func setImageVariantHeaders(w http.ResponseWriter) {
+	w.Header().Set("Cache-Control", imageCacheControl)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// serveImageTransformRequest calls getImageTransform for the named image and returns the recorded response.
+func serveImageTransformRequest(ih ImagesHandler, name, query string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/images/"+name+"/transform?"+query, nil)
+	req = mux.SetURLVars(req, map[string]string{"name": name})
+	rr := httptest.NewRecorder()
+	ih.getImageTransform(rr, req)
+	return rr
+}
+
+func TestGetImageTransform(t *testing.T) {
+	storage, err := newLocalImageStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Save("abc.png", bytes.NewReader(getPNG(t, 400, 200))); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Save("text.png", bytes.NewReader([]byte("not an image"))); err != nil {
+		t.Fatal(err)
+	}
+	ih := ImagesHandler{storage: storage}
+
+	rr := serveImageTransformRequest(ih, "abc.png", "w=100&h=100&fit=cover")
+	checkResponseCode(t, rr.Code, http.StatusOK)
+	if got := rr.Header().Get("Content-Type"); got != "image/png" {
+		t.Errorf("unexpected content type: %v", got)
+	}
+	if got := rr.Header().Get("Cache-Control"); got != imageCacheControl {
+		t.Errorf("unexpected cache control: %v", got)
+	}
+	cfg, _, err := image.DecodeConfig(rr.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Width != 100 || cfg.Height != 100 {
+		t.Errorf("expected a 100x100 image, got %dx%d", cfg.Width, cfg.Height)
+	}
+
+	// The variant is cached in the storage and served from there
+	f, _, err := storage.Open("variants/abc/100x100-cover.png")
+	if err != nil {
+		t.Fatalf("expected the variant to be cached: %v", err)
+	}
+	f.Close()
+	rr = serveImageTransformRequest(ih, "abc.png", "w=100&h=100&fit=cover")
+	checkResponseCode(t, rr.Code, http.StatusOK)
+
+	rr = serveImageTransformRequest(ih, "abc.png", "w=50&format=jpeg")
+	checkResponseCode(t, rr.Code, http.StatusOK)
+	if got := rr.Header().Get("Content-Type"); got != "image/jpeg" {
+		t.Errorf("unexpected content type: %v", got)
+	}
+
+	rr = serveImageTransformRequest(ih, "abc.png", "fit=cover")
+	checkResponseCode(t, rr.Code, http.StatusBadRequest)
+	checkResponseBody(t, rr.Body.String(), "at least one of w and h is required\n", nil)
+
+	rr = serveImageTransformRequest(ih, "text.png", "w=50")
+	checkResponseCode(t, rr.Code, http.StatusBadRequest)
+	checkResponseBody(t, rr.Body.String(), "only JPEG and PNG images can be transformed\n", nil)
+
+	rr = serveImageTransformRequest(ih, "missing.png", "w=50")
+	checkResponseCode(t, rr.Code, http.StatusNotFound)
+	checkResponseBody(t, rr.Body.String(), "Image not found\n", nil)
+	// Errors are not cached, the image may be uploaded later
+	if got := rr.Header().Get("Cache-Control"); got != "" {
+		t.Errorf("unexpected cache control of an error: %v", got)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"path"
+
+	"github.com/gorilla/mux"
+)
+
+// imageCacheControl lets browsers and proxies keep images for a year: stored images are named after
+// the hash of their content, so the content behind a name never changes.
+const imageCacheControl = "public, max-age=31536000, immutable"
+
+// getImage serves a stored image file.
+//
+// It expects the name of the image as a URL parameter, as listed in Product.Images.
+// Range and conditional requests (If-Modified-Since) are supported, and the response can be cached.
+// If the image does not exist, it returns an HTTP 404 Not Found error.
+// If there is an error while reading the image, it returns an HTTP 500 Internal Server Error.
+func (ih ImagesHandler) getImage(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	f, modTime, err := ih.storage.Open(name)
+	if err == errImageNotFound {
+		http.Error(w, "Image not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Println(err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Cache-Control", imageCacheControl)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	// ServeContent sets the Content-Type from the extension and handles Range and If-Modified-Since
+	http.ServeContent(w, r, path.Base(name), modTime, f)
+}
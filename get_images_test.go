@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// serveImageRequest calls getImage for the named image and returns the recorded response.
+func serveImageRequest(ih ImagesHandler, name string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/images/"+name, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req = mux.SetURLVars(req, map[string]string{"name": name})
+	rr := httptest.NewRecorder()
+	ih.getImage(rr, req)
+	return rr
+}
+
+func TestGetImage(t *testing.T) {
+	storage, err := newLocalImageStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Save("abc.png", strings.NewReader("png data")); err != nil {
+		t.Fatal(err)
+	}
+	ih := ImagesHandler{storage: storage}
+
+	rr := serveImageRequest(ih, "abc.png", nil)
+	checkResponseCode(t, rr.Code, http.StatusOK)
+	checkResponseBody(t, rr.Body.String(), "png data", nil)
+	if got := rr.Header().Get("Content-Type"); got != "image/png" {
+		t.Errorf("unexpected content type: %v", got)
+	}
+	if got := rr.Header().Get("Cache-Control"); got != imageCacheControl {
+		t.Errorf("unexpected cache control: %v", got)
+	}
+
+	// Conditional requests are answered without a body
+	rr = serveImageRequest(ih, "abc.png", http.Header{"If-Modified-Since": {rr.Header().Get("Last-Modified")}})
+	checkResponseCode(t, rr.Code, http.StatusNotModified)
+
+	rr = serveImageRequest(ih, "missing.png", nil)
+	checkResponseCode(t, rr.Code, http.StatusNotFound)
+	checkResponseBody(t, rr.Body.String(), "Image not found\n", nil)
+}
@@ -2,17 +2,30 @@ package main
 
 import (
 	"database/sql"
-	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 )
 
-// getProduct retrieves a single product from the database by ID and returns it as a JSON response.
+// getProduct retrieves a single product from the database by ID, with its variants, and returns it as a JSON response.
 //
 // It expects the ID of the product to be provided as a URL parameter. If the ID is not a valid integer, it returns an HTTP 400 Bad Request error.
+// The fields and include query parameters select the returned fields and related resources (see parseFieldSelection).
+// The response has an ETag and a Last-Modified date, and conditional requests for an unchanged product
+// are answered with an HTTP 304 Not Modified (see writeCacheableJSON). Responses are cached in Redis
+// until the product changes (see productCache).
+// Products that are not visible to shoppers (see liveCondition) are only returned to admins.
+// Prices can be converted to another currency with the currency query parameter or the Accept-Currency header
+// (see parseCurrencyRequest), and then the product includes the exchange rate that was used.
+// The name and description are translated to the locale of the lang query parameter or the Accept-Language header
+// (see parseLocale) when the product has a translation, and the Content-Language header has the locale they are in.
+// Admins can get the version of the product at a past time with the as_of query parameter, rebuilt from its
+// history (see productVersionQuery). Past versions have no variants, and their Last-Modified date is the time
+// of the change that made them. If a request that is not from an admin has as_of, it returns an HTTP 403 Forbidden error.
 // If the product is not found in the database, it returns an HTTP 404 Not Found error.
 // If there is an error while querying the database, it returns an HTTP 500 Internal Server Error.
 //
@@ -27,15 +40,83 @@ func (ph ProductsHandler) getProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ph.serveProduct(w, r, id)
+}
+
+// serveProduct writes the product with the given ID as the response of getProduct or getProductBySlug.
+func (ph ProductsHandler) serveProduct(w http.ResponseWriter, r *http.Request, id int) {
+	fields, err := parseFieldSelection(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	currency, err := parseCurrencyRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Add("Vary", "Accept-Currency")
+	locale, err := parseLocale(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	isAdmin := ph.admin.isAdmin(r)
+	asOf, err := parseAsOf(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !asOf.IsZero() && !isAdmin {
+		http.Error(w, "Only admins can get past versions of a product", http.StatusForbidden)
+		return
+	}
+
+	// Serve the product from the cache if it's there. Only full products for shoppers, in the store currency
+	// and the default locale, are cached.
+	cacheable := !fields.sparse() && !isAdmin && !currency.converted() && locale == defaultLocale
+	cacheKey := productKey(id)
+	if cacheable {
+		if cached, ok := ph.cache.get(r.Context(), cacheKey); ok {
+			setLocaleHeaders(w, locale)
+			writeCacheableJSON(w, r, cached.Body, cached.LastModified)
+			return
+		}
+	}
+
+	// Load the exchange rate of the requested currency
+	rate, err := loadExchangeRate(ph.db, currency)
+	var vErr validationError
+	if errors.As(err, &vErr) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		log.Println(err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
 	// Build SQL query
-	sqlQuery := "SELECT id, name, price, description, categories, images, referenced_name, date_added FROM products WHERE id = $1"
+	columns, dest := fields.columns("")
+	sqlQuery := "SELECT " + columns + ", updated_at FROM products WHERE id = $1"
+	args := []interface{}{id}
+	if !isAdmin {
+		sqlQuery += " AND " + liveCondition
+	}
+	if !asOf.IsZero() {
+		sqlQuery = productVersionQuery(columns)
+		args = append(args, asOf)
+	}
 
 	// Execute query
-	row := ph.db.QueryRow(sqlQuery, id)
+	row := ph.db.QueryRow(sqlQuery, args...)
 
 	// Scan product
 	p := Product{}
-	err = row.Scan(&p.ID, &p.Name, &p.Price, &p.Description, (*textArray)(&p.Categories), (*textArray)(&p.Images), &p.ReferencedName, &p.DateAdded)
+	var updatedAt time.Time
+	err = row.Scan(append(dest(&p), &updatedAt)...)
 	if err == sql.ErrNoRows {
 		http.Error(w, "Product not found", http.StatusNotFound)
 		return
@@ -45,12 +126,60 @@ func (ph ProductsHandler) getProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Encode and send response
-	w.Header().Set("Content-Type", "application/json")
-	err = json.NewEncoder(w).Encode(p)
+	// Load the product variants
+	products := []Product{p}
+	if fields.variants() && asOf.IsZero() {
+		err = loadVariants(ph.db, products)
+		if err != nil {
+			log.Println(err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Translate the name and description
+	servedLocale, err := translateProducts(ph.db, products, locale)
 	if err != nil {
 		log.Println(err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
+
+	// Convert the prices, the response changes when either the product or the rate do
+	if rate != nil {
+		err = convertPrices(products, *rate)
+		if err != nil {
+			log.Println(err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if rate.UpdatedAt.After(updatedAt) {
+			updatedAt = rate.UpdatedAt
+		}
+	}
+
+	// Keep only the selected fields and embed the included resources
+	var out interface{} = products[0]
+	if fields.sparse() {
+		rendered, err := renderProducts(ph.db, fields, products, locale)
+		if err != nil {
+			log.Println(err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		out = rendered[0]
+	}
+
+	// Encode, cache and send response, or a 304 Not Modified if the client has it cached
+	body, err := encodeJSON(out)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if cacheable {
+		ph.cache.set(r.Context(), cacheKey, cachedResponse{Body: body, LastModified: updatedAt}, liveTTL(p, productCacheTTL))
+	}
+	setLocaleHeaders(w, servedLocale)
+	writeCacheableJSON(w, r, body, updatedAt)
 }
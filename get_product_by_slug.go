@@ -0,0 +1,57 @@
+package main
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/gorilla/mux"
+)
+
+// productBySlugQuery returns the query that finds the product that has a slug now or had it before, and its current
+// slug. With liveOnly, products that are not live are not found.
+func productBySlugQuery(liveOnly bool) string {
+	live := ""
+	if liveOnly {
+		live = " AND " + liveCondition
+	}
+	return "SELECT id, slug FROM products WHERE slug = $1" + live + " " +
+		"UNION ALL SELECT products.id, products.slug FROM product_slug_history JOIN products ON products.id = product_slug_history.product_id " +
+		"WHERE product_slug_history.slug = $1" + live + " LIMIT 1"
+}
+
+// getProductBySlug retrieves a single product by its slug and returns it as a JSON response, like getProduct.
+//
+// It expects the slug of the product to be provided as a URL parameter. Old slugs of a product are answered
+// with an HTTP 301 Moved Permanently to the URL of its current slug, keeping the query parameters.
+// If no product has or had the slug, or it is not live and the request is not from an admin, it returns an
+// HTTP 404 Not Found error.
+// If there is an error while querying the database, it returns an HTTP 500 Internal Server Error.
+func (ph ProductsHandler) getProductBySlug(w http.ResponseWriter, r *http.Request) {
+	slug := mux.Vars(r)["slug"]
+
+	var id int
+	var current string
+	err := ph.db.QueryRow(productBySlugQuery(!ph.admin.isAdmin(r)), slug).Scan(&id, &current)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Println(err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Old slugs redirect to the current one
+	if current != slug {
+		target := "/products/by-slug/" + url.PathEscape(current)
+		if r.URL.RawQuery != "" {
+			target += "?" + r.URL.RawQuery
+		}
+		http.Redirect(w, r, target, http.StatusMovedPermanently)
+		return
+	}
+
+	ph.serveProduct(w, r, id)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestGetProductBySlug(t *testing.T) {
+	p := getExpectedProducts()[0]
+
+	tests := []struct {
+		name             string
+		target           string
+		slug             string
+		setup            func(mock sqlmock.Sqlmock)
+		expectedStatus   int
+		expectedLocation string
+		expectedBody     string
+	}{
+		{
+			name:   "Current slug",
+			target: "/products/by-slug/product-a",
+			slug:   "product-a",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(productBySlugQuery(true))).WithArgs("product-a").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "slug"}).AddRow(1, "product-a"))
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT " + productColumns + ", updated_at FROM products WHERE id = $1 AND " + liveCondition)).
+					WithArgs(1).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "name", "slug", "price", "description", "categories", "images", "referenced_name", "date_added", "status", "publish_at", "unpublish_at", "average_rating", "review_count", "stock_quantity", "updated_at"}).
+						AddRow(p.ID, p.Name, p.Slug, p.Price.String(), p.Description, sliceToPostgreSQLArray(p.Categories), sliceToPostgreSQLArray(p.Images), p.ReferencedName, p.DateAdded,
+							p.Status, nil, nil, p.AverageRating, p.ReviewCount, p.AvailableQuantity, p.DateAdded))
+				expectVariants(mock, []Product{p})
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody: func() string {
+				body, _ := marshalLine(p)
+				return body
+			}(),
+		},
+		{
+			name:   "Old slug",
+			target: "/products/by-slug/producto-a?fields=name",
+			slug:   "producto-a",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(productBySlugQuery(true))).WithArgs("producto-a").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "slug"}).AddRow(1, "product-a"))
+			},
+			expectedStatus:   http.StatusMovedPermanently,
+			expectedLocation: "/products/by-slug/product-a?fields=name",
+		},
+		{
+			name:   "Unknown slug",
+			target: "/products/by-slug/nothing",
+			slug:   "nothing",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(productBySlugQuery(true))).WithArgs("nothing").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "slug"}))
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   "Product not found\n",
+		},
+		{
+			name:   "Database error",
+			target: "/products/by-slug/product-a",
+			slug:   "product-a",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(productBySlugQuery(true))).WillReturnError(errors.New("some error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   "Internal server error\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := getMockDB(t)
+			defer db.Close()
+			tt.setup(mock)
+
+			ph := ProductsHandler{db: db}
+			rr := serveProductRequest(t, ph.getProductBySlug, http.MethodGet, tt.target, "", map[string]string{"slug": tt.slug})
+
+			checkResponseCode(t, rr.Code, tt.expectedStatus)
+			if location := rr.Header().Get("Location"); location != tt.expectedLocation {
+				t.Errorf("handler returned wrong location: got %v want %v", location, tt.expectedLocation)
+			}
+			if tt.expectedStatus != http.StatusMovedPermanently {
+				checkResponseBody(t, rr.Body.String(), tt.expectedBody, nil)
+			}
+			checkMockExpectations(t, mock)
+		})
+	}
+}
+
+func TestGetProductBySlug_Admin(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	// Admins are redirected to the current slug of products that are not live
+	mock.ExpectQuery(regexp.QuoteMeta(productBySlugQuery(false))).WithArgs("producto-a").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "slug"}).AddRow(1, "product-a"))
+
+	ph := ProductsHandler{db: db, admin: adminAuth{token: "secret"}}
+	rr := serveAdminRequest(t, ph, ph.getProductBySlug, "/products/by-slug/producto-a", map[string]string{"slug": "producto-a"})
+
+	checkResponseCode(t, rr.Code, http.StatusMovedPermanently)
+	if location := rr.Header().Get("Location"); location != "/products/by-slug/product-a" {
+		t.Errorf("handler returned wrong location: got %v want %v", location, "/products/by-slug/product-a")
+	}
+	checkMockExpectations(t, mock)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// getProductHistory returns a page of the changes to a product as a JSON response, the newest first.
+//
+// It expects the ID of the product as a URL parameter. Every change has the values of the product before and after
+// it, the columns that changed and the actor that made it (see ProductChange). The history of deleted products is
+// kept. The limit and cursor query parameters page through the changes like in the products listing, and the field
+// query parameter lists only the changes to one column, e.g. field=price (see parseHistoryPageRequest).
+// If the ID or the query parameters are not valid, it returns an HTTP 400 Bad Request error.
+// If the product has no history, it returns an HTTP 404 Not Found error.
+// If there is an error while querying the database, it returns an HTTP 500 Internal Server Error.
+func (ph ProductsHandler) getProductHistory(w http.ResponseWriter, r *http.Request) {
+	// Extract product ID from URL parameter
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+
+	pr, err := parseHistoryPageRequest(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	qb := newQueryBuilder("SELECT " + productChangeColumns + " FROM product_history")
+	qb.where("product_id = ?", id)
+	pr.apply(qb)
+	sqlQuery, args := qb.build()
+	rows, err := ph.db.Query(sqlQuery, args...)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	changes := []ProductChange{}
+	for rows.Next() {
+		c := ProductChange{}
+		if err := scanProductChange(rows, &c); err != nil {
+			log.Println(err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		changes = append(changes, c)
+	}
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Every product has a history since it was created, so the first page is only empty for unknown products
+	if len(changes) == 0 && pr.cursor == nil && pr.field == "" {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	}
+
+	// Encode and send response, or a 304 Not Modified if the client has it cached
+	body, err := encodeJSON(pr.paginate(changes))
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	writeCacheableJSON(w, r, body, time.Time{})
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestGetProductHistory_Success(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	changedAt := time.Date(2023, 7, 18, 10, 0, 0, 0, time.UTC)
+	changes := []ProductChange{
+		{ID: 3, ProductID: 1, Operation: historyUpdate, OldValues: json.RawMessage(`{"id": 1, "price": 35000.00}`), NewValues: json.RawMessage(`{"id": 1, "price": 38000.00}`),
+			Changed: []string{"price"}, Actor: "ana", ChangedAt: changedAt.Add(time.Hour)},
+		{ID: 2, ProductID: 1, Operation: historyCreate, NewValues: json.RawMessage(`{"id": 1, "price": 35000.00}`),
+			Changed: []string{"id", "price"}, Actor: "admin", ChangedAt: changedAt},
+	}
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT "+productChangeColumns+" FROM product_history WHERE product_id = $1 "+
+		"AND old_values -> $2 IS DISTINCT FROM new_values -> $3 ORDER BY changed_at DESC, id DESC LIMIT $4")).
+		WithArgs(1, "price", "price", 2).
+		WillReturnRows(getProductChangeRows(changes...))
+
+	ph := ProductsHandler{db: db}
+	rr := serveProductRequest(t, ph.getProductHistory, http.MethodGet, "/products/1/history?field=price&limit=1", "", map[string]string{"id": "1"})
+
+	checkResponseCode(t, rr.Code, http.StatusOK)
+	nextCursor := productCursor{Order: "history", Value: "2023-07-18T11:00:00Z", ID: 3}.encode()
+	expectedBody, _ := marshalLine(historyPage{Changes: changes[:1], NextCursor: nextCursor})
+	checkResponseBody(t, rr.Body.String(), expectedBody, nil)
+	checkMockExpectations(t, mock)
+}
+
+func TestGetProductHistory_Errors(t *testing.T) {
+	tests := []struct {
+		name           string
+		id             string
+		target         string
+		setup          func(mock sqlmock.Sqlmock)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{name: "Invalid ID", id: "a", target: "/products/a/history", setup: func(mock sqlmock.Sqlmock) {}, expectedStatus: http.StatusBadRequest, expectedBody: "Invalid product ID\n"},
+		{name: "Invalid field", id: "1", target: "/products/1/history?field=color", setup: func(mock sqlmock.Sqlmock) {}, expectedStatus: http.StatusBadRequest, expectedBody: "unknown field \"color\"\n"},
+		{
+			name:   "Unknown product",
+			id:     "9",
+			target: "/products/9/history",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SELECT "+productChangeColumns).WithArgs(9, defaultHistoryLimit+1).WillReturnRows(getProductChangeRows())
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   "Product not found\n",
+		},
+		{
+			name:   "Database error",
+			id:     "1",
+			target: "/products/1/history",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SELECT " + productChangeColumns).WillReturnError(errors.New("some error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   "Internal server error\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := getMockDB(t)
+			defer db.Close()
+			tt.setup(mock)
+
+			ph := ProductsHandler{db: db}
+			rr := serveProductRequest(t, ph.getProductHistory, http.MethodGet, tt.target, "", map[string]string{"id": tt.id})
+
+			checkResponseCode(t, rr.Code, tt.expectedStatus)
+			checkResponseBody(t, rr.Body.String(), tt.expectedBody, nil)
+			checkMockExpectations(t, mock)
+		})
+	}
+}
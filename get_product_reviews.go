@@ -0,0 +1,87 @@
+package main
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// getProductReviews returns a page of the reviews of a product as a JSON response, the newest first.
+//
+// It expects the ID of the product as a URL parameter. Only approved reviews are listed, admins can list the
+// pending or rejected ones with the status query parameter. The limit and cursor query parameters page through
+// the reviews like in the products listing (see parseReviewPageRequest).
+// If the ID or the query parameters are not valid, it returns an HTTP 400 Bad Request error.
+// If the product is not found, or it is not live and the request is not from an admin, it returns an HTTP 404 Not Found error.
+// If there is an error while querying the database, it returns an HTTP 500 Internal Server Error.
+func (ph ProductsHandler) getProductReviews(w http.ResponseWriter, r *http.Request) {
+	// Extract product ID from URL parameter
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+
+	admin := ph.admin.isAdmin(r)
+	pr, err := parseReviewPageRequest(r.URL.Query(), admin)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Check that the product exists and customers can see it
+	sqlQuery := "SELECT id FROM products WHERE id = $1"
+	if !admin {
+		sqlQuery += " AND " + liveCondition
+	}
+	err = ph.db.QueryRow(sqlQuery, id).Scan(&id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Println(err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	qb := newQueryBuilder("SELECT " + reviewColumns + " FROM product_reviews")
+	qb.where("product_id = ?", id)
+	pr.apply(qb)
+	sqlQuery, args := qb.build()
+	rows, err := ph.db.Query(sqlQuery, args...)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	reviews := []Review{}
+	for rows.Next() {
+		rev := Review{}
+		if err := scanReview(rows, &rev); err != nil {
+			log.Println(err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		reviews = append(reviews, rev)
+	}
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Encode and send response, or a 304 Not Modified if the client has it cached
+	body, err := encodeJSON(pr.paginate(reviews))
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	writeCacheableJSON(w, r, body, time.Time{})
+}
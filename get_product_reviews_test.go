@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestGetProductReviews(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	createdAt := time.Date(2023, 6, 27, 15, 0, 0, 0, time.UTC)
+	reviews := []Review{
+		{ID: 3, ProductID: 1, Rating: 5, Author: "Ana", Status: reviewApproved, CreatedAt: createdAt},
+		{ID: 2, ProductID: 1, Rating: 3, Author: "Luis", Status: reviewApproved, CreatedAt: createdAt.Add(-time.Hour)},
+	}
+	cursor := productCursor{Order: "reviews", Value: "2023-06-28T00:00:00Z", ID: 9}
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id FROM products WHERE id = $1 AND " + liveCondition)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT "+reviewColumns+" FROM product_reviews WHERE product_id = $1 AND status = $2 "+
+		"AND (created_at, id) < ($3::timestamptz, $4) ORDER BY created_at DESC, id DESC LIMIT $5")).
+		WithArgs(1, reviewApproved, cursor.Value, cursor.ID, 2).
+		WillReturnRows(getMockReviewRows(reviews...))
+
+	ph := ProductsHandler{db: db}
+	rr := serveProductRequest(t, ph.getProductReviews, http.MethodGet, "/products/1/reviews?limit=1&cursor="+cursor.encode(), "", map[string]string{"id": "1"})
+
+	expected := reviewsPage{
+		Reviews:    reviews[:1],
+		NextCursor: productCursor{Order: "reviews", Value: "2023-06-27T15:00:00Z", ID: 3}.encode(),
+	}
+	checkResponseCode(t, rr.Code, http.StatusOK)
+	checkResponseBody(t, rr.Body.String(), "", expected)
+	checkMockExpectations(t, mock)
+}
+
+func TestGetProductReviews_AdminStatus(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	// Admins can list the pending reviews of products that are not live
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id FROM products WHERE id = $1")).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM product_reviews WHERE product_id = $1 AND status = $2 ORDER BY")).
+		WithArgs(1, reviewPending, defaultReviewLimit+1).
+		WillReturnRows(getMockReviewRows())
+
+	ph := ProductsHandler{db: db, admin: adminAuth{token: "secret"}}
+	rr := serveAdminRequest(t, ph, ph.getProductReviews, "/products/1/reviews?status=pending", map[string]string{"id": "1"})
+
+	checkResponseCode(t, rr.Code, http.StatusOK)
+	checkResponseBody(t, rr.Body.String(), "", reviewsPage{Reviews: []Review{}})
+	checkMockExpectations(t, mock)
+}
+
+func TestGetProductReviews_Errors(t *testing.T) {
+	tests := []struct {
+		name           string
+		target         string
+		id             string
+		setup          func(mock sqlmock.Sqlmock)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{name: "Invalid ID", target: "/products/abc/reviews", id: "abc", setup: func(mock sqlmock.Sqlmock) {},
+			expectedStatus: http.StatusBadRequest, expectedBody: "Invalid product ID\n"},
+		{name: "Pending reviews", target: "/products/1/reviews?status=pending", id: "1", setup: func(mock sqlmock.Sqlmock) {},
+			expectedStatus: http.StatusBadRequest, expectedBody: "only admins can list reviews that are not approved\n"},
+		{name: "Not found", target: "/products/1/reviews", id: "1", setup: func(mock sqlmock.Sqlmock) {
+			mock.ExpectQuery("SELECT id FROM products").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		}, expectedStatus: http.StatusNotFound, expectedBody: "Product not found\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := getMockDB(t)
+			defer db.Close()
+			tt.setup(mock)
+
+			ph := ProductsHandler{db: db}
+			rr := serveProductRequest(t, ph.getProductReviews, http.MethodGet, tt.target, "", map[string]string{"id": tt.id})
+
+			checkResponseCode(t, rr.Code, tt.expectedStatus)
+			checkResponseBody(t, rr.Body.String(), tt.expectedBody, nil)
+			checkMockExpectations(t, mock)
+		})
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"regexp"
 	"strings"
 	"testing"
 	"time"
@@ -33,14 +34,17 @@ func TestGetProduct_Success(t *testing.T) {
 
 	// Expected product
 	expectedProduct := Product{
-		ID:             1,
-		Name:           "Test Product",
-		Price:          10.99,
-		Description:    "This is a test product",
-		Categories:     []string{"category1", "category2"},
-		Images:         []string{"image1.jpg", "image2.jpg"},
-		ReferencedName: "test-reference",
-		DateAdded:      time.Now(),
+		ID:                1,
+		Name:              "Test Product",
+		Slug:              "test-product",
+		Price:             Money{Amount: 1099, Currency: storeCurrency},
+		Description:       "This is a test product",
+		Categories:        []string{"category1", "category2"},
+		Images:            []string{"image1.jpg", "image2.jpg"},
+		ReferencedName:    "test-reference",
+		DateAdded:         time.Now(),
+		Status:            statusPublished,
+		AvailableQuantity: 3,
 	}
 
 	// Set proper formats for SQL Arrays
@@ -48,11 +52,13 @@ func TestGetProduct_Success(t *testing.T) {
 	postgreSQLArrayImages := sliceToPostgreSQLArray(expectedProduct.Images)
 
 	// Set expectations on mock
-	rows := sqlmock.NewRows([]string{"id", "name", "price", "description", "categories", "images", "referenced_name", "date_added"}).
-		AddRow(expectedProduct.ID, expectedProduct.Name, expectedProduct.Price, expectedProduct.Description, postgreSQLArrayCategories, postgreSQLArrayImages, expectedProduct.ReferencedName, expectedProduct.DateAdded)
-	mock.ExpectQuery("SELECT id, name, price, description, categories, images, referenced_name, date_added FROM products WHERE id = ?").
+	rows := sqlmock.NewRows([]string{"id", "name", "slug", "price", "description", "categories", "images", "referenced_name", "date_added", "status", "publish_at", "unpublish_at", "average_rating", "review_count", "stock_quantity", "updated_at"}).
+		AddRow(expectedProduct.ID, expectedProduct.Name, expectedProduct.Slug, expectedProduct.Price.String(), expectedProduct.Description, postgreSQLArrayCategories, postgreSQLArrayImages, expectedProduct.ReferencedName, expectedProduct.DateAdded,
+			expectedProduct.Status, nil, nil, 0, 0, expectedProduct.AvailableQuantity, expectedProduct.DateAdded)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT " + productColumns + ", updated_at FROM products WHERE id = $1 AND " + liveCondition)).
 		WithArgs(1).
 		WillReturnRows(rows)
+	expectVariants(mock, []Product{expectedProduct})
 
 	// Make request to handler
 	req, err := http.NewRequest(http.MethodGet, "/products/1", nil)
@@ -111,7 +117,7 @@ func TestGetProduct_ErrorWhileQuerying(t *testing.T) {
 
 	// Set up expected query and result
 	expectedErr := errors.New("some error")
-	mock.ExpectQuery("SELECT id, name, price, description, categories, images, referenced_name, date_added FROM products WHERE id = ?").
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT " + productColumns + ", updated_at FROM products WHERE id = $1 AND " + liveCondition)).
 		WithArgs(1).
 		WillReturnError(expectedErr)
 
@@ -159,7 +165,7 @@ func TestGetProduct_ProductNotFound(t *testing.T) {
 	defer db.Close()
 
 	// Set up expected query and result
-	mock.ExpectQuery("SELECT id, name, price, description, categories, images, referenced_name, date_added FROM products WHERE id = ?").
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT " + productColumns + ", updated_at FROM products WHERE id = $1 AND " + liveCondition)).
 		WithArgs(1).
 		WillReturnError(sql.ErrNoRows)
 
@@ -232,3 +238,108 @@ func TestGetProduct_InvalidID(t *testing.T) {
 		t.Errorf("handler returned wrong content-type header: got %v want %v", contentType, "text/plain; charset=utf-8")
 	}
 }
+
+func TestGetProduct_Currency(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	// The Last-Modified date is the latest of the product and the rate
+	rate := ExchangeRate{Currency: "EUR", Rate: "4", RoundingIncrement: "0.05", RoundingMode: roundUp, UpdatedAt: time.Date(2023, 7, 4, 16, 0, 0, 0, time.UTC)}
+	p := getExpectedProducts()[0]
+	expectExchangeRate(mock, rate)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT " + productColumns + ", updated_at FROM products WHERE id = $1 AND " + liveCondition)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "slug", "price", "description", "categories", "images", "referenced_name", "date_added", "status", "publish_at", "unpublish_at", "average_rating", "review_count", "stock_quantity", "updated_at"}).
+			AddRow(p.ID, p.Name, p.Slug, p.Price.String(), p.Description, sliceToPostgreSQLArray(p.Categories), sliceToPostgreSQLArray(p.Images), p.ReferencedName, p.DateAdded,
+				p.Status, nil, nil, 0, 0, p.AvailableQuantity, rate.UpdatedAt.Add(-time.Hour)))
+	expectVariants(mock, []Product{p})
+
+	ph := ProductsHandler{db: db}
+	rr := serveProductRequest(t, ph.getProduct, http.MethodGet, "/products/1?currency=eur", "", map[string]string{"id": "1"})
+
+	// 10.00 COP are 2.50 EUR, already a multiple of 0.05
+	p.Price = Money{Amount: 250, Currency: "EUR"}
+	p.ExchangeRate = &rate
+	checkResponseCode(t, rr.Code, http.StatusOK)
+	checkResponseBody(t, rr.Body.String(), "", p)
+	if lastModified := rr.Header().Get("Last-Modified"); lastModified != rate.UpdatedAt.Format(http.TimeFormat) {
+		t.Errorf("unexpected Last-Modified: got %q want %q", lastModified, rate.UpdatedAt.Format(http.TimeFormat))
+	}
+	checkMockExpectations(t, mock)
+}
+
+func TestGetProduct_UntranslatedIsServedInTheDefaultLocale(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	p := getExpectedProducts()[0]
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT " + productColumns + ", updated_at FROM products WHERE id = $1 AND " + liveCondition)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "slug", "price", "description", "categories", "images", "referenced_name", "date_added", "status", "publish_at", "unpublish_at", "average_rating", "review_count", "stock_quantity", "updated_at"}).
+			AddRow(p.ID, p.Name, p.Slug, p.Price.String(), p.Description, sliceToPostgreSQLArray(p.Categories), sliceToPostgreSQLArray(p.Images), p.ReferencedName, p.DateAdded,
+				p.Status, nil, nil, 0, 0, p.AvailableQuantity, p.DateAdded))
+	expectVariants(mock, []Product{p})
+	expectProductTranslations(mock, []Product{p}, "en")
+
+	ph := ProductsHandler{db: db}
+	rr := serveProductRequest(t, ph.getProduct, http.MethodGet, "/products/1?lang=en", "", map[string]string{"id": "1"})
+
+	checkResponseCode(t, rr.Code, http.StatusOK)
+	checkResponseBody(t, rr.Body.String(), "", p)
+	if language := rr.Header().Get("Content-Language"); language != defaultLocale {
+		t.Errorf("unexpected Content-Language: got %q want %q", language, defaultLocale)
+	}
+	checkMockExpectations(t, mock)
+}
+
+func TestGetProduct_AsOf(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+	ph := ProductsHandler{db: db, admin: adminAuth{token: "secret"}}
+	vars := map[string]string{"id": "1"}
+
+	// The version is rebuilt from the history, without its variants
+	asOf := time.Date(2023, 7, 1, 12, 0, 0, 0, time.UTC)
+	changedAt := time.Date(2023, 6, 20, 9, 30, 0, 0, time.UTC)
+	p := getExpectedProducts()[0]
+	p.Price = cop(900)
+	mock.ExpectQuery(regexp.QuoteMeta(productVersionQuery(productColumns))).
+		WithArgs(1, asOf).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "slug", "price", "description", "categories", "images", "referenced_name", "date_added", "status", "publish_at", "unpublish_at", "average_rating", "review_count", "stock_quantity", "changed_at"}).
+			AddRow(p.ID, p.Name, p.Slug, p.Price.String(), p.Description, sliceToPostgreSQLArray(p.Categories), sliceToPostgreSQLArray(p.Images), p.ReferencedName, p.DateAdded,
+				p.Status, nil, nil, p.AverageRating, p.ReviewCount, p.AvailableQuantity, changedAt))
+
+	rr := serveAdminRequest(t, ph, ph.getProduct, "/products/1?as_of=2023-07-01T12:00:00Z", vars)
+
+	checkResponseCode(t, rr.Code, http.StatusOK)
+	expectedBody, _ := marshalLine(p)
+	checkResponseBody(t, rr.Body.String(), expectedBody, nil)
+	if lastModified := rr.Header().Get("Last-Modified"); lastModified != changedAt.Format(http.TimeFormat) {
+		t.Errorf("handler returned wrong Last-Modified: got %v want %v", lastModified, changedAt.Format(http.TimeFormat))
+	}
+	checkMockExpectations(t, mock)
+}
+
+func TestGetProduct_AsOfErrors(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+	ph := ProductsHandler{db: db, admin: adminAuth{token: "secret"}}
+	vars := map[string]string{"id": "1"}
+
+	// Shoppers can't get past versions
+	rr := serveProductRequest(t, ph.getProduct, http.MethodGet, "/products/1?as_of=2023-07-01", "", vars)
+	checkResponseCode(t, rr.Code, http.StatusForbidden)
+	checkResponseBody(t, rr.Body.String(), "Only admins can get past versions of a product\n", nil)
+
+	rr = serveAdminRequest(t, ph, ph.getProduct, "/products/1?as_of=yesterday", vars)
+	checkResponseCode(t, rr.Code, http.StatusBadRequest)
+	checkResponseBody(t, rr.Body.String(), "as_of must be a date (2006-01-02) or an RFC 3339 timestamp\n", nil)
+
+	// The product did not exist yet, or was deleted
+	mock.ExpectQuery(regexp.QuoteMeta(productVersionQuery(productColumns))).
+		WithArgs(1, time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	rr = serveAdminRequest(t, ph, ph.getProduct, "/products/1?as_of=2023-01-01", vars)
+	checkResponseCode(t, rr.Code, http.StatusNotFound)
+	checkMockExpectations(t, mock)
+}
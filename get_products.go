@@ -1,76 +1,128 @@
 package main
 
 import (
-	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"time"
 )
 
 // getProducts retrieves a list of products from the database and sends a JSON response.
 //
-// Query parameters can be used to filter the results by name, referenced name, category,
-// or a list of categories. The results can also be ordered by price or date added.
+// Query parameters can be used to search the products, and to filter the results by name, referenced name,
+// categories, price range and date added (see parseProductFilter). Filtering by a category also matches
+// the products of its subcategories. The results can also be ordered by price, date added
+// or, for searches, relevance. Search results include their rank and a highlighted snippet of the description.
+// Filter values are always sent to the database as bound arguments. Every product includes its variants.
+// Only the products visible to shoppers are listed, except for admins, who see every product and can filter them by status.
+// Prices can be converted to another currency with the currency query parameter or the Accept-Currency header
+// (see parseCurrencyRequest). The price filters and facets are always in the store currency.
+// Names and descriptions are translated to the locale of the lang query parameter or the Accept-Language header
+// (see parseLocale). Products without a translation keep the default locale, and the filters and the search always
+// use it. The Content-Language header reports the locale that was served (see translateProducts).
 //
+// The results are paginated with the limit and cursor query parameters (see parsePageRequest), and the
+// response includes the cursors of the next and previous pages when they exist. With the facets query parameter,
+// it also includes the number of matching products per category and price bucket (see parseFacetRequest).
+// The fields and include parameters select the product fields that are returned, and the related resources
+// embedded in them (see parseFieldSelection). Only the columns of the selected fields are queried.
+//
+// The response has an ETag, and conditional requests for an unchanged page are answered with an HTTP 304 Not Modified.
+// Responses are cached in Redis for a short time, or until any product changes (see productCache).
+//
+// If the filter, pagination, facets, currency or lang parameters are not valid, a category does not exist, or there is no
+// exchange rate for the requested currency, it returns a 400 Bad Request.
 // If there is an internal server error, it returns a 500 Internal Server Error.
 func (ph ProductsHandler) getProducts(w http.ResponseWriter, r *http.Request) {
-	// Parse query parameters
-	name := r.URL.Query().Get("name")
-	refName := r.URL.Query().Get("referenced_name")
-	categories := r.URL.Query()["categories"]
-	order := r.URL.Query().Get("order")
-
-	// Build SQL query
-	sqlQuery := "SELECT id, name, price, description, categories, images, referenced_name, date_added FROM products"
-
-	// Add filters
-	//
-	// Add name filter
-	if name != "" {
-		sqlQuery += " WHERE name ILIKE '%" + name + "%'"
+	currency, err := parseCurrencyRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Add("Vary", "Accept-Currency")
+	locale, err := parseLocale(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	// Add refname filter
-	if refName != "" {
-		if name == "" {
-			sqlQuery += " WHERE referenced_name ILIKE '%" + refName + "%'"
-		} else {
-			sqlQuery += " AND referenced_name ILIKE '%" + refName + "%'"
-		}
+	// Load the exchange rate of the requested currency. A negotiated currency without a rate falls back to the
+	// store currency, so the rate is needed to know the currency the listing is served in.
+	rate, err := loadExchangeRate(ph.db, currency)
+	var vErr validationError
+	if errors.As(err, &vErr) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		log.Println(err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
 	}
 
-	// Add categories filter
-	if len(categories) > 0 {
-		if name == "" && refName == "" {
-			sqlQuery += " WHERE "
-		} else {
-			sqlQuery += " AND "
+	// Serve the listing from the cache if it's there. Only successful responses are cached, and admin
+	// responses, which include the products that are not live, never are. The currency and the locale can be
+	// negotiated with headers, so the served ones are added to the query the cache key is built from.
+	isAdmin := ph.admin.isAdmin(r)
+	cacheKey, cacheable := "", false
+	if !isAdmin {
+		query := r.URL.Query()
+		query.Del("currency")
+		if rate != nil {
+			query.Set("currency", rate.Currency)
 		}
-		for i, category := range categories {
-			sqlQuery += "'" + category + "' = ANY(categories)"
-			if i < len(categories)-1 {
-				sqlQuery += " OR "
-			}
+		if locale != defaultLocale {
+			query.Set("lang", locale)
 		}
+		cacheKey, cacheable = ph.cache.listKey(r.Context(), query)
 	}
-
-	// Add order by
-	if order != "" {
-		switch order {
-		case "price_asc":
-			sqlQuery += " ORDER BY price ASC"
-		case "price_desc":
-			sqlQuery += " ORDER BY price DESC"
-		case "date_asc":
-			sqlQuery += " ORDER BY date_added ASC"
-		default:
-			sqlQuery += " ORDER BY date_added DESC"
+	if cacheable {
+		if cached, ok := ph.cache.get(r.Context(), cacheKey); ok {
+			setLocaleHeaders(w, cached.Locale)
+			writeCacheableJSON(w, r, cached.Body, time.Time{})
+			return
 		}
-	} else {
-		sqlQuery += " ORDER BY date_added DESC"
 	}
 
+	// Parse query parameters
+	filter, err := parseProductFilter(r.URL.Query())
+	if err == nil {
+		filter.LiveOnly = !isAdmin
+		filter, err = filter.resolveCategories(ph.db)
+	}
+	if errors.As(err, &vErr) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		log.Println(err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	page, err := parsePageRequest(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	facetReq, err := parseFacetRequest(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	fields, err := parseFieldSelection(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Build SQL query
+	columns, dest := fields.columns(page.sort.field)
+	qb := newQueryBuilder("")
+	qb.base = filter.selectProducts(qb, columns)
+	filter.apply(qb)
+	page.apply(qb)
+	sqlQuery, args := qb.build()
+
 	// Execute query
-	rows, err := ph.db.Query(sqlQuery)
+	rows, err := ph.db.Query(sqlQuery, args...)
 	if err != nil {
 		log.Println(err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -82,7 +134,11 @@ func (ph ProductsHandler) getProducts(w http.ResponseWriter, r *http.Request) {
 	products := []Product{}
 	for rows.Next() {
 		p := Product{}
-		err := rows.Scan(&p.ID, &p.Name, &p.Price, &p.Description, (*textArray)(&p.Categories), (*textArray)(&p.Images), &p.ReferencedName, &p.DateAdded)
+		scanDest := dest(&p)
+		if filter.Query != "" {
+			scanDest = append(scanDest, &p.Rank, &p.Snippet)
+		}
+		err := rows.Scan(scanDest...)
 		if err != nil {
 			log.Println(err)
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -90,13 +146,75 @@ func (ph ProductsHandler) getProducts(w http.ResponseWriter, r *http.Request) {
 		}
 		products = append(products, p)
 	}
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	response := page.paginate(products)
+
+	// Load the variants of the products in the page
+	if fields.variants() {
+		err = loadVariants(ph.db, response.Products)
+		if err != nil {
+			log.Println(err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
 
-	// Encode and send response
-	w.Header().Set("Content-Type", "application/json")
-	err = json.NewEncoder(w).Encode(products)
+	// Translate the names and descriptions of the products in the page
+	servedLocale, err := translateProducts(ph.db, response.Products, locale)
 	if err != nil {
 		log.Println(err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
+
+	// Convert the prices after paginating, the cursors are built from the prices in the store currency
+	if rate != nil {
+		err = convertPrices(response.Products, *rate)
+		if err != nil {
+			log.Println(err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Count the facets over the same filters
+	if facetReq.requested() {
+		response.Facets, err = ph.productFacets(filter, facetReq)
+		if err != nil {
+			log.Println(err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Keep only the selected fields and embed the included resources
+	var out interface{} = response
+	if fields.sparse() {
+		sparse := sparseProductPage{productPage: response}
+		sparse.Products, err = renderProducts(ph.db, fields, response.Products, locale)
+		if err != nil {
+			log.Println(err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		out = sparse
+	}
+
+	// Encode, cache and send response, or a 304 Not Modified if the client has it cached
+	body, err := encodeJSON(out)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if cacheable {
+		ph.cache.set(r.Context(), cacheKey, cachedResponse{Body: body, Locale: servedLocale}, productListCacheTTL)
+	}
+	setLocaleHeaders(w, servedLocale)
+	writeCacheableJSON(w, r, body, time.Time{})
 }
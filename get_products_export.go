@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+)
+
+// exportProducts streams every product that matches the filters of getProducts as a catalog file.
+//
+// The format query parameter selects the format of the file: csv (the default), jsonl, with the JSON object of
+// a product in every line, or excel, a CSV file for spreadsheets (see newProductWriter). The products are ordered
+// by ID and written as they are read from the database, so the whole catalog is never in memory. Prices are in the
+// store currency and the content in the default locale, and the products don't include their variants.
+// Only the products visible to shoppers are exported, except for admins, who get every product.
+//
+// If the filter parameters or the format are not valid, or a category does not exist, it returns an HTTP 400 Bad Request.
+// If there is an error before the first product is written, it returns an HTTP 500 Internal Server Error; later
+// errors can only end the response early, and are logged.
+func (ph ProductsHandler) exportProducts(w http.ResponseWriter, r *http.Request) {
+	format, err := parseExportFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Parse query parameters
+	filter, err := parseProductFilter(r.URL.Query())
+	if err == nil {
+		filter.LiveOnly = !ph.admin.isAdmin(r)
+		filter, err = filter.resolveCategories(ph.db)
+	}
+	var vErr validationError
+	if errors.As(err, &vErr) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		log.Println(err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Build SQL query
+	qb := newQueryBuilder("")
+	qb.base = filter.selectProducts(qb, productColumns)
+	filter.apply(qb)
+	qb.order("id")
+	sqlQuery, args := qb.build()
+
+	// Execute query
+	rows, err := ph.db.QueryContext(r.Context(), sqlQuery, args...)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	// Write every product as it is scanned
+	w.Header().Set("Content-Type", exportContentTypes[format])
+	w.Header().Set("Content-Disposition", `attachment; filename="products.`+exportExtensions[format]+`"`)
+	pw, err := newProductWriter(w, format)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	for rows.Next() {
+		p := Product{}
+		var extra []interface{}
+		if filter.Query != "" {
+			extra = []interface{}{&p.Rank, &p.Snippet}
+		}
+		if err := scanProduct(rows, &p, extra...); err != nil {
+			log.Println(err)
+			return
+		}
+		if err := pw.write(p); err != nil {
+			log.Println(err)
+			return
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		return
+	}
+	if err := pw.flush(); err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestExportProducts_CSV(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	products := getExpectedProducts()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT " + productColumns + " FROM products WHERE name ILIKE $1 AND " + liveCondition + " ORDER BY id")).
+		WithArgs("%Product%").
+		WillReturnRows(getMockRows(products))
+
+	ph := ProductsHandler{db: db}
+	rr := serveProductRequest(t, ph.exportProducts, http.MethodGet, "/products/export?name=Product", "", nil)
+
+	checkResponseCode(t, rr.Code, http.StatusOK)
+	if contentType := rr.Header().Get("Content-Type"); contentType != "text/csv; charset=utf-8" {
+		t.Errorf("handler returned wrong content type: got %v", contentType)
+	}
+	if disposition := rr.Header().Get("Content-Disposition"); disposition != `attachment; filename="products.csv"` {
+		t.Errorf("handler returned wrong content disposition: got %v", disposition)
+	}
+	expectedBody := "id,slug,name,price,currency,description,categories,images,referenced_name,status,publish_at,unpublish_at,date_added,average_rating,review_count,available_quantity\n" +
+		"1,product-a,Product A,10.00,COP,Product A description,cat1|cat2,img1|img2,Product B,published,,," + products[0].DateAdded.UTC().Format(time.RFC3339) + ",0,0,1\n" +
+		"2,product-b,Product B,20.00,COP,Product B description,cat1|cat3,img3|img4,Product C,published,,," + products[1].DateAdded.UTC().Format(time.RFC3339) + ",0,0,0\n"
+	checkResponseBody(t, rr.Body.String(), expectedBody, nil)
+	checkMockExpectations(t, mock)
+}
+
+func TestExportProducts_JSONL(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	// Admins export every product
+	products := getExpectedProducts()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT " + productColumns + " FROM products ORDER BY id")).
+		WillReturnRows(getMockRows(products))
+
+	ph := ProductsHandler{db: db, admin: adminAuth{token: "secret"}}
+	rr := serveAdminRequest(t, ph, ph.exportProducts, "/products/export?format=jsonl", nil)
+
+	checkResponseCode(t, rr.Code, http.StatusOK)
+	if contentType := rr.Header().Get("Content-Type"); contentType != "application/x-ndjson" {
+		t.Errorf("handler returned wrong content type: got %v", contentType)
+	}
+	first, _ := marshalLine(products[0])
+	second, _ := marshalLine(products[1])
+	checkResponseBody(t, rr.Body.String(), first+second, nil)
+	checkMockExpectations(t, mock)
+}
+
+func TestExportProducts_Errors(t *testing.T) {
+	tests := []struct {
+		name           string
+		target         string
+		dbErr          error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{name: "Invalid format", target: "/products/export?format=xlsx", expectedStatus: http.StatusBadRequest, expectedBody: "format must be csv, jsonl or excel\n"},
+		{name: "Invalid filter", target: "/products/export?min_price=cheap", expectedStatus: http.StatusBadRequest, expectedBody: "min_price must be a non-negative amount with at most 2 decimals\n"},
+		{name: "DB error", target: "/products/export", dbErr: errors.New("some error"), expectedStatus: http.StatusInternalServerError, expectedBody: "Internal server error\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := getMockDB(t)
+			defer db.Close()
+			if tt.dbErr != nil {
+				mock.ExpectQuery("SELECT").WillReturnError(tt.dbErr)
+			}
+
+			ph := ProductsHandler{db: db}
+			rr := serveProductRequest(t, ph.exportProducts, http.MethodGet, tt.target, "", nil)
+
+			checkResponseCode(t, rr.Code, tt.expectedStatus)
+			checkResponseBody(t, rr.Body.String(), tt.expectedBody, nil)
+			checkMockExpectations(t, mock)
+		})
+	}
+}
@@ -2,11 +2,15 @@ package main
 
 import (
 	"database/sql"
+	"database/sql/driver"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
 	"testing"
 	"time"
 
@@ -26,45 +30,50 @@ func getMockDB(t *testing.T) (*sql.DB, sqlmock.Sqlmock) {
 // getExpectedProducts returns a slice of Product objects that can be used as expected values in tests.
 func getExpectedProducts() []Product {
 	return []Product{
-		{ID: 1, Name: "Product A", Price: 10.0, Description: "Product A description", Categories: []string{"cat1", "cat2"}, Images: []string{"img1", "img2"}, ReferencedName: "Product B", DateAdded: time.Now().Add(time.Minute)},
-		{ID: 2, Name: "Product B", Price: 20.0, Description: "Product B description", Categories: []string{"cat1", "cat3"}, Images: []string{"img3", "img4"}, ReferencedName: "Product C", DateAdded: time.Now()},
+		{ID: 1, Name: "Product A", Slug: "product-a", Price: Money{Amount: 1000, Currency: storeCurrency}, Description: "Product A description", Categories: []string{"cat1", "cat2"}, Images: []string{"img1", "img2"}, ReferencedName: "Product B", DateAdded: time.Now().Add(time.Minute), Status: statusPublished, AvailableQuantity: 1},
+		{ID: 2, Name: "Product B", Slug: "product-b", Price: Money{Amount: 2000, Currency: storeCurrency}, Description: "Product B description", Categories: []string{"cat1", "cat3"}, Images: []string{"img3", "img4"}, ReferencedName: "Product C", DateAdded: time.Now(), Status: statusPublished},
 	}
 }
 
-// expectedQuery returns a SELECT query string for the 'products' table with the given order by clause.
-func expectedQuery(orderBy string, nameFilter, refNameFilter bool, categoriesFiltered int) string {
-	query := "SELECT id, name, price, description, categories, images, referenced_name, date_added FROM products"
+// expectedQuery returns the escaped SELECT query string for the 'products' table with the given order by clause,
+// as requested by a shopper, and the arguments that are expected to be bound to its placeholders.
+func expectedQuery(orderBy string, nameFilter, refNameFilter bool, categoriesFiltered int) (string, []driver.Value) {
+	query := "SELECT " + productColumns + " FROM products"
+	args := []driver.Value{}
+	conditions := []string{}
 
 	if nameFilter {
-		query += " WHERE name ILIKE '%ARandomName%'"
+		args = append(args, "%ARandomName%")
+		conditions = append(conditions, fmt.Sprintf("name ILIKE $%d", len(args)))
 	}
 
 	if refNameFilter {
-		if nameFilter {
-			query += " AND "
-		} else {
-			query += " WHERE "
-		}
-		query += "referenced_name ILIKE '%ARandomReferencedName%'"
+		args = append(args, "%ARandomReferencedName%")
+		conditions = append(conditions, fmt.Sprintf("referenced_name ILIKE $%d", len(args)))
 	}
 
 	if categoriesFiltered > 0 {
-		if nameFilter || refNameFilter {
-			query += " AND "
-		} else {
-			query += " WHERE "
-		}
-		for i := 0; i < categoriesFiltered; i++ {
-			if i > 0 {
-				query += " OR "
-			}
-			// Note how we had to escape the parentheses here by actually escaping the backslash
-			query = fmt.Sprintf("%s'Category%v' = ANY\\(categories\\)", query, i)
-		}
+		args = append(args, textArray(expectedCategories(categoriesFiltered)))
+		conditions = append(conditions, fmt.Sprintf("categories && $%d", len(args)))
 	}
 
-	query = fmt.Sprintf("%s ORDER BY %s", query, orderBy)
-	return query
+	// Requests that are not from an admin only see the live products
+	conditions = append(conditions, liveCondition)
+	query += " WHERE " + strings.Join(conditions, " AND ")
+
+	args = append(args, defaultPageLimit+1)
+	query = fmt.Sprintf("%s ORDER BY %s LIMIT $%d", query, orderBy, len(args))
+	// Placeholders and parentheses are regex metacharacters for sqlmock
+	return regexp.QuoteMeta(query), args
+}
+
+// expectedCategories returns the categories used by getProductsURL to filter by the given number of categories.
+func expectedCategories(categoriesFiltered int) []string {
+	categories := []string{}
+	for i := 0; i < categoriesFiltered; i++ {
+		categories = append(categories, fmt.Sprintf("Category%v", i))
+	}
+	return categories
 }
 
 // getProductsURL returns a URL with the given parameters.
@@ -109,9 +118,10 @@ func getProductsURL(order string, nameFilter, refNameFilter bool, categoriesFilt
 
 // getMockRows returns a mock sqlmock.Rows object populated with the given products slice.
 func getMockRows(products []Product) *sqlmock.Rows {
-	rows := sqlmock.NewRows([]string{"id", "name", "price", "description", "categories", "images", "referenced_name", "date_added"})
+	rows := sqlmock.NewRows([]string{"id", "name", "slug", "price", "description", "categories", "images", "referenced_name", "date_added", "status", "publish_at", "unpublish_at", "average_rating", "review_count", "stock_quantity"})
 	for _, p := range products {
-		rows.AddRow(p.ID, p.Name, p.Price, p.Description, sliceToPostgreSQLArray(p.Categories), sliceToPostgreSQLArray(p.Images), p.ReferencedName, p.DateAdded)
+		rows.AddRow(p.ID, p.Name, p.Slug, p.Price.String(), p.Description, sliceToPostgreSQLArray(p.Categories), sliceToPostgreSQLArray(p.Images), p.ReferencedName, p.DateAdded,
+			p.Status, p.PublishAt, p.UnpublishAt, p.AverageRating, p.ReviewCount, p.AvailableQuantity)
 	}
 	return rows
 }
@@ -139,11 +149,11 @@ func checkResponseCode(t *testing.T, status, expectedStatus int) {
 	}
 }
 
-// checkResponseBody compares the response body string with the expected products slice and logs an error if they are not the same.
+// checkResponseBody compares the response body string with the expected value encoded as JSON and logs an error if they are not the same.
 // t is the testing.T object used for logging any errors that occur.
-func checkResponseBody(t *testing.T, body, expectedBody string, expectedProducts []Product) {
-	if expectedProducts != nil && expectedBody == "" {
-		expectedBodyBytes, err := json.Marshal(expectedProducts)
+func checkResponseBody(t *testing.T, body, expectedBody string, expected interface{}) {
+	if expected != nil && expectedBody == "" {
+		expectedBodyBytes, err := json.Marshal(expected)
 		if err != nil {
 			t.Fatalf("failed to marshal expected body: %v", err)
 		}
@@ -181,7 +191,7 @@ func TestGetProducts(t *testing.T) {
 			nameFilter:         false,
 			refNameFilter:      false,
 			categoriesFiltered: 0,
-			dbString:           "date_added DESC",
+			dbString:           "date_added DESC, id DESC",
 			expectedProducts:   getExpectedProducts(),
 		},
 		{
@@ -190,7 +200,7 @@ func TestGetProducts(t *testing.T) {
 			nameFilter:         true,
 			refNameFilter:      false,
 			categoriesFiltered: 0,
-			dbString:           "date_added DESC",
+			dbString:           "date_added DESC, id DESC",
 			expectedProducts:   getExpectedProducts(),
 		},
 		{
@@ -199,7 +209,7 @@ func TestGetProducts(t *testing.T) {
 			nameFilter:         false,
 			refNameFilter:      true,
 			categoriesFiltered: 0,
-			dbString:           "date_added DESC",
+			dbString:           "date_added DESC, id DESC",
 			expectedProducts:   getExpectedProducts(),
 		},
 		{
@@ -208,7 +218,7 @@ func TestGetProducts(t *testing.T) {
 			nameFilter:         true,
 			refNameFilter:      true,
 			categoriesFiltered: 0,
-			dbString:           "date_added DESC",
+			dbString:           "date_added DESC, id DESC",
 			expectedProducts:   getExpectedProducts(),
 		},
 		{
@@ -217,7 +227,7 @@ func TestGetProducts(t *testing.T) {
 			nameFilter:         false,
 			refNameFilter:      false,
 			categoriesFiltered: 1,
-			dbString:           "date_added DESC",
+			dbString:           "date_added DESC, id DESC",
 			expectedProducts:   getExpectedProducts(),
 		},
 		{
@@ -226,7 +236,7 @@ func TestGetProducts(t *testing.T) {
 			nameFilter:         false,
 			refNameFilter:      false,
 			categoriesFiltered: 2,
-			dbString:           "date_added DESC",
+			dbString:           "date_added DESC, id DESC",
 			expectedProducts:   getExpectedProducts(),
 		},
 		{
@@ -235,7 +245,7 @@ func TestGetProducts(t *testing.T) {
 			nameFilter:         true,
 			refNameFilter:      true,
 			categoriesFiltered: 2,
-			dbString:           "date_added DESC",
+			dbString:           "date_added DESC, id DESC",
 			expectedProducts:   getExpectedProducts(),
 		},
 		{
@@ -244,7 +254,7 @@ func TestGetProducts(t *testing.T) {
 			nameFilter:         false,
 			refNameFilter:      false,
 			categoriesFiltered: 0,
-			dbString:           "price DESC",
+			dbString:           "price DESC, id DESC",
 			expectedProducts:   getExpectedProducts(),
 		},
 		{
@@ -253,7 +263,7 @@ func TestGetProducts(t *testing.T) {
 			nameFilter:         false,
 			refNameFilter:      false,
 			categoriesFiltered: 0,
-			dbString:           "price ASC",
+			dbString:           "price ASC, id ASC",
 			expectedProducts:   getExpectedProducts(),
 		},
 		{
@@ -262,7 +272,7 @@ func TestGetProducts(t *testing.T) {
 			nameFilter:         false,
 			refNameFilter:      false,
 			categoriesFiltered: 0,
-			dbString:           "date_added DESC",
+			dbString:           "date_added DESC, id DESC",
 			expectedProducts:   getExpectedProducts(),
 		},
 		{
@@ -271,7 +281,7 @@ func TestGetProducts(t *testing.T) {
 			nameFilter:         false,
 			refNameFilter:      false,
 			categoriesFiltered: 0,
-			dbString:           "date_added ASC",
+			dbString:           "date_added ASC, id ASC",
 			expectedProducts:   getExpectedProducts(),
 		},
 		{
@@ -280,7 +290,7 @@ func TestGetProducts(t *testing.T) {
 			nameFilter:         true,
 			refNameFilter:      true,
 			categoriesFiltered: 2,
-			dbString:           "date_added ASC",
+			dbString:           "date_added ASC, id ASC",
 			expectedProducts:   getExpectedProducts(),
 		},
 	}
@@ -290,11 +300,16 @@ func TestGetProducts(t *testing.T) {
 			db, mock := getMockDB(t)
 			defer db.Close()
 
-			mock.ExpectQuery(expectedQuery(tt.dbString, tt.nameFilter, tt.refNameFilter, tt.categoriesFiltered)).WillReturnRows(getMockRows(tt.expectedProducts))
+			if tt.categoriesFiltered > 0 {
+				expectCategoryTree(mock, expectedCategories(tt.categoriesFiltered)...)
+			}
+			query, args := expectedQuery(tt.dbString, tt.nameFilter, tt.refNameFilter, tt.categoriesFiltered)
+			mock.ExpectQuery(query).WithArgs(args...).WillReturnRows(getMockRows(tt.expectedProducts))
+			expectVariants(mock, tt.expectedProducts)
 			rr := makeRequest(t, db, getProductsURL(tt.order, tt.nameFilter, tt.refNameFilter, tt.categoriesFiltered))
 
 			checkResponseCode(t, rr.Code, http.StatusOK)
-			checkResponseBody(t, rr.Body.String(), "", tt.expectedProducts)
+			checkResponseBody(t, rr.Body.String(), "", productPage{Products: tt.expectedProducts})
 			checkMockExpectations(t, mock)
 		})
 	}
@@ -305,7 +320,7 @@ func TestGetProducts_DBError(t *testing.T) {
 	defer db.Close()
 
 	expectedErr := errors.New("some error")
-	mock.ExpectQuery("SELECT id, name, price, description, categories, images, referenced_name, date_added FROM products").
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT " + productColumns + " FROM products")).
 		WillReturnError(expectedErr)
 
 	rr := makeRequest(t, db, getProductsURL("", false, false, 0))
@@ -319,10 +334,10 @@ func TestGetProducts_ScanError(t *testing.T) {
 	db, mock := getMockDB(t)
 	defer db.Close()
 
-	rows := sqlmock.NewRows([]string{"id", "name", "price", "description", "categories", "images", "referenced_name", "date_added"}).
-		AddRow(1, "Test Product", 9.99, "Test Description", nil, nil, nil, time.Now()).
-		AddRow(2, "Invalid Product", "invalid price", "Invalid Description", nil, nil, nil, time.Now())
-	mock.ExpectQuery("SELECT id, name, price, description, categories, images, referenced_name, date_added FROM products").WillReturnRows(rows)
+	rows := sqlmock.NewRows([]string{"id", "name", "slug", "price", "description", "categories", "images", "referenced_name", "date_added", "status", "publish_at", "unpublish_at", "average_rating", "review_count", "stock_quantity"}).
+		AddRow(1, "Test Product", "test-product", 9.99, "Test Description", nil, nil, nil, time.Now(), statusPublished, nil, nil, 0, 0, 0).
+		AddRow(2, "Invalid Product", "invalid-product", "invalid price", "Invalid Description", nil, nil, nil, time.Now(), statusPublished, nil, nil, 0, 0, 0)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT " + productColumns + " FROM products")).WillReturnRows(rows)
 
 	rr := makeRequest(t, db, getProductsURL("", false, false, 0))
 
@@ -330,3 +345,174 @@ func TestGetProducts_ScanError(t *testing.T) {
 	checkResponseBody(t, rr.Body.String(), "Internal server error\n", nil)
 	checkMockExpectations(t, mock)
 }
+
+func TestGetProducts_SQLInjectionIsBound(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	name := "x' OR '1'='1"
+	query := regexp.QuoteMeta("SELECT " + productColumns + " FROM products WHERE name ILIKE $1 AND " + liveCondition + " ORDER BY date_added DESC, id DESC LIMIT $2")
+	mock.ExpectQuery(query).WithArgs("%"+name+"%", defaultPageLimit+1).WillReturnRows(getMockRows(getExpectedProducts()))
+	expectVariants(mock, getExpectedProducts())
+
+	rr := makeRequest(t, db, "/products?name="+url.QueryEscape(name))
+
+	checkResponseCode(t, rr.Code, http.StatusOK)
+	checkMockExpectations(t, mock)
+}
+
+func TestGetProducts_Search(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	expectedProducts := getExpectedProducts()
+	expectedProducts[0].Rank, expectedProducts[0].Snippet = 0.5, "A <mark>blue</mark> mug"
+	expectedProducts[1].Rank, expectedProducts[1].Snippet = 0.25, "Another <mark>blue</mark> piece"
+
+	rows := sqlmock.NewRows([]string{"id", "name", "slug", "price", "description", "categories", "images", "referenced_name", "date_added", "status", "publish_at", "unpublish_at", "average_rating", "review_count", "stock_quantity", "rank", "snippet"})
+	for _, p := range expectedProducts {
+		rows.AddRow(p.ID, p.Name, p.Slug, p.Price.String(), p.Description, sliceToPostgreSQLArray(p.Categories), sliceToPostgreSQLArray(p.Images), p.ReferencedName, p.DateAdded,
+			p.Status, p.PublishAt, p.UnpublishAt, p.AverageRating, p.ReviewCount, p.AvailableQuantity, p.Rank, p.Snippet)
+	}
+	query := regexp.QuoteMeta("SELECT " + productColumns + ", " + searchColumns +
+		" FROM products, websearch_to_tsquery('spanish', $1) query WHERE search_vector @@ query AND " + liveCondition + " ORDER BY ts_rank(search_vector, query) DESC, id DESC LIMIT $2")
+	mock.ExpectQuery(query).WithArgs("blue", defaultPageLimit+1).WillReturnRows(rows)
+	expectVariants(mock, expectedProducts)
+
+	rr := makeRequest(t, db, "/products?q=blue")
+
+	checkResponseCode(t, rr.Code, http.StatusOK)
+	checkResponseBody(t, rr.Body.String(), "", productPage{Products: expectedProducts})
+	checkMockExpectations(t, mock)
+}
+
+func TestGetProducts_RelevanceWithoutSearch(t *testing.T) {
+	rr := makeRequest(t, nil, "/products?order=relevance")
+
+	checkResponseCode(t, rr.Code, http.StatusBadRequest)
+	checkResponseBody(t, rr.Body.String(), "order=relevance requires the q parameter\n", nil)
+}
+
+func TestGetProducts_InvalidFilter(t *testing.T) {
+	rr := makeRequest(t, nil, "/products?min_price=abc")
+
+	checkResponseCode(t, rr.Code, http.StatusBadRequest)
+	checkResponseBody(t, rr.Body.String(), "min_price must be a non-negative amount with at most 2 decimals\n", nil)
+}
+
+func TestGetProducts_Variants(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	expectedProducts := getExpectedProducts()
+	expectedProducts[1].Variants = []ProductVariant{
+		{ID: 1, ProductID: 2, SKU: "B-WHITE", Options: map[string]string{"glaze": "white"}, Images: []string{}, Stock: 1},
+	}
+	query, args := expectedQuery("date_added DESC, id DESC", false, false, 0)
+	mock.ExpectQuery(query).WithArgs(args...).WillReturnRows(getMockRows(expectedProducts))
+	expectVariants(mock, expectedProducts, expectedProducts[1].Variants...)
+
+	rr := makeRequest(t, db, "/products")
+
+	checkResponseCode(t, rr.Code, http.StatusOK)
+	checkResponseBody(t, rr.Body.String(), "", productPage{Products: expectedProducts})
+	checkMockExpectations(t, mock)
+}
+
+func TestGetProducts_Currency(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	rate := ExchangeRate{Currency: "USD", Rate: "4", RoundingIncrement: "0.01", RoundingMode: roundHalfUp, UpdatedAt: time.Date(2023, 7, 4, 16, 0, 0, 0, time.UTC)}
+	products := getExpectedProducts()
+	expectExchangeRate(mock, rate)
+	query, args := expectedQuery("date_added DESC, id DESC", false, false, 0)
+	mock.ExpectQuery(query).WithArgs(args...).WillReturnRows(getMockRows(products))
+	expectVariants(mock, products)
+
+	ph := ProductsHandler{db: db}
+	req, err := http.NewRequest(http.MethodGet, "/products", nil)
+	if err != nil {
+		t.Fatalf("Failed to create HTTP request: %v", err)
+	}
+	req.Header.Set("Accept-Currency", "usd")
+	rr := httptest.NewRecorder()
+	ph.getProducts(rr, req)
+
+	// 10.00 and 20.00 COP are 2.50 and 5.00 USD
+	products[0].Price = Money{Amount: 250, Currency: "USD"}
+	products[1].Price = Money{Amount: 500, Currency: "USD"}
+	products[0].ExchangeRate, products[1].ExchangeRate = &rate, &rate
+	checkResponseCode(t, rr.Code, http.StatusOK)
+	checkResponseBody(t, rr.Body.String(), "", productPage{Products: products})
+	if vary := rr.Header().Get("Vary"); vary != "Accept-Currency" {
+		t.Errorf("unexpected Vary header: %q", vary)
+	}
+	checkMockExpectations(t, mock)
+}
+
+func TestGetProducts_CurrencyWithoutRate(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	mock.ExpectQuery("FROM exchange_rates").WithArgs("EUR").WillReturnRows(getExchangeRateRows())
+
+	rr := makeRequest(t, db, "/products?currency=EUR")
+
+	checkResponseCode(t, rr.Code, http.StatusBadRequest)
+	checkResponseBody(t, rr.Body.String(), "there is no exchange rate for EUR\n", nil)
+	checkMockExpectations(t, mock)
+}
+
+func TestGetProducts_Translated(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	description := "A mug"
+	products := getExpectedProducts()
+	query, args := expectedQuery("date_added DESC, id DESC", false, false, 0)
+	mock.ExpectQuery(query).WithArgs(args...).WillReturnRows(getMockRows(products))
+	expectVariants(mock, products)
+	expectProductTranslations(mock, products, "en", productTranslation{ProductID: 2, Name: "Mug", Description: &description})
+
+	ph := ProductsHandler{db: db}
+	req, err := http.NewRequest(http.MethodGet, "/products", nil)
+	if err != nil {
+		t.Fatalf("Failed to create HTTP request: %v", err)
+	}
+	req.Header.Set("Accept-Language", "en-GB,en;q=0.9")
+	rr := httptest.NewRecorder()
+	ph.getProducts(rr, req)
+
+	products[1].Name, products[1].Description = "Mug", description
+	checkResponseCode(t, rr.Code, http.StatusOK)
+	checkResponseBody(t, rr.Body.String(), "", productPage{Products: products})
+	if language := rr.Header().Get("Content-Language"); language != "en" {
+		t.Errorf("unexpected Content-Language: got %q want %q", language, "en")
+	}
+	if vary := rr.Header().Values("Vary"); len(vary) != 2 || vary[1] != "Accept-Language" {
+		t.Errorf("unexpected Vary headers: %q", vary)
+	}
+	checkMockExpectations(t, mock)
+}
+
+func TestGetProducts_Untranslated(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	// Without translations the listing is served in the default locale, whatever was requested
+	products := getExpectedProducts()
+	query, args := expectedQuery("date_added DESC, id DESC", false, false, 0)
+	mock.ExpectQuery(query).WithArgs(args...).WillReturnRows(getMockRows(products))
+	expectVariants(mock, products)
+	expectProductTranslations(mock, products, "en")
+
+	rr := makeRequest(t, db, "/products?lang=en")
+
+	checkResponseCode(t, rr.Code, http.StatusOK)
+	checkResponseBody(t, rr.Body.String(), "", productPage{Products: products})
+	if language := rr.Header().Get("Content-Language"); language != defaultLocale {
+		t.Errorf("unexpected Content-Language: got %q want %q", language, defaultLocale)
+	}
+	checkMockExpectations(t, mock)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// getRelatedProducts returns the products related to a product as a JSON response, the most related first,
+// so the product page can show more pieces of the same series.
+//
+// It expects the ID of the product as a URL parameter. Related products share its referenced name or reference
+// it, share categories, or are close in price (see relatedScore), and every one has its score. Only live products
+// are returned. The limit query parameter sets the number of products, 8 by default and 50 at most.
+// If the ID or the limit are not valid, it returns an HTTP 400 Bad Request error.
+// If the product is not found, or it is not live and the request is not from an admin, it returns an HTTP 404 Not Found error.
+// If there is an error while querying the database, it returns an HTTP 500 Internal Server Error.
+func (ph ProductsHandler) getRelatedProducts(w http.ResponseWriter, r *http.Request) {
+	// Extract product ID from URL parameter
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+
+	limit, err := parseRelatedLimit(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Load the product the others are related to
+	sqlQuery := "SELECT " + productColumns + " FROM products WHERE id = $1"
+	if !ph.admin.isAdmin(r) {
+		sqlQuery += " AND " + liveCondition
+	}
+	p := Product{}
+	err = scanProduct(ph.db.QueryRow(sqlQuery, id), &p)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Println(err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Score the candidates and keep the most related ones
+	candidates, err := relatedCandidates(ph.db, p)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	response := relatedProductsPage{Products: rankRelated(p, candidates, limit)}
+
+	// Encode and send response, or a 304 Not Modified if the client has it cached
+	body, err := encodeJSON(response)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	writeCacheableJSON(w, r, body, time.Time{})
+}
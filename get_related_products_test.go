@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestGetRelatedProducts(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	p := Product{ID: 3, Name: "Taza Mar", Slug: "taza-mar", Price: cop(10000), ReferencedName: "Product B", Categories: []string{"cat3"},
+		Images: []string{}, Status: statusPublished}
+	candidates := getExpectedProducts()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT " + productColumns + " FROM products WHERE id = $1 AND " + liveCondition)).
+		WithArgs(3).
+		WillReturnRows(getMockRows([]Product{p}))
+	expectRelatedCandidates(mock, p, candidates, nil)
+
+	ph := ProductsHandler{db: db}
+	rr := serveProductRequest(t, ph.getRelatedProducts, http.MethodGet, "/products/3/related?limit=1", "", map[string]string{"id": "3"})
+
+	// Product A is in the same series, which outweighs product B being the referenced product and sharing a category
+	expected := relatedProductsPage{Products: []relatedProduct{{Product: candidates[0], Score: sameReferenceScore}}}
+	checkResponseCode(t, rr.Code, http.StatusOK)
+	checkResponseBody(t, rr.Body.String(), "", expected)
+	checkMockExpectations(t, mock)
+}
+
+func TestGetRelatedProducts_Errors(t *testing.T) {
+	tests := []struct {
+		name           string
+		target         string
+		id             string
+		setup          func(mock sqlmock.Sqlmock)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{name: "Invalid ID", target: "/products/abc/related", id: "abc", setup: func(mock sqlmock.Sqlmock) {},
+			expectedStatus: http.StatusBadRequest, expectedBody: "Invalid product ID\n"},
+		{name: "Invalid limit", target: "/products/1/related?limit=100", id: "1", setup: func(mock sqlmock.Sqlmock) {},
+			expectedStatus: http.StatusBadRequest, expectedBody: "limit must be a number between 1 and 50\n"},
+		{name: "Not found", target: "/products/1/related", id: "1", setup: func(mock sqlmock.Sqlmock) {
+			mock.ExpectQuery("SELECT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		}, expectedStatus: http.StatusNotFound, expectedBody: "Product not found\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := getMockDB(t)
+			defer db.Close()
+			tt.setup(mock)
+
+			ph := ProductsHandler{db: db}
+			rr := serveProductRequest(t, ph.getRelatedProducts, http.MethodGet, tt.target, "", map[string]string{"id": tt.id})
+
+			checkResponseCode(t, rr.Code, tt.expectedStatus)
+			checkResponseBody(t, rr.Body.String(), tt.expectedBody, nil)
+			checkMockExpectations(t, mock)
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// errImageNotFound is returned by an ImageStorage when there is no image with the requested name.
+var errImageNotFound = errors.New("image not found")
+
+// imageNamePattern matches the names an ImageStorage accepts: slash separated segments of letters, numbers,
+// dots, dashes and underscores, which can't be used to escape the storage root.
+var imageNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9._-]*(/[A-Za-z0-9_-][A-Za-z0-9._-]*)*$`)
+
+// ImageStorage stores image files by name, so the files can live on the local disk or on any other backend.
+type ImageStorage interface {
+	// Save stores the content of r under name, replacing any previous image with the same name.
+	Save(name string, r io.Reader) error
+	// Open returns the content of the image and the time it was last modified,
+	// or errImageNotFound if there is no image with that name.
+	Open(name string) (io.ReadSeekCloser, time.Time, error)
+}
+
+// localImageStorage is an ImageStorage that keeps the images as files under a directory of the local filesystem.
+type localImageStorage struct {
+	dir string
+}
+
+// newLocalImageStorage returns a localImageStorage rooted at dir, creating the directory if needed.
+func newLocalImageStorage(dir string) (*localImageStorage, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &localImageStorage{dir: dir}, nil
+}
+
+// path returns the file path of an image, or an error if the name is not valid.
+func (s *localImageStorage) path(name string) (string, error) {
+	if !imageNamePattern.MatchString(name) {
+		return "", errors.New("invalid image name")
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(name)), nil
+}
+
+// Save writes the image to a temporary file and renames it, so readers never see a partially written image.
+func (s *localImageStorage) Save(name string, r io.Reader) error {
+	path, err := s.path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Open opens the image file for reading.
+func (s *localImageStorage) Open(name string) (io.ReadSeekCloser, time.Time, error) {
+	path, err := s.path(name)
+	if err != nil {
+		return nil, time.Time{}, errImageNotFound
+	}
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, time.Time{}, errImageNotFound
+	} else if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, time.Time{}, err
+	}
+	if info.IsDir() {
+		f.Close()
+		return nil, time.Time{}, errImageNotFound
+	}
+	return f, info.ModTime(), nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLocalImageStorage(t *testing.T) {
+	storage, err := newLocalImageStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := storage.Save("variants/abc.png", strings.NewReader("png data")); err != nil {
+		t.Fatalf("unexpected error saving image: %v", err)
+	}
+
+	f, modTime, err := storage.Open("variants/abc.png")
+	if err != nil {
+		t.Fatalf("unexpected error opening image: %v", err)
+	}
+	defer f.Close()
+	content, _ := io.ReadAll(f)
+	if string(content) != "png data" {
+		t.Errorf("unexpected content: %q", content)
+	}
+	if modTime.IsZero() {
+		t.Errorf("expected a modification time")
+	}
+
+	for _, name := range []string{"missing.png", "../secret", "/etc/passwd", "variants", "a/../b.png", ""} {
+		if _, _, err := storage.Open(name); err != errImageNotFound {
+			t.Errorf("expected errImageNotFound for %q, got %v", name, err)
+		}
+	}
+
+	if err := storage.Save("../escape.png", strings.NewReader("x")); err == nil {
+		t.Errorf("expected an error saving an image outside of the storage")
+	}
+}
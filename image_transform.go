@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxTransformDimension is the biggest width or height that can be requested for a transformed image.
+	maxTransformDimension = 2000
+	// maxSourcePixels is the biggest original image, in pixels, that is decoded to be transformed.
+	maxSourcePixels = 50_000_000
+	// transformJPEGQuality is the quality of transformed images encoded as JPEG.
+	transformJPEGQuality = 85
+)
+
+// imageTransform describes how an image should be resized and encoded.
+type imageTransform struct {
+	width  int
+	height int
+	// fit is "contain" (fit inside the box keeping the aspect ratio), "cover" (fill the box keeping
+	// the aspect ratio and crop the overflow) or "fill" (stretch to the box).
+	fit string
+	// format is "jpeg" or "png". It is empty to keep the format of the original image.
+	format string
+}
+
+// parseImageTransform reads the w, h, fit and format query parameters.
+//
+// At least one of w and h is required, and both must be between 1 and maxTransformDimension.
+// fit defaults to contain. It returns a validationError if any of the values is not valid.
+func parseImageTransform(query url.Values) (imageTransform, error) {
+	t := imageTransform{fit: query.Get("fit"), format: query.Get("format")}
+
+	dimensionErr := validationError{fmt.Sprintf("w and h must be numbers between 1 and %d", maxTransformDimension)}
+	for _, p := range []struct {
+		name string
+		dest *int
+	}{{"w", &t.width}, {"h", &t.height}} {
+		v := query.Get(p.name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxTransformDimension {
+			return t, dimensionErr
+		}
+		*p.dest = n
+	}
+	if t.width == 0 && t.height == 0 {
+		return t, validationError{"at least one of w and h is required"}
+	}
+
+	switch t.fit {
+	case "":
+		t.fit = "contain"
+	case "contain", "cover", "fill":
+	default:
+		return t, validationError{"fit must be contain, cover or fill"}
+	}
+
+	switch t.format {
+	case "", "jpeg", "png":
+	case "jpg":
+		t.format = "jpeg"
+	default:
+		return t, validationError{"format must be jpeg or png"}
+	}
+
+	return t, nil
+}
+
+// outputFormat returns the format of the transformed version of the named image:
+// the requested one, or the format of the original image.
+func (t imageTransform) outputFormat(name string) string {
+	if t.format != "" {
+		return t.format
+	}
+	if strings.EqualFold(path.Ext(name), ".png") {
+		return "png"
+	}
+	return "jpeg"
+}
+
+// variantName returns the name the transformed version of the named image is cached with.
+func (t imageTransform) variantName(name string) string {
+	ext := ".jpg"
+	if t.outputFormat(name) == "png" {
+		ext = ".png"
+	}
+	base := strings.TrimSuffix(name, path.Ext(name))
+	return fmt.Sprintf("variants/%s/%dx%d-%s%s", base, t.width, t.height, t.fit, ext)
+}
+
+// size returns the size of the resized image and the part of the source image it is taken from.
+func (t imageTransform) size(src image.Rectangle) (int, int, image.Rectangle) {
+	sw, sh := src.Dx(), src.Dy()
+	w, h := t.width, t.height
+
+	// With a single dimension, the other one keeps the aspect ratio. If it would be bigger than
+	// maxTransformDimension, as for a very wide or tall image, it is clamped and the given one scaled down to match.
+	if w == 0 {
+		w = max(1, sw*h/sh)
+		if w > maxTransformDimension {
+			return maxTransformDimension, max(1, sh*maxTransformDimension/sw), src
+		}
+		return w, h, src
+	}
+	if h == 0 {
+		h = max(1, sh*w/sw)
+		if h > maxTransformDimension {
+			return max(1, sw*maxTransformDimension/sh), maxTransformDimension, src
+		}
+		return w, h, src
+	}
+
+	switch t.fit {
+	case "fill":
+		return w, h, src
+	case "cover":
+		// Crop the source to the aspect ratio of the box, centered
+		crop := src
+		if sw*h > sh*w {
+			cw := sh * w / h
+			crop.Min.X += (sw - cw) / 2
+			crop.Max.X = crop.Min.X + cw
+		} else {
+			ch := sw * h / w
+			crop.Min.Y += (sh - ch) / 2
+			crop.Max.Y = crop.Min.Y + ch
+		}
+		return w, h, crop
+	default:
+		// contain: scale by the most restrictive dimension
+		if sw*h > sh*w {
+			return w, max(1, sh*w/sw), src
+		}
+		return max(1, sw*h/sh), h, src
+	}
+}
+
+// apply decodes a JPEG or PNG image, resizes it and encodes it in the given format.
+func (t imageTransform) apply(r io.ReadSeeker, format string) ([]byte, error) {
+	// Check the size before decoding, so huge images can't exhaust the memory
+	cfg, srcFormat, err := image.DecodeConfig(r)
+	if err != nil || (srcFormat != "jpeg" && srcFormat != "png") {
+		return nil, validationError{"only JPEG and PNG images can be transformed"}
+	}
+	if cfg.Width*cfg.Height > maxSourcePixels {
+		return nil, validationError{"image is too big to be transformed"}
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	src, _, err := image.Decode(r)
+	if err != nil {
+		return nil, validationError{"only JPEG and PNG images can be transformed"}
+	}
+
+	w, h, crop := t.size(src.Bounds())
+	dst := resize(src, crop, w, h)
+
+	var buf bytes.Buffer
+	if format == "png" {
+		err = png.Encode(&buf, dst)
+	} else {
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: transformJPEGQuality})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// resize scales the crop rectangle of src to a w x h image.
+//
+// Every destination pixel is the average of the source pixels it covers (a box filter), which gives
+// smooth thumbnails when downscaling. When upscaling, each pixel covers less than one source pixel and
+// the nearest one is used.
+func resize(src image.Image, crop image.Rectangle, w, h int) *image.NRGBA {
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	cw, ch := crop.Dx(), crop.Dy()
+
+	for y := 0; y < h; y++ {
+		y0 := crop.Min.Y + y*ch/h
+		y1 := max(y0+1, crop.Min.Y+(y+1)*ch/h)
+		for x := 0; x < w; x++ {
+			x0 := crop.Min.X + x*cw/w
+			x1 := max(x0+1, crop.Min.X+(x+1)*cw/w)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					c := color.NRGBA64Model.Convert(src.At(sx, sy)).(color.NRGBA64)
+					r += uint64(c.R)
+					g += uint64(c.G)
+					b += uint64(c.B)
+					a += uint64(c.A)
+					n++
+				}
+			}
+			dst.SetNRGBA(x, y, color.NRGBA{
+				R: uint8(r / n >> 8),
+				G: uint8(g / n >> 8),
+				B: uint8(b / n >> 8),
+				A: uint8(a / n >> 8),
+			})
+		}
+	}
+	return dst
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
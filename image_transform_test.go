@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"net/url"
+	"testing"
+)
+
+func TestParseImageTransform(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    url.Values
+		expected imageTransform
+		err      string
+	}{
+		{"width only", url.Values{"w": {"200"}}, imageTransform{width: 200, fit: "contain"}, ""},
+		{"all parameters", url.Values{"w": {"200"}, "h": {"100"}, "fit": {"cover"}, "format": {"jpg"}}, imageTransform{width: 200, height: 100, fit: "cover", format: "jpeg"}, ""},
+		{"no size", url.Values{"fit": {"cover"}}, imageTransform{}, "at least one of w and h is required"},
+		{"invalid width", url.Values{"w": {"abc"}}, imageTransform{}, "w and h must be numbers between 1 and 2000"},
+		{"height too big", url.Values{"h": {"2001"}}, imageTransform{}, "w and h must be numbers between 1 and 2000"},
+		{"invalid fit", url.Values{"w": {"10"}, "fit": {"stretch"}}, imageTransform{}, "fit must be contain, cover or fill"},
+		{"invalid format", url.Values{"w": {"10"}, "format": {"gif"}}, imageTransform{}, "format must be jpeg or png"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr, err := parseImageTransform(tt.query)
+			if tt.err != "" {
+				if _, ok := err.(validationError); !ok || err.Error() != tt.err {
+					t.Fatalf("expected validation error %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tr != tt.expected {
+				t.Errorf("expected %+v, got %+v", tt.expected, tr)
+			}
+		})
+	}
+}
+
+func TestImageTransformVariantName(t *testing.T) {
+	tr := imageTransform{width: 200, fit: "contain"}
+	if got := tr.variantName("abc.png"); got != "variants/abc/200x0-contain.png" {
+		t.Errorf("unexpected variant name: %v", got)
+	}
+	tr = imageTransform{width: 200, height: 100, fit: "cover", format: "jpeg"}
+	if got := tr.variantName("abc.png"); got != "variants/abc/200x100-cover.jpg" {
+		t.Errorf("unexpected variant name: %v", got)
+	}
+}
+
+func TestImageTransformSize(t *testing.T) {
+	src := image.Rect(0, 0, 400, 200)
+	tests := []struct {
+		name           string
+		transform      imageTransform
+		expectedWidth  int
+		expectedHeight int
+		expectedCrop   image.Rectangle
+	}{
+		{"width only", imageTransform{width: 100}, 100, 50, src},
+		{"height only", imageTransform{height: 100}, 200, 100, src},
+		{"contain", imageTransform{width: 100, height: 100, fit: "contain"}, 100, 50, src},
+		{"cover", imageTransform{width: 100, height: 100, fit: "cover"}, 100, 100, image.Rect(100, 0, 300, 200)},
+		{"fill", imageTransform{width: 100, height: 100, fit: "fill"}, 100, 100, src},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, h, crop := tt.transform.size(src)
+			if w != tt.expectedWidth || h != tt.expectedHeight || crop != tt.expectedCrop {
+				t.Errorf("expected %dx%d from %v, got %dx%d from %v", tt.expectedWidth, tt.expectedHeight, tt.expectedCrop, w, h, crop)
+			}
+		})
+	}
+}
+
+func TestImageTransformSize_ExtremeAspectRatio(t *testing.T) {
+	wide := image.Rect(0, 0, 10000, 1)
+	tall := image.Rect(0, 0, 3, 9000)
+	tests := []struct {
+		name           string
+		src            image.Rectangle
+		transform      imageTransform
+		expectedWidth  int
+		expectedHeight int
+	}{
+		// The computed side would be 20,000,000 pixels, so it is clamped and the requested one scaled down
+		{"wide by height", wide, imageTransform{height: 2000}, maxTransformDimension, 1},
+		{"wide by width", wide, imageTransform{width: 2000}, 2000, 1},
+		{"tall by width", tall, imageTransform{width: 2000}, 1, maxTransformDimension},
+		{"tall by height", tall, imageTransform{height: 1500}, 1, 1500},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, h, crop := tt.transform.size(tt.src)
+			if w != tt.expectedWidth || h != tt.expectedHeight || crop != tt.src {
+				t.Errorf("expected %dx%d, got %dx%d from %v", tt.expectedWidth, tt.expectedHeight, w, h, crop)
+			}
+		})
+	}
+}
+
+func TestImageTransformApply_SourceTooBig(t *testing.T) {
+	// Only the header is read: an image with too many pixels is rejected before it is decoded
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	header := buf.Bytes()
+	// The IHDR chunk has the width and height after the 8 bytes of the signature and 8 of the chunk header,
+	// and is followed by the checksum of its type and data
+	binary.BigEndian.PutUint32(header[16:], 100000)
+	binary.BigEndian.PutUint32(header[20:], 100000)
+	binary.BigEndian.PutUint32(header[29:], crc32.ChecksumIEEE(header[12:29]))
+
+	_, err := imageTransform{width: 100, fit: "contain"}.apply(bytes.NewReader(header), "png")
+	if err == nil || err.Error() != "image is too big to be transformed" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestResize(t *testing.T) {
+	// Left half black, right half white
+	src := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 4; x++ {
+			v := uint8(0)
+			if x >= 2 {
+				v = 255
+			}
+			src.SetNRGBA(x, y, color.NRGBA{v, v, v, 255})
+		}
+	}
+
+	dst := resize(src, src.Bounds(), 2, 1)
+	if dst.Bounds() != image.Rect(0, 0, 2, 1) {
+		t.Fatalf("unexpected size: %v", dst.Bounds())
+	}
+	if got := dst.NRGBAAt(0, 0); got != (color.NRGBA{0, 0, 0, 255}) {
+		t.Errorf("unexpected left pixel: %v", got)
+	}
+	if got := dst.NRGBAAt(1, 0); got != (color.NRGBA{255, 255, 255, 255}) {
+		t.Errorf("unexpected right pixel: %v", got)
+	}
+
+	// Every pixel of a single pixel image is the average of the whole source
+	dst = resize(src, src.Bounds(), 1, 1)
+	if got := dst.NRGBAAt(0, 0); got.R != 127 || got.A != 255 {
+		t.Errorf("unexpected average pixel: %v", got)
+	}
+}
+
+func TestImageTransformApply(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 400, 200))); err != nil {
+		t.Fatal(err)
+	}
+
+	tr := imageTransform{width: 100, fit: "contain"}
+	out, err := tr.apply(bytes.NewReader(buf.Bytes()), "jpeg")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != "jpeg" || cfg.Width != 100 || cfg.Height != 50 {
+		t.Errorf("expected a 100x50 jpeg, got a %dx%d %s", cfg.Width, cfg.Height, format)
+	}
+
+	_, err = tr.apply(bytes.NewReader([]byte("GIF89a")), "png")
+	if _, ok := err.(validationError); !ok {
+		t.Errorf("expected a validation error for an unsupported image, got %v", err)
+	}
+}
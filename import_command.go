@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// runImportCommand runs the import command, which imports a catalog file like the import endpoint:
+//
+//	ceramics-store-system import [-format csv|jsonl] [-dry-run] [-actor name] <file>
+//
+// The format defaults to the extension of the file, and the actor recorded in the history of the products to the
+// user running the command. The report is written to stdout as JSON, and errors to stderr.
+// It returns the exit status of the command: 0 if the file was imported (or checked, with -dry-run),
+// 1 if any row is invalid and 2 if the command could not run.
+func runImportCommand(ctx context.Context, db *sql.DB, cache productCache, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	format := flags.String("format", "", "format of the file, csv or jsonl (default: the file extension)")
+	dryRun := flags.Bool("dry-run", false, "check every row and report what would be imported, without importing it")
+	actor := flags.String("actor", os.Getenv("USER"), "name recorded in the history of the changed products")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintln(stderr, "usage: ceramics-store-system import [-format csv|jsonl] [-dry-run] [-actor name] <file>")
+		return 2
+	}
+	path := flags.Arg(0)
+
+	if *format == "" {
+		*format = filepath.Ext(path)
+		if len(*format) > 0 {
+			*format = (*format)[1:]
+		}
+	}
+	parsedFormat, err := parseImportFormat(*format)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	defer f.Close()
+
+	if *actor == "" {
+		*actor = "import"
+	}
+	report, err := runImport(ctx, db, *actor, f, parsedFormat, *dryRun)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	// Drop the cached responses that include the imported products
+	if !report.DryRun && !report.failed() {
+		cache.invalidate(ctx, report.productIDs...)
+	}
+
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	if report.failed() {
+		return 1
+	}
+	return 0
+}
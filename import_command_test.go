@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunImportCommand(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	path := filepath.Join(t.TempDir(), "catalog.csv")
+	if err := os.WriteFile(path, []byte(importTestFile), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	rows := importTestRows()
+	expectImportSKUs(mock, textArray{"TAZA-AZUL-S", "TAZA-AZUL-L"}, nil)
+	expectImportSlugs(mock, textArray{"taza-azul"}, nil)
+	expectActor(mock, "ana")
+	expectImportProduct(mock, rows[0], 7, true)
+	expectImportVariant(mock, rows[0], 7, nil)
+	expectImportProduct(mock, rows[1], 7, false)
+	expectImportVariant(mock, rows[1], 7, nil)
+	mock.ExpectRollback()
+
+	// The format is taken from the extension of the file
+	var stdout, stderr bytes.Buffer
+	status := runImportCommand(context.Background(), db, productCache{}, []string{"-dry-run", "-actor", "ana", path}, &stdout, &stderr)
+
+	if status != 0 {
+		t.Errorf("unexpected status: got %d want 0, stderr: %s", status, stderr.String())
+	}
+	expected := `{
+  "dry_run": true,
+  "rows": 2,
+  "products": {
+    "created": 1,
+    "updated": 0
+  },
+  "variants": {
+    "created": 2,
+    "updated": 0
+  },
+  "errors": []
+}
+`
+	if stdout.String() != expected {
+		t.Errorf("unexpected report: got %s want %s", stdout.String(), expected)
+	}
+	checkMockExpectations(t, mock)
+}
+
+func TestRunImportCommand_Errors(t *testing.T) {
+	dir := t.TempDir()
+	invalid := filepath.Join(dir, "catalog.jsonl")
+	if err := os.WriteFile(invalid, []byte(`{"price":"1"}`+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		args           []string
+		expectedStatus int
+		expectedStderr string
+	}{
+		{name: "Missing file", args: []string{}, expectedStatus: 2, expectedStderr: "usage: ceramics-store-system import [-format csv|jsonl] [-dry-run] [-actor name] <file>\n"},
+		{name: "Unknown extension", args: []string{filepath.Join(dir, "catalog.xlsx")}, expectedStatus: 2, expectedStderr: "format must be csv or jsonl\n"},
+		{name: "File not found", args: []string{"-format", "csv", filepath.Join(dir, "missing")}, expectedStatus: 2, expectedStderr: "open " + filepath.Join(dir, "missing") + ": no such file or directory\n"},
+		{name: "Invalid rows", args: []string{invalid}, expectedStatus: 1, expectedStderr: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			status := runImportCommand(context.Background(), nil, productCache{}, tt.args, &stdout, &stderr)
+
+			if status != tt.expectedStatus {
+				t.Errorf("unexpected status: got %d want %d", status, tt.expectedStatus)
+			}
+			if stderr.String() != tt.expectedStderr {
+				t.Errorf("unexpected stderr: got %q want %q", stderr.String(), tt.expectedStderr)
+			}
+		})
+	}
+}
@@ -1,9 +1,12 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"expvar"
 	"log"
 	"net/http"
+	"os"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -12,20 +15,50 @@ import (
 )
 
 type Product struct {
-	ID             int       `json:"id"`
-	Name           string    `json:"name"`
-	Price          float64   `json:"price"`
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	// Slug identifies the product in URLs. It is unique, and generated from the name unless given.
+	Slug           string    `json:"slug"`
+	Price          Money     `json:"price"`
 	Description    string    `json:"description"`
 	Categories     []string  `json:"categories"`
 	Images         []string  `json:"images"`
 	ReferencedName string    `json:"referenced_name"`
 	DateAdded      time.Time `json:"date_added"`
+	// Status is draft, published or archived. Published products are only shown between PublishAt and UnpublishAt, when set.
+	Status      string     `json:"status"`
+	PublishAt   *time.Time `json:"publish_at,omitempty"`
+	UnpublishAt *time.Time `json:"unpublish_at,omitempty"`
+	// AverageRating and ReviewCount summarize the approved reviews. AverageRating is 0 without reviews.
+	AverageRating float64 `json:"average_rating"`
+	ReviewCount   int     `json:"review_count"`
+	// AvailableQuantity is the number of units on hand that are not reserved by a shopping cart.
+	AvailableQuantity int `json:"available_quantity"`
+	// Variants are only loaded by getProduct and getProducts.
+	Variants []ProductVariant `json:"variants,omitempty"`
+	// ExchangeRate is only set when the prices were converted to another currency, and has the rate that was used.
+	ExchangeRate *ExchangeRate `json:"exchange_rate,omitempty"`
+	// Rank and Snippet are only set when the products are the result of a full-text search.
+	Rank    float32 `json:"rank,omitempty"`
+	Snippet string  `json:"snippet,omitempty"`
+}
+
+type Category struct {
+	ID           int        `json:"id"`
+	Slug         string     `json:"slug"`
+	Name         string     `json:"name"`
+	ParentID     *int       `json:"parent_id,omitempty"`
+	SortOrder    int        `json:"sort_order"`
+	ProductCount int        `json:"product_count"`
+	Children     []Category `json:"children,omitempty"`
 }
 
 type ShoppingCartItem struct {
-	ID               int `json:"id,omitempty"`
-	ShoppingCartID   int `json:"shopping_cart_id,omitempty"`
-	ProductID        int `json:"product_id,omitempty"`
+	ID             int `json:"id,omitempty"`
+	ShoppingCartID int `json:"shopping_cart_id,omitempty"`
+	ProductID      int `json:"product_id,omitempty"`
+	// VariantID is the variant of the product that was chosen, if the product has variants.
+	VariantID        int `json:"variant_id,omitempty"`
 	NumberOfProducts int `json:"number_of_products,omitempty"`
 }
 
@@ -34,10 +67,31 @@ type ShoppingCart struct {
 	UserID            int                `json:"user_id,omitempty"`
 	IPAddress         string             `json:"ip_address,omitempty"`
 	ShoppingCartItems []ShoppingCartItem `json:"shopping_cart_items,omitempty"`
+	// UnreservedItems are the items whose stock could not be reserved when the cart was saved.
+	UnreservedItems []ShoppingCartItem `json:"unreserved_items,omitempty"`
 }
 
 type ProductsHandler struct {
-	db *sql.DB
+	db    *sql.DB
+	cache productCache
+	// admin tells the admin requests apart, which also see the products that are not live.
+	admin adminAuth
+}
+
+type CategoriesHandler struct {
+	db    *sql.DB
+	cache productCache
+}
+
+type ImagesHandler struct {
+	db      *sql.DB
+	storage ImageStorage
+	cache   productCache
+}
+
+type ExchangeRatesHandler struct {
+	db    *sql.DB
+	cache productCache
 }
 
 type ShoppingCartsHandler struct {
@@ -62,17 +116,73 @@ func main() {
 	redisClient := redis.NewClient(&redis.Options{
 		Addr: "redis_db:6379",
 	})
+	cache := productCache{client: redisClient}
+
+	// Run the import command instead of the server, e.g. ceramics-store-system import -dry-run collection.csv
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		status := runImportCommand(context.Background(), db, cache, os.Args[2:], os.Stdout, os.Stderr)
+		db.Close()
+		os.Exit(status)
+	}
+
+	// Open the storage for uploaded images
+	imagesDir := os.Getenv("IMAGES_DIR")
+	if imagesDir == "" {
+		imagesDir = "images"
+	}
+	imageStorage, err := newLocalImageStorage(imagesDir)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	// Initialize router
 	r := mux.NewRouter()
 
-	ph := ProductsHandler{db: db}
+	admin := adminAuth{token: os.Getenv("ADMIN_API_TOKEN")}
+	ph := ProductsHandler{db: db, cache: cache, admin: admin}
+	ch := CategoriesHandler{db: db, cache: cache}
+	ih := ImagesHandler{db: db, storage: imageStorage, cache: cache}
 	sch := ShoppingCartsHandler{db: db, redisClient: redisClient}
+	eh := ExchangeRatesHandler{db: db, cache: cache}
 
 	// Define endpoint for getting all products
-	r.HandleFunc("/products", ph.getProducts).Methods(http.MethodGet)
+	r.HandleFunc("/products", ph.getProducts).Methods(http.MethodGet, http.MethodHead)
+	// Define endpoint for exporting the products as a file, before /products/{id} so it isn't taken for an ID
+	r.HandleFunc("/products/export", ph.exportProducts).Methods(http.MethodGet)
 	// Define endpoint for getting a single product by ID
-	r.HandleFunc("/products/{id}", ph.getProduct).Methods(http.MethodGet)
+	r.HandleFunc("/products/{id}", ph.getProduct).Methods(http.MethodGet, http.MethodHead)
+	// Define endpoint for getting a single product by its slug, or one of its old slugs
+	r.HandleFunc("/products/by-slug/{slug}", ph.getProductBySlug).Methods(http.MethodGet, http.MethodHead)
+	// Define endpoint for getting the products related to a product, after by-slug so it doesn't shadow the slug "related"
+	r.HandleFunc("/products/{id}/related", ph.getRelatedProducts).Methods(http.MethodGet, http.MethodHead)
+	// Define endpoints for listing and writing the reviews of a product, shown once an admin approves them
+	r.HandleFunc("/products/{id}/reviews", ph.getProductReviews).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc("/products/{id}/reviews", ph.createProductReview).Methods(http.MethodPost)
+	// Define admin endpoints for managing the products catalog
+	r.HandleFunc("/products", admin.require(ph.createProduct)).Methods(http.MethodPost)
+	r.HandleFunc("/products/import", admin.require(ph.importProducts)).Methods(http.MethodPost)
+	r.HandleFunc("/products/{id}", admin.require(ph.updateProduct)).Methods(http.MethodPut)
+	r.HandleFunc("/products/{id}", admin.require(ph.patchProduct)).Methods(http.MethodPatch)
+	r.HandleFunc("/products/{id}", admin.require(ph.deleteProduct)).Methods(http.MethodDelete)
+	r.HandleFunc("/products/{id}/variants", admin.require(ph.createProductVariant)).Methods(http.MethodPost)
+	r.HandleFunc("/products/{id}/variants/{variant_id}", admin.require(ph.deleteProductVariant)).Methods(http.MethodDelete)
+	r.HandleFunc("/products/{id}/inventory_adjustments", admin.require(ph.adjustInventory)).Methods(http.MethodPost)
+	r.HandleFunc("/products/{id}/reviews/{review_id}", admin.require(ph.moderateProductReview)).Methods(http.MethodPatch)
+	r.HandleFunc("/products/{id}/translations/{locale}", admin.require(ph.putProductTranslation)).Methods(http.MethodPut)
+	r.HandleFunc("/products/{id}/history", admin.require(ph.getProductHistory)).Methods(http.MethodGet, http.MethodHead)
+	// Define endpoints for uploading and serving product images
+	r.HandleFunc("/products/{id}/images", admin.require(ih.uploadProductImages)).Methods(http.MethodPost)
+	r.HandleFunc("/images/{name}", ih.getImage).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc("/images/{name}/transform", ih.getImageTransform).Methods(http.MethodGet, http.MethodHead)
+	// Define endpoints for listing, creating and translating categories
+	r.HandleFunc("/categories", ch.getCategories).Methods(http.MethodGet)
+	r.HandleFunc("/categories", admin.require(ch.createCategory)).Methods(http.MethodPost)
+	r.HandleFunc("/categories/{slug}/translations/{locale}", admin.require(ch.putCategoryTranslation)).Methods(http.MethodPut)
+	// Define endpoints for listing and setting the exchange rates that prices are converted with
+	r.HandleFunc("/exchange_rates", eh.getExchangeRates).Methods(http.MethodGet)
+	r.HandleFunc("/exchange_rates/{currency}", admin.require(eh.putExchangeRate)).Methods(http.MethodPut)
+	// Define admin endpoint for the server metrics, such as the product cache hit ratio
+	r.HandleFunc("/debug/vars", admin.require(expvar.Handler().ServeHTTP)).Methods(http.MethodGet)
 	// Define endpoint for upserting a shopping cart in redis
 	r.HandleFunc("/shopping_carts", sch.upsertShoppingCartHandler).Methods(http.MethodPost)
 	// Define endpoint for getting a shopping cart from redis
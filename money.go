@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// storeCurrency is the currency every price is stored in.
+const storeCurrency = "COP"
+
+// priceScale is the number of decimals of the price column (DECIMAL(10,2)).
+const priceScale = 2
+
+// currencyExponents is the number of minor units digits of every supported currency (ISO 4217).
+var currencyExponents = map[string]int{
+	"COP": 2,
+	"USD": 2,
+	"EUR": 2,
+}
+
+// Money is an exact amount of money, stored as an integer number of minor units of its currency
+// (e.g. 999 for 9.99 COP), so prices and totals never go through binary floating point.
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+// exponent returns the number of minor units digits of the money's currency.
+func (m Money) exponent() int {
+	if e, ok := currencyExponents[m.Currency]; ok {
+		return e
+	}
+	return priceScale
+}
+
+// String formats the amount as a decimal number with the currency's number of decimals, e.g. "9.99".
+func (m Money) String() string {
+	exp := m.exponent()
+	amount := m.Amount
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	digits := strconv.FormatInt(amount, 10)
+	if exp == 0 {
+		return sign + digits
+	}
+	if len(digits) <= exp {
+		digits = strings.Repeat("0", exp-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-exp] + "." + digits[len(digits)-exp:]
+}
+
+// parseMoney parses a decimal number such as "9.99" or "-12" as an amount of the given currency.
+// It fails if the number has more decimals than the currency allows, instead of rounding it.
+func parseMoney(s, currency string) (Money, error) {
+	m := Money{Currency: currency}
+	exp := m.exponent()
+
+	str := strings.TrimSpace(s)
+	negative := strings.HasPrefix(str, "-")
+	str = strings.TrimPrefix(strings.TrimPrefix(str, "-"), "+")
+
+	whole, frac, _ := strings.Cut(str, ".")
+	// Trailing zeros don't change the value, e.g. DECIMAL columns scanned as "9.990"
+	frac = strings.TrimRight(frac, "0")
+	if whole == "" || len(frac) > exp || !isDigits(whole) || !isDigits(frac) {
+		return m, fmt.Errorf("invalid amount %q: must be a number with at most %d decimals", s, exp)
+	}
+	frac += strings.Repeat("0", exp-len(frac))
+
+	amount, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return m, fmt.Errorf("invalid amount %q: %w", s, err)
+	}
+	if negative {
+		amount = -amount
+	}
+	m.Amount = amount
+	return m, nil
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Scan converts a database value of a DECIMAL column to Money in the store currency.
+// Implements the database/sql Scanner interface.
+func (m *Money) Scan(value interface{}) error {
+	var s string
+	switch v := value.(type) {
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	case int64:
+		s = strconv.FormatInt(v, 10)
+	case float64:
+		// Some drivers return numeric values as floats, round them to the column scale
+		s = strconv.FormatFloat(v, 'f', priceScale, 64)
+	default:
+		return fmt.Errorf("failed to scan money field: unsupported type %T", value)
+	}
+
+	parsed, err := parseMoney(s, storeCurrency)
+	if err != nil {
+		return fmt.Errorf("failed to scan money field: %w", err)
+	}
+	*m = parsed
+	return nil
+}
+
+// Value converts Money to its decimal string representation so it can be written to a DECIMAL column.
+// Implements the database/sql/driver Valuer interface.
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+// moneyJSON is the object form of Money accepted in requests, with the amount and its currency.
+type moneyJSON struct {
+	Amount   json.RawMessage `json:"amount"`
+	Currency string          `json:"currency"`
+}
+
+// MarshalJSON encodes Money as its amount, an exact decimal string such as "9.99". The currency is not part of it:
+// prices are in the store currency unless their product has the exchange rate they were converted with.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.String())
+}
+
+// UnmarshalJSON decodes Money from the amount written by MarshalJSON, or from an object with the amount and
+// its currency.
+// Amounts can be strings or JSON numbers, and are parsed from their text so they are never rounded.
+// The currency defaults to the store currency.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	obj := moneyJSON{Amount: data, Currency: storeCurrency}
+	if bytes.HasPrefix(data, []byte("{")) {
+		if err := json.Unmarshal(data, &obj); err != nil {
+			return err
+		}
+		if obj.Currency == "" {
+			obj.Currency = storeCurrency
+		}
+	}
+
+	var amount string
+	if bytes.HasPrefix(obj.Amount, []byte(`"`)) {
+		if err := json.Unmarshal(obj.Amount, &amount); err != nil {
+			return err
+		}
+	} else {
+		var n json.Number
+		if err := json.Unmarshal(obj.Amount, &n); err != nil {
+			return fmt.Errorf("invalid amount: %s", obj.Amount)
+		}
+		amount = n.String()
+	}
+
+	// Numbers in exponent notation are not accepted, they are rarely exact
+	if strings.ContainsAny(amount, "eE") {
+		return fmt.Errorf("invalid amount %q: exponents are not supported", amount)
+	}
+
+	parsed, err := parseMoney(amount, strings.ToUpper(obj.Currency))
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
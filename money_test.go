@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		money    Money
+		expected string
+	}{
+		{money: Money{Amount: 999, Currency: "COP"}, expected: "9.99"},
+		{money: Money{Amount: 5, Currency: "COP"}, expected: "0.05"},
+		{money: Money{Amount: -1050, Currency: "USD"}, expected: "-10.50"},
+		{money: Money{Amount: 0, Currency: "COP"}, expected: "0.00"},
+	}
+
+	for _, tc := range tests {
+		if got := tc.money.String(); got != tc.expected {
+			t.Errorf("unexpected string, expected %v but got %v", tc.expected, got)
+		}
+	}
+}
+
+func TestMoneyScan(t *testing.T) {
+	tests := []struct {
+		value       interface{}
+		expected    Money
+		expectedErr bool
+	}{
+		{value: []byte("9.99"), expected: Money{Amount: 999, Currency: "COP"}},
+		{value: "14.9", expected: Money{Amount: 1490, Currency: "COP"}},
+		{value: int64(3), expected: Money{Amount: 300, Currency: "COP"}},
+		// 0.1 + 0.2 is not exact as a float, but it's rounded to the column scale
+		{value: 0.1 + 0.2, expected: Money{Amount: 30, Currency: "COP"}},
+		{value: "1.234", expectedErr: true},
+		{value: "invalid price", expectedErr: true},
+		{value: nil, expectedErr: true},
+	}
+
+	for _, tc := range tests {
+		var m Money
+		err := m.Scan(tc.value)
+		if (err != nil) != tc.expectedErr {
+			t.Errorf("unexpected error for %v: %v", tc.value, err)
+		}
+		if !tc.expectedErr && m != tc.expected {
+			t.Errorf("unexpected result for %v, expected %v but got %v", tc.value, tc.expected, m)
+		}
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	b, err := json.Marshal(Money{Amount: 1099, Currency: "COP"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `"10.99"` {
+		t.Errorf("unexpected JSON: %s", b)
+	}
+
+	tests := []struct {
+		data        string
+		expected    Money
+		expectedErr bool
+	}{
+		{data: `{"amount":"10.99","currency":"COP"}`, expected: Money{Amount: 1099, Currency: "COP"}},
+		{data: `{"amount":10.99,"currency":"usd"}`, expected: Money{Amount: 1099, Currency: "USD"}},
+		{data: `"35000"`, expected: Money{Amount: 3500000, Currency: "COP"}},
+		{data: `0.3`, expected: Money{Amount: 30, Currency: "COP"}},
+		{data: `{"amount":"1"}`, expected: Money{Amount: 100, Currency: "COP"}},
+		{data: `1e3`, expectedErr: true},
+		{data: `"1.001"`, expectedErr: true},
+		{data: `true`, expectedErr: true},
+	}
+
+	for _, tc := range tests {
+		var m Money
+		err := json.Unmarshal([]byte(tc.data), &m)
+		if (err != nil) != tc.expectedErr {
+			t.Errorf("unexpected error for %s: %v", tc.data, err)
+		}
+		if !tc.expectedErr && m != tc.expected {
+			t.Errorf("unexpected result for %s, expected %v but got %v", tc.data, tc.expected, m)
+		}
+	}
+}
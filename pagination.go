@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// productSort describes one of the orders supported by the products listing.
+//
+// Listings are paginated with keyset pagination: the cursor stores the sort key and the ID of the
+// product at the edge of a page, and the next page starts right after that (column, id) pair.
+// The ID is always used as a tiebreaker so the order is total.
+type productSort struct {
+	// column is the SQL expression the products are ordered by.
+	column string
+	// field is the product field of the column, which must be selected to build the cursors.
+	field string
+	// cast is the SQL type the cursor value is converted to before comparing it with column.
+	cast string
+	desc bool
+	// key returns the value of column for a product, as stored in the cursor.
+	key func(p Product) string
+}
+
+// productSorts maps every value accepted by the order query parameter to its sort.
+var productSorts = map[string]productSort{
+	"price_asc":  {column: "price", field: "price", cast: "numeric", desc: false, key: priceKey},
+	"price_desc": {column: "price", field: "price", cast: "numeric", desc: true, key: priceKey},
+	"date_asc":   {column: "date_added", field: "date_added", cast: "timestamptz", desc: false, key: dateAddedKey},
+	"date_desc":  {column: "date_added", field: "date_added", cast: "timestamptz", desc: true, key: dateAddedKey},
+	// rating puts the best rated products first
+	"rating": {column: "average_rating", field: "average_rating", cast: "numeric", desc: true, key: ratingKey},
+	// relevance needs a full-text search, see productFilter.selectProducts
+	"relevance": {column: "ts_rank(search_vector, query)", field: "rank", cast: "real", desc: true, key: rankKey},
+}
+
+// defaultProductOrder is used when the order query parameter is empty or unknown.
+const defaultProductOrder = "date_desc"
+
+func priceKey(p Product) string {
+	return p.Price.String()
+}
+
+func dateAddedKey(p Product) string {
+	return p.DateAdded.UTC().Format(time.RFC3339Nano)
+}
+
+func ratingKey(p Product) string {
+	return strconv.FormatFloat(p.AverageRating, 'f', 2, 64)
+}
+
+func rankKey(p Product) string {
+	return strconv.FormatFloat(float64(p.Rank), 'g', -1, 32)
+}
+
+// productCursor is the position of a page boundary. It is sent to clients as an opaque string.
+type productCursor struct {
+	Order string `json:"o"`
+	Value string `json:"v"`
+	ID    int    `json:"id"`
+	// Backward is true for cursors that point to the page before the boundary.
+	Backward bool `json:"b,omitempty"`
+}
+
+// encode returns the opaque string representation of the cursor.
+func (c productCursor) encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeProductCursor parses a cursor created by productCursor.encode.
+func decodeProductCursor(s string) (productCursor, error) {
+	c := productCursor{}
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, validationError{"invalid cursor"}
+	}
+	if err := json.Unmarshal(b, &c); err != nil || c.Value == "" {
+		return productCursor{}, validationError{"invalid cursor"}
+	}
+	return c, nil
+}
+
+// productPage is the JSON response of the products listing.
+type productPage struct {
+	Products   []Product `json:"products"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+	PrevCursor string    `json:"prev_cursor,omitempty"`
+	// Facets is only set when the listing was requested with the facets query parameter.
+	Facets *productFacets `json:"facets,omitempty"`
+}
+
+// pageRequest holds the pagination query parameters of a products listing.
+type pageRequest struct {
+	order  string
+	sort   productSort
+	limit  int
+	cursor *productCursor
+}
+
+// parsePageRequest reads the order, limit and cursor query parameters.
+//
+// Searches (with the q parameter) are ordered by relevance unless another order is given, and the relevance
+// order is only valid for searches. limit defaults to defaultPageLimit and can't be bigger than maxPageLimit.
+// A cursor can only be used with the order it was created for.
+func parsePageRequest(query url.Values) (pageRequest, error) {
+	pr := pageRequest{order: query.Get("order"), limit: defaultPageLimit}
+	search := strings.TrimSpace(query.Get("q")) != ""
+	if pr.order == "" && search {
+		pr.order = "relevance"
+	}
+	if pr.order == "relevance" && !search {
+		return pr, validationError{"order=relevance requires the q parameter"}
+	}
+	sort, ok := productSorts[pr.order]
+	if !ok {
+		pr.order = defaultProductOrder
+		sort = productSorts[pr.order]
+	}
+	pr.sort = sort
+
+	if l := query.Get("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			return pr, validationError{fmt.Sprintf("limit must be a number between 1 and %d", maxPageLimit)}
+		}
+		pr.limit = limit
+	}
+
+	if c := query.Get("cursor"); c != "" {
+		cursor, err := decodeProductCursor(c)
+		if err != nil {
+			return pr, err
+		}
+		if cursor.Order != pr.order {
+			return pr, validationError{"cursor does not match the requested order"}
+		}
+		pr.cursor = &cursor
+	}
+
+	return pr, nil
+}
+
+// apply adds the keyset condition, the ORDER BY and the LIMIT of the page to the query builder.
+// One extra row is requested to know whether there is another page in the same direction.
+func (pr pageRequest) apply(qb *queryBuilder) {
+	// Walking backwards flips the order, and the rows are reversed again in paginate
+	desc := pr.sort.desc
+	if pr.cursor != nil && pr.cursor.Backward {
+		desc = !desc
+	}
+
+	if pr.cursor != nil {
+		op := ">"
+		if desc {
+			op = "<"
+		}
+		qb.where(fmt.Sprintf("(%s, id) %s (?::%s, ?)", pr.sort.column, op, pr.sort.cast), pr.cursor.Value, pr.cursor.ID)
+	}
+
+	dir := " ASC"
+	if desc {
+		dir = " DESC"
+	}
+	qb.order(pr.sort.column+dir, "id"+dir)
+	qb.setLimit(pr.limit + 1)
+}
+
+// paginate trims the extra row requested by apply and builds the page with its cursors.
+func (pr pageRequest) paginate(products []Product) productPage {
+	hasMore := len(products) > pr.limit
+	if hasMore {
+		products = products[:pr.limit]
+	}
+
+	backward := pr.cursor != nil && pr.cursor.Backward
+	if backward {
+		for i, j := 0, len(products)-1; i < j; i, j = i+1, j-1 {
+			products[i], products[j] = products[j], products[i]
+		}
+	}
+
+	page := productPage{Products: products}
+	if len(products) == 0 {
+		return page
+	}
+
+	first, last := products[0], products[len(products)-1]
+	// There is a next page if the query found more rows going forward, or if we came back from it
+	if backward || hasMore {
+		page.NextCursor = productCursor{Order: pr.order, Value: pr.sort.key(last), ID: last.ID}.encode()
+	}
+	// There is a previous page if we came from it, or if the query found more rows going backward
+	if (!backward && pr.cursor != nil) || (backward && hasMore) {
+		page.PrevCursor = productCursor{Order: pr.order, Value: pr.sort.key(first), ID: first.ID, Backward: true}.encode()
+	}
+	return page
+}
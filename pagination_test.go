@@ -0,0 +1,154 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParsePageRequest_Errors(t *testing.T) {
+	priceCursor := productCursor{Order: "price_asc", Value: "10.00", ID: 1}.encode()
+	tests := []struct {
+		name        string
+		query       url.Values
+		expectedErr string
+	}{
+		{name: "Limit is not a number", query: url.Values{"limit": {"ten"}}, expectedErr: "limit must be a number between 1 and 100"},
+		{name: "Limit is too big", query: url.Values{"limit": {"101"}}, expectedErr: "limit must be a number between 1 and 100"},
+		{name: "Limit is zero", query: url.Values{"limit": {"0"}}, expectedErr: "limit must be a number between 1 and 100"},
+		{name: "Cursor is garbage", query: url.Values{"cursor": {"%%%"}}, expectedErr: "invalid cursor"},
+		{name: "Cursor is not JSON", query: url.Values{"cursor": {"bm90IGpzb24"}}, expectedErr: "invalid cursor"},
+		{name: "Cursor for another order", query: url.Values{"order": {"date_asc"}, "cursor": {priceCursor}}, expectedErr: "cursor does not match the requested order"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parsePageRequest(tt.query)
+			if err == nil || err.Error() != tt.expectedErr {
+				t.Errorf("unexpected error, expected %q but got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestPageRequestApply(t *testing.T) {
+	tests := []struct {
+		name          string
+		cursor        *productCursor
+		order         string
+		expectedQuery string
+		expectedArgs  []interface{}
+	}{
+		{
+			name:          "First page",
+			order:         "price_asc",
+			expectedQuery: "SELECT id FROM products ORDER BY price ASC, id ASC LIMIT $1",
+			expectedArgs:  []interface{}{3},
+		},
+		{
+			name:          "Next page",
+			order:         "price_asc",
+			cursor:        &productCursor{Order: "price_asc", Value: "10.00", ID: 4},
+			expectedQuery: "SELECT id FROM products WHERE (price, id) > ($1::numeric, $2) ORDER BY price ASC, id ASC LIMIT $3",
+			expectedArgs:  []interface{}{"10.00", 4, 3},
+		},
+		{
+			name:          "Previous page of a descending order",
+			order:         "date_desc",
+			cursor:        &productCursor{Order: "date_desc", Value: "2023-04-07T00:00:00Z", ID: 4, Backward: true},
+			expectedQuery: "SELECT id FROM products WHERE (date_added, id) > ($1::timestamptz, $2) ORDER BY date_added ASC, id ASC LIMIT $3",
+			expectedArgs:  []interface{}{"2023-04-07T00:00:00Z", 4, 3},
+		},
+		{
+			name:          "Next page by rating",
+			order:         "rating",
+			cursor:        &productCursor{Order: "rating", Value: "4.50", ID: 4},
+			expectedQuery: "SELECT id FROM products WHERE (average_rating, id) < ($1::numeric, $2) ORDER BY average_rating DESC, id DESC LIMIT $3",
+			expectedArgs:  []interface{}{"4.50", 4, 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pr := pageRequest{order: tt.order, sort: productSorts[tt.order], limit: 2, cursor: tt.cursor}
+			qb := newQueryBuilder("SELECT id FROM products")
+			pr.apply(qb)
+			query, args := qb.build()
+			if query != tt.expectedQuery {
+				t.Errorf("unexpected query: got %q want %q", query, tt.expectedQuery)
+			}
+			if !reflect.DeepEqual(args, tt.expectedArgs) {
+				t.Errorf("unexpected args: got %v want %v", args, tt.expectedArgs)
+			}
+		})
+	}
+}
+
+func TestPageRequestPaginate(t *testing.T) {
+	date := time.Date(2023, 4, 7, 0, 0, 0, 0, time.UTC)
+	products := func(ids ...int) []Product {
+		ps := []Product{}
+		for _, id := range ids {
+			ps = append(ps, Product{ID: id, Price: Money{Amount: int64(id) * 100, Currency: storeCurrency}, DateAdded: date})
+		}
+		return ps
+	}
+	cursor := func(id int, backward bool) string {
+		return productCursor{Order: "price_asc", Value: priceKey(Product{Price: Money{Amount: int64(id) * 100, Currency: storeCurrency}}), ID: id, Backward: backward}.encode()
+	}
+
+	tests := []struct {
+		name         string
+		cursor       *productCursor
+		rows         []Product
+		expectedPage productPage
+	}{
+		{
+			name:         "Single page",
+			rows:         products(1, 2),
+			expectedPage: productPage{Products: products(1, 2)},
+		},
+		{
+			name:         "First of several pages",
+			rows:         products(1, 2, 3),
+			expectedPage: productPage{Products: products(1, 2), NextCursor: cursor(2, false)},
+		},
+		{
+			name:         "Middle page going forward",
+			cursor:       &productCursor{Order: "price_asc", Value: "2.00", ID: 2},
+			rows:         products(3, 4, 5),
+			expectedPage: productPage{Products: products(3, 4), NextCursor: cursor(4, false), PrevCursor: cursor(3, true)},
+		},
+		{
+			name:         "First page reached going backward",
+			cursor:       &productCursor{Order: "price_asc", Value: "3.00", ID: 3, Backward: true},
+			rows:         products(2, 1),
+			expectedPage: productPage{Products: products(1, 2), NextCursor: cursor(2, false)},
+		},
+		{
+			name:         "Empty page",
+			cursor:       &productCursor{Order: "price_asc", Value: "9.00", ID: 9},
+			rows:         products(),
+			expectedPage: productPage{Products: products()},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pr := pageRequest{order: "price_asc", sort: productSorts["price_asc"], limit: 2, cursor: tt.cursor}
+			page := pr.paginate(tt.rows)
+			if !reflect.DeepEqual(page, tt.expectedPage) {
+				t.Errorf("unexpected page: got %+v want %+v", page, tt.expectedPage)
+			}
+		})
+	}
+}
+
+func TestGetProducts_InvalidLimit(t *testing.T) {
+	rr := makeRequest(t, nil, "/products?limit=1000")
+
+	checkResponseCode(t, rr.Code, http.StatusBadRequest)
+	checkResponseBody(t, rr.Body.String(), "limit must be a number between 1 and 100\n", nil)
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+// productPatch is the request body accepted when partially updating a product.
+// Fields that are absent from the body are left unchanged.
+type productPatch struct {
+	Name           *string   `json:"name"`
+	Price          *Money    `json:"price"`
+	Description    *string   `json:"description"`
+	Categories     *[]string `json:"categories"`
+	Images         *[]string `json:"images"`
+	ReferencedName *string   `json:"referenced_name"`
+	Slug           *string   `json:"slug"`
+	Status         *string   `json:"status"`
+	// PublishAt and UnpublishAt can be sent as null to clear them.
+	PublishAt   optionalTime `json:"publish_at"`
+	UnpublishAt optionalTime `json:"unpublish_at"`
+}
+
+// assignments returns the SET clauses for the fields present in the patch, binding their values to qb.
+func (p productPatch) assignments(qb *queryBuilder) []string {
+	sets := []string{}
+	if p.Name != nil {
+		sets = append(sets, "name = "+qb.bind(*p.Name))
+	}
+	if p.Price != nil {
+		sets = append(sets, "price = "+qb.bind(*p.Price))
+	}
+	if p.Description != nil {
+		sets = append(sets, "description = "+qb.bind(*p.Description))
+	}
+	if p.Categories != nil {
+		sets = append(sets, "categories = "+qb.bind(textArray(*p.Categories)))
+	}
+	if p.Images != nil {
+		sets = append(sets, "images = "+qb.bind(textArray(*p.Images)))
+	}
+	if p.ReferencedName != nil {
+		sets = append(sets, "referenced_name = "+qb.bind(*p.ReferencedName))
+	}
+	if p.Slug != nil {
+		sets = append(sets, "slug = "+qb.bind(*p.Slug))
+	}
+	if p.Status != nil {
+		sets = append(sets, "status = "+qb.bind(*p.Status))
+	}
+	if p.PublishAt.Set {
+		sets = append(sets, "publish_at = "+qb.bind(p.PublishAt.Time))
+	}
+	if p.UnpublishAt.Set {
+		sets = append(sets, "unpublish_at = "+qb.bind(p.UnpublishAt.Time))
+	}
+	return sets
+}
+
+// patchProduct handles the HTTP request for updating some of the fields of a product.
+//
+// It expects the ID of the product as a URL parameter and a JSON object with the fields to change
+// as the request body, and returns the stored product as a JSON response.
+// If the ID or the body are not valid, the body has no fields, it has categories that don't exist, or the product would be
+// unpublished before it is published, it returns an HTTP 400 Bad Request error.
+// If the product does not exist, it returns an HTTP 404 Not Found error.
+// If the slug is already used by another product, it returns an HTTP 409 Conflict error.
+// If there is an error while updating the product, it returns an HTTP 500 Internal Server Error.
+func (ph ProductsHandler) patchProduct(w http.ResponseWriter, r *http.Request) {
+	// Extract product ID from URL parameter
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+
+	var patch productPatch
+	err = decodeJSONBody(r, &patch)
+	if err == nil {
+		err = patch.validate()
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if patch.Categories != nil {
+		// Categories must exist in the categories table
+		err = checkCategoriesExist(ph.db, *patch.Categories)
+		var vErr validationError
+		if errors.As(err, &vErr) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		} else if err != nil {
+			log.Println(err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Build the UPDATE statement with only the fields present in the body
+	qb := newQueryBuilder("")
+	sets := patch.assignments(qb)
+	if len(sets) == 0 {
+		http.Error(w, "request body has no fields to update", http.StatusBadRequest)
+		return
+	}
+	qb.base = "UPDATE products SET " + strings.Join(sets, ", ")
+	qb.where("id = ?", id)
+	sqlQuery, args := qb.build()
+	sqlQuery += " RETURNING " + productColumns
+
+	// Execute query and read the product back as stored
+	p := Product{}
+	err = runAsActor(r.Context(), ph.db, requestActor(r), func(tx *sql.Tx) error {
+		return scanProduct(tx.QueryRow(sqlQuery, args...), &p)
+	})
+	var pqErr *pq.Error
+	if err == sql.ErrNoRows {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	} else if errors.As(err, &pqErr) && pqErr.Code == checkViolation {
+		// The new publish_at or unpublish_at is on the wrong side of the one already stored
+		http.Error(w, "unpublish_at must be after publish_at", http.StatusBadRequest)
+		return
+	} else if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		http.Error(w, "Slug already exists", http.StatusConflict)
+		return
+	} else if err != nil {
+		log.Println(err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Drop the cached responses that include the product
+	ph.cache.invalidate(r.Context(), p.ID)
+
+	// Encode and send response
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(p)
+	if err != nil {
+		log.Println(err)
+	}
+}
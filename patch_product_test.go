@@ -0,0 +1,130 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+func TestPatchProduct_Success(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	expected := getExpectedProducts()[1:]
+	expectActor(mock, "admin")
+	mock.ExpectQuery(regexp.QuoteMeta("UPDATE products SET price = $1, images = $2 WHERE id = $3 RETURNING "+productColumns)).
+		WithArgs("20.00", textArray{"img3", "img4"}, 2).
+		WillReturnRows(getMockRows(expected))
+	mock.ExpectCommit()
+
+	ph := ProductsHandler{db: db}
+	rr := serveProductRequest(t, ph.patchProduct, http.MethodPatch, "/products/2", `{"price":20,"images":["img3","img4"]}`, map[string]string{"id": "2"})
+
+	checkResponseCode(t, rr.Code, http.StatusOK)
+	expectedBody, _ := marshalLine(expected[0])
+	checkResponseBody(t, rr.Body.String(), expectedBody, nil)
+	checkMockExpectations(t, mock)
+}
+
+func TestPatchProduct_Status(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	// A null publish_at clears it, so the draft is published right away
+	expected := getExpectedProducts()[:1]
+	expectActor(mock, "admin")
+	mock.ExpectQuery(regexp.QuoteMeta("UPDATE products SET status = $1, publish_at = $2 WHERE id = $3 RETURNING "+productColumns)).
+		WithArgs(statusPublished, nil, 1).
+		WillReturnRows(getMockRows(expected))
+	mock.ExpectCommit()
+
+	ph := ProductsHandler{db: db}
+	rr := serveProductRequest(t, ph.patchProduct, http.MethodPatch, "/products/1", `{"status":"published","publish_at":null}`, map[string]string{"id": "1"})
+
+	checkResponseCode(t, rr.Code, http.StatusOK)
+	expectedBody, _ := marshalLine(expected[0])
+	checkResponseBody(t, rr.Body.String(), expectedBody, nil)
+	checkMockExpectations(t, mock)
+}
+
+func TestPatchProduct_PublishWindowViolation(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	expectActor(mock, "admin")
+	mock.ExpectQuery(regexp.QuoteMeta("UPDATE products SET unpublish_at = $1 WHERE id = $2")).
+		WillReturnError(&pq.Error{Code: checkViolation})
+	mock.ExpectRollback()
+
+	ph := ProductsHandler{db: db}
+	rr := serveProductRequest(t, ph.patchProduct, http.MethodPatch, "/products/1", `{"unpublish_at":"2023-01-01T00:00:00Z"}`, map[string]string{"id": "1"})
+
+	checkResponseCode(t, rr.Code, http.StatusBadRequest)
+	checkResponseBody(t, rr.Body.String(), "unpublish_at must be after publish_at\n", nil)
+	checkMockExpectations(t, mock)
+}
+
+func TestPatchProduct_NotFound(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	expectActor(mock, "admin")
+	mock.ExpectQuery("UPDATE products").WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	ph := ProductsHandler{db: db}
+	rr := serveProductRequest(t, ph.patchProduct, http.MethodPatch, "/products/7", `{"name":"Mug"}`, map[string]string{"id": "7"})
+
+	checkResponseCode(t, rr.Code, http.StatusNotFound)
+	checkMockExpectations(t, mock)
+}
+
+func TestPatchProduct_InvalidRequest(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		expectedBody string
+	}{
+		{name: "Empty patch", body: `{}`, expectedBody: "request body has no fields to update\n"},
+		{name: "Empty name", body: `{"name":" "}`, expectedBody: "name is required\n"},
+		{name: "Image with whitespace", body: `{"images":["a b.jpg"]}`, expectedBody: "images must be non-empty and must not contain whitespace\n"},
+		{name: "Unknown status", body: `{"status":"deleted"}`, expectedBody: "status must be draft, published or archived\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ph := ProductsHandler{db: nil}
+			rr := serveProductRequest(t, ph.patchProduct, http.MethodPatch, "/products/1", tt.body, map[string]string{"id": "1"})
+
+			checkResponseCode(t, rr.Code, http.StatusBadRequest)
+			checkResponseBody(t, rr.Body.String(), tt.expectedBody, nil)
+		})
+	}
+}
+
+func TestPatchProduct_Actor(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	// The change is recorded in the history as made by the actor of the request
+	expectActor(mock, "ana@example.com")
+	mock.ExpectQuery(regexp.QuoteMeta("UPDATE products SET price = $1 WHERE id = $2 RETURNING "+productColumns)).
+		WithArgs("38000.00", 1).
+		WillReturnRows(getMockRows(getExpectedProducts()[:1]))
+	mock.ExpectCommit()
+
+	req := httptest.NewRequest(http.MethodPatch, "/products/1", strings.NewReader(`{"price":38000}`))
+	req.Header.Set(actorHeader, "ana@example.com")
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	rr := httptest.NewRecorder()
+	ProductsHandler{db: db}.patchProduct(rr, req)
+
+	checkResponseCode(t, rr.Code, http.StatusOK)
+	checkMockExpectations(t, mock)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// uniqueViolation is the PostgreSQL error code raised when a row breaks a unique constraint.
+const uniqueViolation = "23505"
+
+// categorySlugPattern matches lowercase words separated by single dashes, e.g. "tea-cups".
+var categorySlugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// categoryInput is the request body accepted when creating a category.
+type categoryInput struct {
+	Slug      string `json:"slug"`
+	Name      string `json:"name"`
+	Parent    string `json:"parent"`
+	SortOrder int    `json:"sort_order"`
+}
+
+// validate checks the fields of a category input.
+func (in categoryInput) validate() error {
+	if !categorySlugPattern.MatchString(in.Slug) || len(in.Slug) > maxCategoryLength {
+		return validationError{fmt.Sprintf("slug must be lowercase letters and numbers separated by dashes, at most %d characters", maxCategoryLength)}
+	}
+	if strings.TrimSpace(in.Name) == "" {
+		return validationError{"name is required"}
+	}
+	if in.Parent == in.Slug {
+		return validationError{"a category can't be its own parent"}
+	}
+	return nil
+}
+
+// createCategory handles the HTTP request for adding a new category.
+//
+// The body has the slug, the display name, the slug of the parent category (optional) and the sort order.
+// It returns the stored category as a JSON response with an HTTP 201 Created status.
+// If the body is not valid or the parent does not exist, it returns an HTTP 400 Bad Request error.
+// If the slug is already used, it returns an HTTP 409 Conflict error.
+// If there is an error while inserting the category, it returns an HTTP 500 Internal Server Error.
+func (ch CategoriesHandler) createCategory(w http.ResponseWriter, r *http.Request) {
+	var in categoryInput
+	err := decodeJSONBody(r, &in)
+	if err == nil {
+		err = in.validate()
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c := Category{Slug: in.Slug, Name: in.Name, SortOrder: in.SortOrder}
+
+	// Resolve the parent slug
+	if in.Parent != "" {
+		var parentID int
+		err = ch.db.QueryRow("SELECT id FROM categories WHERE slug = $1", in.Parent).Scan(&parentID)
+		if err == sql.ErrNoRows {
+			http.Error(w, "unknown categories: "+in.Parent, http.StatusBadRequest)
+			return
+		} else if err != nil {
+			log.Println(err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		c.ParentID = &parentID
+	}
+
+	err = ch.db.QueryRow("INSERT INTO categories (slug, name, parent_id, sort_order) VALUES ($1, $2, $3, $4) RETURNING id",
+		c.Slug, c.Name, c.ParentID, c.SortOrder).Scan(&c.ID)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		http.Error(w, "Category already exists", http.StatusConflict)
+		return
+	} else if err != nil {
+		log.Println(err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Drop the cached listings, the products of the new category may now match the filters of its parent
+	ch.cache.invalidate(r.Context())
+
+	// Encode and send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(c)
+	if err != nil {
+		log.Println(err)
+	}
+}
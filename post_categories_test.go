@@ -0,0 +1,88 @@
+package main
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-redis/redismock/v8"
+	"github.com/lib/pq"
+)
+
+func TestCreateCategory_Success(t *testing.T) {
+	client, redisMock := redismock.NewClientMock()
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id FROM categories WHERE slug = $1")).WithArgs("tableware").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO categories (slug, name, parent_id, sort_order) VALUES ($1, $2, $3, $4) RETURNING id")).
+		WithArgs("mugs", "Mugs", 1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	// The cached listings are dropped
+	redisMock.ExpectIncr(productListGenerationKey).SetVal(1)
+
+	ch := CategoriesHandler{db: db, cache: productCache{client: client}}
+	rr := serveProductRequest(t, ch.createCategory, http.MethodPost, "/categories", `{"slug":"mugs","name":"Mugs","parent":"tableware","sort_order":2}`, nil)
+
+	parentID := 1
+	checkResponseCode(t, rr.Code, http.StatusCreated)
+	expectedBody, _ := marshalLine(Category{ID: 7, Slug: "mugs", Name: "Mugs", ParentID: &parentID, SortOrder: 2})
+	checkResponseBody(t, rr.Body.String(), expectedBody, nil)
+	checkMockExpectations(t, mock)
+	if err := redisMock.ExpectationsWereMet(); err != nil {
+		t.Errorf("redis expectations were not met: %s", err.Error())
+	}
+}
+
+func TestCreateCategory_Errors(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		setup          func(mock sqlmock.Sqlmock)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Invalid slug",
+			body:           `{"slug":"Tea Cups","name":"Tea cups"}`,
+			setup:          func(mock sqlmock.Sqlmock) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "slug must be lowercase letters and numbers separated by dashes, at most 100 characters\n",
+		},
+		{
+			name: "Unknown parent",
+			body: `{"slug":"tea-cups","name":"Tea cups","parent":"nope"}`,
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SELECT id FROM categories").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "unknown categories: nope\n",
+		},
+		{
+			name: "Slug already used",
+			body: `{"slug":"mugs","name":"Mugs"}`,
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("INSERT INTO categories").WillReturnError(&pq.Error{Code: uniqueViolation})
+			},
+			expectedStatus: http.StatusConflict,
+			expectedBody:   "Category already exists\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := getMockDB(t)
+			defer db.Close()
+			tt.setup(mock)
+
+			ch := CategoriesHandler{db: db}
+			rr := serveProductRequest(t, ch.createCategory, http.MethodPost, "/categories", tt.body, nil)
+
+			checkResponseCode(t, rr.Code, tt.expectedStatus)
+			checkResponseBody(t, rr.Body.String(), tt.expectedBody, nil)
+			checkMockExpectations(t, mock)
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+// checkViolation is the PostgreSQL error code raised when a row breaks a check constraint,
+// such as a stock going below zero.
+const checkViolation = "23514"
+
+// maxNoteLength is the maximum length of the note of an inventory adjustment.
+const maxNoteLength = 1000
+
+// inventoryReasons maps every reason an inventory adjustment can have to the sign of its change in stock.
+var inventoryReasons = map[string]int{
+	"received": 1,
+	"returned": 1,
+	"sold":     -1,
+	"broken":   -1,
+}
+
+// inventoryAdjustmentInput is the request body accepted when adjusting the stock of a product.
+type inventoryAdjustmentInput struct {
+	// VariantID adjusts the stock of one of the product variants instead of the product's.
+	VariantID int    `json:"variant_id"`
+	Reason    string `json:"reason"`
+	// Quantity is the number of units received, returned, sold or broken. It is always positive,
+	// the reason tells whether they are added to or taken out of the stock.
+	Quantity int    `json:"quantity"`
+	Note     string `json:"note"`
+}
+
+// validate checks every field of an inventory adjustment input.
+func (in inventoryAdjustmentInput) validate() error {
+	if _, ok := inventoryReasons[in.Reason]; !ok {
+		return validationError{"reason must be received, returned, sold or broken"}
+	}
+	if in.Quantity <= 0 {
+		return validationError{"quantity must be greater than zero"}
+	}
+	if in.VariantID < 0 {
+		return validationError{"variant_id must be a valid variant ID"}
+	}
+	if len(in.Note) > maxNoteLength {
+		return validationError{fmt.Sprintf("note must be at most %d characters", maxNoteLength)}
+	}
+	return nil
+}
+
+// inventoryAdjustment is a stored change of the stock of a product or variant.
+type inventoryAdjustment struct {
+	ID        int    `json:"id"`
+	ProductID int    `json:"product_id"`
+	VariantID int    `json:"variant_id,omitempty"`
+	Reason    string `json:"reason"`
+	// Quantity is positive when units were added to the stock and negative when they were taken out.
+	Quantity  int       `json:"quantity"`
+	Note      string    `json:"note"`
+	CreatedAt time.Time `json:"created_at"`
+	// Stock is the stock of the product or variant right after the adjustment.
+	Stock int `json:"stock"`
+}
+
+// adjustInventory handles the HTTP request for recording a change in the stock of a product or one of its variants.
+//
+// It expects the ID of the product as a URL parameter and a body with the reason and quantity of the change.
+// The stock is updated and the adjustment recorded in a single statement, so they can't get out of sync.
+// It returns the stored adjustment, with the resulting stock, as a JSON response with an HTTP 201 Created status.
+// If the ID or the body are not valid, it returns an HTTP 400 Bad Request error.
+// If the product or variant does not exist, it returns an HTTP 404 Not Found error.
+// If the adjustment would make the stock negative, it returns an HTTP 409 Conflict error.
+// If there is an error while storing the adjustment, it returns an HTTP 500 Internal Server Error.
+func (ph ProductsHandler) adjustInventory(w http.ResponseWriter, r *http.Request) {
+	// Extract product ID from URL parameter
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+
+	var in inventoryAdjustmentInput
+	err = decodeJSONBody(r, &in)
+	if err == nil {
+		err = in.validate()
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	a := inventoryAdjustment{ProductID: id, VariantID: in.VariantID, Reason: in.Reason, Quantity: inventoryReasons[in.Reason] * in.Quantity, Note: in.Note}
+
+	// Update the stock and record the adjustment only if the product or variant exists
+	var variantID interface{}
+	notFound := "Product not found"
+	updateStock := "UPDATE products SET stock_quantity = stock_quantity + $1 WHERE id = $2 RETURNING stock_quantity AS stock"
+	if in.VariantID != 0 {
+		variantID = in.VariantID
+		notFound = "Variant not found"
+		updateStock = "UPDATE product_variants SET stock = stock + $1 WHERE product_id = $2 AND id = $5 RETURNING stock"
+	}
+	sqlQuery := "WITH updated AS (" + updateStock + ") " +
+		"INSERT INTO inventory_adjustments (product_id, variant_id, reason, quantity, note) " +
+		"SELECT $2, $5::int, $3, $1, $4 FROM updated RETURNING id, created_at, (SELECT stock FROM updated)"
+	err = runAsActor(r.Context(), ph.db, requestActor(r), func(tx *sql.Tx) error {
+		return tx.QueryRow(sqlQuery, a.Quantity, id, a.Reason, a.Note, variantID).Scan(&a.ID, &a.CreatedAt, &a.Stock)
+	})
+	var pqErr *pq.Error
+	if err == sql.ErrNoRows {
+		http.Error(w, notFound, http.StatusNotFound)
+		return
+	} else if errors.As(err, &pqErr) && pqErr.Code == checkViolation {
+		http.Error(w, "Not enough stock", http.StatusConflict)
+		return
+	} else if err != nil {
+		log.Println(err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Drop the cached responses that include the product
+	ph.cache.invalidate(r.Context(), id)
+
+	// Encode and send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(a)
+	if err != nil {
+		log.Println(err)
+	}
+}
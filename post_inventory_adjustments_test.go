@@ -0,0 +1,118 @@
+package main
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+func TestAdjustInventory(t *testing.T) {
+	createdAt := time.Date(2023, 5, 24, 10, 0, 0, 0, time.UTC)
+	productQuery := regexp.QuoteMeta("WITH updated AS (UPDATE products SET stock_quantity = stock_quantity + $1 WHERE id = $2 RETURNING stock_quantity AS stock) " +
+		"INSERT INTO inventory_adjustments (product_id, variant_id, reason, quantity, note) SELECT $2, $5::int, $3, $1, $4 FROM updated RETURNING id, created_at, (SELECT stock FROM updated)")
+	variantQuery := regexp.QuoteMeta("WITH updated AS (UPDATE product_variants SET stock = stock + $1 WHERE product_id = $2 AND id = $5 RETURNING stock) " +
+		"INSERT INTO inventory_adjustments (product_id, variant_id, reason, quantity, note) SELECT $2, $5::int, $3, $1, $4 FROM updated RETURNING id, created_at, (SELECT stock FROM updated)")
+	resultRows := func(stock int) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "created_at", "stock"}).AddRow(3, createdAt, stock)
+	}
+
+	received, _ := marshalLine(inventoryAdjustment{ID: 3, ProductID: 1, Reason: "received", Quantity: 5, Note: "new batch", CreatedAt: createdAt, Stock: 6})
+	broken, _ := marshalLine(inventoryAdjustment{ID: 3, ProductID: 1, VariantID: 2, Reason: "broken", Quantity: -1, CreatedAt: createdAt, Stock: 0})
+
+	tests := []struct {
+		name           string
+		body           string
+		setup          func(mock sqlmock.Sqlmock)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "Received units are added",
+			body: `{"reason":"received","quantity":5,"note":"new batch"}`,
+			setup: func(mock sqlmock.Sqlmock) {
+				expectActor(mock, "admin")
+				mock.ExpectQuery(productQuery).WithArgs(5, 1, "received", "new batch", nil).WillReturnRows(resultRows(6))
+				mock.ExpectCommit()
+			},
+			expectedStatus: http.StatusCreated,
+			expectedBody:   received,
+		},
+		{
+			name: "Broken units of a variant are taken out",
+			body: `{"variant_id":2,"reason":"broken","quantity":1}`,
+			setup: func(mock sqlmock.Sqlmock) {
+				expectActor(mock, "admin")
+				mock.ExpectQuery(variantQuery).WithArgs(-1, 1, "broken", "", 2).WillReturnRows(resultRows(0))
+				mock.ExpectCommit()
+			},
+			expectedStatus: http.StatusCreated,
+			expectedBody:   broken,
+		},
+		{
+			name:           "Unknown reason",
+			body:           `{"reason":"lost","quantity":1}`,
+			setup:          func(mock sqlmock.Sqlmock) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "reason must be received, returned, sold or broken\n",
+		},
+		{
+			name:           "Zero quantity",
+			body:           `{"reason":"sold","quantity":0}`,
+			setup:          func(mock sqlmock.Sqlmock) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "quantity must be greater than zero\n",
+		},
+		{
+			name: "Product not found",
+			body: `{"reason":"sold","quantity":1}`,
+			setup: func(mock sqlmock.Sqlmock) {
+				expectActor(mock, "admin")
+				mock.ExpectQuery(productQuery).WithArgs(-1, 1, "sold", "", nil).WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "stock"}))
+				mock.ExpectRollback()
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   "Product not found\n",
+		},
+		{
+			name: "Variant not found",
+			body: `{"variant_id":9,"reason":"sold","quantity":1}`,
+			setup: func(mock sqlmock.Sqlmock) {
+				expectActor(mock, "admin")
+				mock.ExpectQuery(variantQuery).WithArgs(-1, 1, "sold", "", 9).WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "stock"}))
+				mock.ExpectRollback()
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   "Variant not found\n",
+		},
+		{
+			name: "Not enough stock",
+			body: `{"reason":"sold","quantity":2}`,
+			setup: func(mock sqlmock.Sqlmock) {
+				expectActor(mock, "admin")
+				mock.ExpectQuery(productQuery).WithArgs(-2, 1, "sold", "", nil).WillReturnError(&pq.Error{Code: checkViolation})
+				mock.ExpectRollback()
+			},
+			expectedStatus: http.StatusConflict,
+			expectedBody:   "Not enough stock\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := getMockDB(t)
+			defer db.Close()
+			tt.setup(mock)
+
+			ph := ProductsHandler{db: db}
+			rr := serveProductRequest(t, ph.adjustInventory, http.MethodPost, "/products/1/inventory_adjustments", tt.body, map[string]string{"id": "1"})
+
+			checkResponseCode(t, rr.Code, tt.expectedStatus)
+			checkResponseBody(t, rr.Body.String(), tt.expectedBody, nil)
+			checkMockExpectations(t, mock)
+		})
+	}
+}
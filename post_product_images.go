@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// maxImageSize is the maximum size of a single uploaded image.
+	maxImageSize = 10 << 20
+	// maxUploadSize is the maximum size of a whole upload request.
+	maxUploadSize = 50 << 20
+)
+
+// imageExtensions maps the accepted image content types to the extension of their stored files.
+var imageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// readUploadedImage reads an uploaded file and checks its size and content type.
+//
+// The content type is detected from the content instead of trusting the one sent by the client.
+// It returns the content and the name the image is stored with: the SHA-256 of the content plus the
+// extension of its type, so the same image is only stored once and its URL never changes content.
+func readUploadedImage(fh *multipart.FileHeader) ([]byte, string, error) {
+	f, err := fh.Open()
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+
+	content, err := io.ReadAll(io.LimitReader(f, maxImageSize+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(content) > maxImageSize {
+		return nil, "", validationError{fmt.Sprintf("%s is bigger than %d MB", fh.Filename, maxImageSize>>20)}
+	}
+
+	ext, ok := imageExtensions[http.DetectContentType(content)]
+	if !ok {
+		return nil, "", validationError{fmt.Sprintf("%s is not a JPEG, PNG, GIF or WebP image", fh.Filename)}
+	}
+
+	sum := sha256.Sum256(content)
+	return content, hex.EncodeToString(sum[:]) + ext, nil
+}
+
+// uploadProductImages handles the HTTP request for adding images to a product.
+//
+// It expects the ID of the product as a URL parameter and a multipart/form-data body with one or more files
+// in the images field. Every file is validated, stored, and appended to the product images, and the updated
+// product is returned as a JSON response. Images the product already has are not added twice.
+// If the ID or the files are not valid, it returns an HTTP 400 Bad Request error.
+// If the request is too big, it returns an HTTP 413 Request Entity Too Large error.
+// If the product does not exist, it returns an HTTP 404 Not Found error.
+// If there is an error while storing the images or updating the product, it returns an HTTP 500 Internal Server Error.
+func (ih ImagesHandler) uploadProductImages(w http.ResponseWriter, r *http.Request) {
+	// Extract product ID from URL parameter
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+
+	// Parse the multipart form, keeping at most maxImageSize in memory
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	err = r.ParseMultipartForm(maxImageSize)
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		http.Error(w, fmt.Sprintf("request body must be at most %d MB", maxUploadSize>>20), http.StatusRequestEntityTooLarge)
+		return
+	} else if err != nil {
+		http.Error(w, "request body must be multipart/form-data", http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	files := r.MultipartForm.File["images"]
+	if len(files) == 0 {
+		http.Error(w, "images field is required", http.StatusBadRequest)
+		return
+	}
+
+	// Get the current images, which also checks that the product exists
+	var current textArray
+	err = ih.db.QueryRow("SELECT images FROM products WHERE id = $1", id).Scan(&current)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Println(err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	existing := map[string]bool{}
+	for _, name := range current {
+		existing[name] = true
+	}
+
+	// Validate every file before storing any of them
+	contents := map[string][]byte{}
+	added := textArray{}
+	for _, fh := range files {
+		content, name, err := readUploadedImage(fh)
+		var vErr validationError
+		if errors.As(err, &vErr) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		} else if err != nil {
+			log.Println(err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if existing[name] {
+			continue
+		}
+		existing[name] = true
+		contents[name] = content
+		added = append(added, name)
+	}
+
+	// Store the files
+	for _, name := range added {
+		err = ih.storage.Save(name, bytes.NewReader(contents[name]))
+		if err != nil {
+			log.Println(err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Append the new images to the product
+	sqlQuery := "UPDATE products SET images = COALESCE(images, '{}') || $1::text[] WHERE id = $2 RETURNING " + productColumns
+	p := Product{}
+	err = runAsActor(r.Context(), ih.db, requestActor(r), func(tx *sql.Tx) error {
+		return scanProduct(tx.QueryRow(sqlQuery, added, id), &p)
+	})
+	if err == sql.ErrNoRows {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Println(err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Drop the cached responses that include the product
+	ih.cache.invalidate(r.Context(), p.ID)
+
+	// Encode and send response
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(p)
+	if err != nil {
+		log.Println(err)
+	}
+}
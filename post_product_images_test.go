@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+)
+
+// getPNG returns a small PNG image of the given size.
+func getPNG(t *testing.T, width, height int) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// makeUploadRequest returns a multipart request that uploads the given files in the images field.
+func makeUploadRequest(t *testing.T, id string, files map[string][]byte) *http.Request {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for name, content := range files {
+		fw, err := mw.CreateFormFile("images", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write(content)
+	}
+	mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/products/"+id+"/images", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return mux.SetURLVars(req, map[string]string{"id": id})
+}
+
+func TestUploadProductImages_Success(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+	storage, err := newLocalImageStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content := getPNG(t, 2, 2)
+	sum := sha256.Sum256(content)
+	name := hex.EncodeToString(sum[:]) + ".png"
+
+	expected := getExpectedProducts()[:1]
+	expected[0].Images = []string{"img1", name}
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT images FROM products WHERE id = $1")).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"images"}).AddRow([]byte("{img1}")))
+	expectActor(mock, "admin")
+	mock.ExpectQuery(regexp.QuoteMeta("UPDATE products SET images = COALESCE(images, '{}') || $1::text[] WHERE id = $2 RETURNING "+productColumns)).
+		WithArgs(textArray{name}, 1).
+		WillReturnRows(getMockRows(expected))
+	mock.ExpectCommit()
+
+	ih := ImagesHandler{db: db, storage: storage}
+	rr := httptest.NewRecorder()
+	ih.uploadProductImages(rr, makeUploadRequest(t, "1", map[string][]byte{"mug.png": content}))
+
+	checkResponseCode(t, rr.Code, http.StatusOK)
+	expectedBody, _ := marshalLine(expected[0])
+	checkResponseBody(t, rr.Body.String(), expectedBody, nil)
+	checkMockExpectations(t, mock)
+
+	// The image was stored under its hash
+	f, _, err := storage.Open(name)
+	if err != nil {
+		t.Fatalf("image was not stored: %v", err)
+	}
+	f.Close()
+}
+
+func TestUploadProductImages_InvalidFile(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT images FROM products WHERE id = $1")).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"images"}).AddRow([]byte("{}")))
+
+	ih := ImagesHandler{db: db, storage: nil}
+	rr := httptest.NewRecorder()
+	ih.uploadProductImages(rr, makeUploadRequest(t, "1", map[string][]byte{"notes.txt": []byte("not an image")}))
+
+	checkResponseCode(t, rr.Code, http.StatusBadRequest)
+	checkResponseBody(t, rr.Body.String(), "notes.txt is not a JPEG, PNG, GIF or WebP image\n", nil)
+	checkMockExpectations(t, mock)
+}
+
+func TestUploadProductImages_ProductNotFound(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT images FROM products WHERE id = $1")).WithArgs(9).
+		WillReturnRows(sqlmock.NewRows([]string{"images"}))
+
+	ih := ImagesHandler{db: db, storage: nil}
+	rr := httptest.NewRecorder()
+	ih.uploadProductImages(rr, makeUploadRequest(t, "9", map[string][]byte{"mug.png": getPNG(t, 1, 1)}))
+
+	checkResponseCode(t, rr.Code, http.StatusNotFound)
+	checkMockExpectations(t, mock)
+}
+
+func TestUploadProductImages_MissingFiles(t *testing.T) {
+	ih := ImagesHandler{db: nil, storage: nil}
+	rr := httptest.NewRecorder()
+	ih.uploadProductImages(rr, makeUploadRequest(t, "1", nil))
+
+	checkResponseCode(t, rr.Code, http.StatusBadRequest)
+	checkResponseBody(t, rr.Body.String(), "images field is required\n", nil)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// createProductReview handles the HTTP request for reviewing a product.
+//
+// It expects the ID of the product as a URL parameter and a body with the rating (1 to 5), title, body and author
+// of the review. New reviews are pending until an admin approves them, so they don't count in the product rating yet.
+// Only admins can set verified_purchase. It returns the stored review as a JSON response with an HTTP 201 Created status.
+// If the ID or the body are not valid, it returns an HTTP 400 Bad Request error.
+// If verified_purchase is sent by someone who is not an admin, it returns an HTTP 403 Forbidden error.
+// If the product is not found, or it is not live and the request is not from an admin, it returns an HTTP 404 Not Found error.
+// If there is an error while storing the review, it returns an HTTP 500 Internal Server Error.
+func (ph ProductsHandler) createProductReview(w http.ResponseWriter, r *http.Request) {
+	// Extract product ID from URL parameter
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+
+	var in reviewInput
+	err = decodeJSONBody(r, &in)
+	if err == nil {
+		err = in.validate()
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	admin := ph.admin.isAdmin(r)
+	if in.VerifiedPurchase != nil && !admin {
+		http.Error(w, "Only admins can set verified_purchase", http.StatusForbidden)
+		return
+	}
+	verified := in.VerifiedPurchase != nil && *in.VerifiedPurchase
+
+	// Store the review only if the product exists and customers can see it
+	sqlQuery := "INSERT INTO product_reviews (product_id, rating, title, body, author, verified_purchase) " +
+		"SELECT id, $2, $3, $4, $5, $6 FROM products WHERE id = $1"
+	if !admin {
+		sqlQuery += " AND " + liveCondition
+	}
+	sqlQuery += " RETURNING " + reviewColumns
+	rev := Review{}
+	err = scanReview(ph.db.QueryRow(sqlQuery, id, in.Rating, in.Title, in.Body, in.Author, verified), &rev)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Println(err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Encode and send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(rev)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// moderateProductReview handles the HTTP request for approving or rejecting a review of a product.
+//
+// It expects the IDs of the product and the review as URL parameters and a body with the new status, and optionally
+// the verified_purchase flag. Only approved reviews are shown to customers and count in the product rating, which is
+// updated by the database when the review changes, and recorded in the product history as changed by the actor of
+// the request (see requestActor). It returns the updated review as a JSON response.
+// If the IDs or the body are not valid, it returns an HTTP 400 Bad Request error.
+// If the review is not found, it returns an HTTP 404 Not Found error.
+// If there is an error while updating the review, it returns an HTTP 500 Internal Server Error.
+func (ph ProductsHandler) moderateProductReview(w http.ResponseWriter, r *http.Request) {
+	// Extract product and review IDs from URL parameters
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+	reviewID, err := strconv.Atoi(vars["review_id"])
+	if err != nil {
+		http.Error(w, "Invalid review ID", http.StatusBadRequest)
+		return
+	}
+
+	var m reviewModeration
+	err = decodeJSONBody(r, &m)
+	if err == nil {
+		err = m.validate()
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// A missing verified_purchase keeps the stored flag
+	var verified sql.NullBool
+	if m.VerifiedPurchase != nil {
+		verified = sql.NullBool{Bool: *m.VerifiedPurchase, Valid: true}
+	}
+	sqlQuery := "UPDATE product_reviews SET status = $1, verified_purchase = COALESCE($2, verified_purchase) " +
+		"WHERE product_id = $3 AND id = $4 RETURNING " + reviewColumns
+	rev := Review{}
+	err = runAsActor(r.Context(), ph.db, requestActor(r), func(tx *sql.Tx) error {
+		return scanReview(tx.QueryRow(sqlQuery, m.Status, verified, id, reviewID), &rev)
+	})
+	if err == sql.ErrNoRows {
+		http.Error(w, "Review not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Println(err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Drop the cached responses that include the product, its rating may have changed
+	ph.cache.invalidate(r.Context(), id)
+
+	// Encode and send response
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(rev)
+	if err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+)
+
+// getMockReviewRows returns the rows of the given reviews as selected with reviewColumns.
+func getMockReviewRows(reviews ...Review) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"id", "product_id", "rating", "title", "body", "author", "verified_purchase", "status", "created_at"})
+	for _, r := range reviews {
+		rows.AddRow(r.ID, r.ProductID, r.Rating, r.Title, r.Body, r.Author, r.VerifiedPurchase, r.Status, r.CreatedAt)
+	}
+	return rows
+}
+
+func TestCreateProductReview_Success(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	expected := Review{ID: 7, ProductID: 1, Rating: 4, Title: "Lovely", Body: "Great glaze", Author: "Ana", Status: reviewPending,
+		CreatedAt: time.Date(2023, 6, 27, 15, 0, 0, 0, time.UTC)}
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO product_reviews (product_id, rating, title, body, author, verified_purchase) "+
+		"SELECT id, $2, $3, $4, $5, $6 FROM products WHERE id = $1 AND "+liveCondition+" RETURNING "+reviewColumns)).
+		WithArgs(1, 4, "Lovely", "Great glaze", "Ana", false).
+		WillReturnRows(getMockReviewRows(expected))
+
+	ph := ProductsHandler{db: db}
+	body := `{"rating":4,"title":"Lovely","body":"Great glaze","author":"Ana"}`
+	rr := serveProductRequest(t, ph.createProductReview, http.MethodPost, "/products/1/reviews", body, map[string]string{"id": "1"})
+
+	checkResponseCode(t, rr.Code, http.StatusCreated)
+	expectedBody, _ := marshalLine(expected)
+	checkResponseBody(t, rr.Body.String(), expectedBody, nil)
+	checkMockExpectations(t, mock)
+}
+
+func TestCreateProductReview_VerifiedPurchaseByAdmin(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	// Admins can review products that are not live and mark the purchase as verified
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, $2, $3, $4, $5, $6 FROM products WHERE id = $1 RETURNING")).
+		WithArgs(1, 5, "", "", "Ana", true).
+		WillReturnRows(getMockReviewRows(Review{ID: 8, ProductID: 1, Rating: 5, Author: "Ana", VerifiedPurchase: true, Status: reviewPending}))
+
+	ph := ProductsHandler{db: db, admin: adminAuth{token: "secret"}}
+	req, err := http.NewRequest(http.MethodPost, "/products/1/reviews", strings.NewReader(`{"rating":5,"author":"Ana","verified_purchase":true}`))
+	if err != nil {
+		t.Fatalf("Failed to create HTTP request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer secret")
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	rr := httptest.NewRecorder()
+	ph.createProductReview(rr, req)
+
+	checkResponseCode(t, rr.Code, http.StatusCreated)
+	checkMockExpectations(t, mock)
+}
+
+func TestCreateProductReview_Errors(t *testing.T) {
+	tests := []struct {
+		name           string
+		id             string
+		body           string
+		setup          func(mock sqlmock.Sqlmock)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{name: "Invalid ID", id: "abc", body: `{"rating":4,"author":"Ana"}`, setup: func(mock sqlmock.Sqlmock) {},
+			expectedStatus: http.StatusBadRequest, expectedBody: "Invalid product ID\n"},
+		{name: "Invalid rating", id: "1", body: `{"rating":0,"author":"Ana"}`, setup: func(mock sqlmock.Sqlmock) {},
+			expectedStatus: http.StatusBadRequest, expectedBody: "rating must be a number between 1 and 5\n"},
+		{name: "Unknown field", id: "1", body: `{"rating":4,"author":"Ana","status":"approved"}`, setup: func(mock sqlmock.Sqlmock) {},
+			expectedStatus: http.StatusBadRequest, expectedBody: "invalid request body: json: unknown field \"status\"\n"},
+		{name: "Verified purchase", id: "1", body: `{"rating":4,"author":"Ana","verified_purchase":true}`, setup: func(mock sqlmock.Sqlmock) {},
+			expectedStatus: http.StatusForbidden, expectedBody: "Only admins can set verified_purchase\n"},
+		{name: "Not found", id: "1", body: `{"rating":4,"author":"Ana"}`, setup: func(mock sqlmock.Sqlmock) {
+			mock.ExpectQuery("INSERT INTO product_reviews").WillReturnRows(getMockReviewRows())
+		}, expectedStatus: http.StatusNotFound, expectedBody: "Product not found\n"},
+		{name: "Database error", id: "1", body: `{"rating":4,"author":"Ana"}`, setup: func(mock sqlmock.Sqlmock) {
+			mock.ExpectQuery("INSERT INTO product_reviews").WillReturnError(errors.New("some error"))
+		}, expectedStatus: http.StatusInternalServerError, expectedBody: "Internal server error\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := getMockDB(t)
+			defer db.Close()
+			tt.setup(mock)
+
+			ph := ProductsHandler{db: db}
+			rr := serveProductRequest(t, ph.createProductReview, http.MethodPost, "/products/"+tt.id+"/reviews", tt.body, map[string]string{"id": tt.id})
+
+			checkResponseCode(t, rr.Code, tt.expectedStatus)
+			checkResponseBody(t, rr.Body.String(), tt.expectedBody, nil)
+			checkMockExpectations(t, mock)
+		})
+	}
+}
+
+func TestModerateProductReview(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		setup          func(mock sqlmock.Sqlmock)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{name: "Approved", body: `{"status":"approved"}`, setup: func(mock sqlmock.Sqlmock) {
+			expectActor(mock, defaultActor)
+			mock.ExpectQuery(regexp.QuoteMeta("UPDATE product_reviews SET status = $1, verified_purchase = COALESCE($2, verified_purchase) "+
+				"WHERE product_id = $3 AND id = $4 RETURNING "+reviewColumns)).
+				WithArgs(reviewApproved, nil, 1, 7).
+				WillReturnRows(getMockReviewRows(Review{ID: 7, ProductID: 1, Rating: 4, Author: "Ana", Status: reviewApproved}))
+			mock.ExpectCommit()
+		}, expectedStatus: http.StatusOK},
+		{name: "Verified purchase", body: `{"status":"rejected","verified_purchase":true}`, setup: func(mock sqlmock.Sqlmock) {
+			expectActor(mock, defaultActor)
+			mock.ExpectQuery("UPDATE product_reviews").
+				WithArgs(reviewRejected, true, 1, 7).
+				WillReturnRows(getMockReviewRows(Review{ID: 7, ProductID: 1, Rating: 4, Author: "Ana", VerifiedPurchase: true, Status: reviewRejected}))
+			mock.ExpectCommit()
+		}, expectedStatus: http.StatusOK},
+		{name: "Unknown status", body: `{"status":"hidden"}`, setup: func(mock sqlmock.Sqlmock) {},
+			expectedStatus: http.StatusBadRequest, expectedBody: "status must be pending, approved or rejected\n"},
+		{name: "Not found", body: `{"status":"approved"}`, setup: func(mock sqlmock.Sqlmock) {
+			expectActor(mock, defaultActor)
+			mock.ExpectQuery("UPDATE product_reviews").WillReturnRows(getMockReviewRows())
+			mock.ExpectRollback()
+		}, expectedStatus: http.StatusNotFound, expectedBody: "Review not found\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := getMockDB(t)
+			defer db.Close()
+			tt.setup(mock)
+
+			ph := ProductsHandler{db: db}
+			rr := serveProductRequest(t, ph.moderateProductReview, http.MethodPatch, "/products/1/reviews/7", tt.body, map[string]string{"id": "1", "review_id": "7"})
+
+			checkResponseCode(t, rr.Code, tt.expectedStatus)
+			if tt.expectedBody != "" {
+				checkResponseBody(t, rr.Body.String(), tt.expectedBody, nil)
+			}
+			checkMockExpectations(t, mock)
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+// variantInput is the request body accepted when creating a product variant.
+type variantInput struct {
+	SKU     string            `json:"sku"`
+	Options map[string]string `json:"options"`
+	Price   *Money            `json:"price"`
+	Images  []string          `json:"images"`
+	Stock   int               `json:"stock"`
+}
+
+// normalize replaces missing options and images with empty ones so they are never stored as NULL.
+func (in *variantInput) normalize() {
+	if in.Options == nil {
+		in.Options = map[string]string{}
+	}
+	if in.Images == nil {
+		in.Images = []string{}
+	}
+}
+
+// createProductVariant handles the HTTP request for adding a variant to a product.
+//
+// It expects the ID of the product as a URL parameter, decodes and validates the request body, and returns
+// the stored variant as a JSON response with an HTTP 201 Created status. Variants without a price cost
+// the same as their product.
+// If the ID or the body are not valid, it returns an HTTP 400 Bad Request error.
+// If the product does not exist, it returns an HTTP 404 Not Found error.
+// If another variant already has the same SKU, it returns an HTTP 409 Conflict error.
+// If there is an error while inserting the variant, it returns an HTTP 500 Internal Server Error.
+func (ph ProductsHandler) createProductVariant(w http.ResponseWriter, r *http.Request) {
+	// Extract product ID from URL parameter
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+
+	var in variantInput
+	err = decodeJSONBody(r, &in)
+	if err == nil {
+		err = in.validate()
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	in.normalize()
+
+	options, err := json.Marshal(in.Options)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Insert the variant and read it back as stored
+	sqlQuery := "INSERT INTO product_variants (product_id, sku, options, price, images, stock) " +
+		"VALUES ($1, $2, $3, $4, $5, $6) RETURNING " + variantColumns
+	row := ph.db.QueryRow(sqlQuery, id, in.SKU, string(options), in.Price, textArray(in.Images), in.Stock)
+
+	v := ProductVariant{}
+	err = scanVariant(row, &v)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolation {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	} else if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		http.Error(w, "SKU already exists", http.StatusConflict)
+		return
+	} else if err != nil {
+		log.Println(err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Drop the cached responses that include the product
+	ph.cache.invalidate(r.Context(), id)
+
+	// Encode and send response
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/products/"+strconv.Itoa(id))
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(v)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// deleteProductVariant handles the HTTP request for removing a variant from a product.
+//
+// It expects the IDs of the product and the variant as URL parameters and returns an HTTP 204 No Content on success.
+// If an ID is not valid, it returns an HTTP 400 Bad Request error.
+// If the variant does not exist or belongs to another product, it returns an HTTP 404 Not Found error.
+// If the variant is still referenced by a shopping cart item, it returns an HTTP 409 Conflict error.
+// If there is an error while deleting the variant, it returns an HTTP 500 Internal Server Error.
+func (ph ProductsHandler) deleteProductVariant(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+	variantID, err := strconv.Atoi(vars["variant_id"])
+	if err != nil {
+		http.Error(w, "Invalid variant ID", http.StatusBadRequest)
+		return
+	}
+
+	result, err := ph.db.Exec("DELETE FROM product_variants WHERE id = $1 AND product_id = $2", variantID, id)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolation {
+		http.Error(w, "Variant is referenced by shopping carts", http.StatusConflict)
+		return
+	} else if err != nil {
+		log.Println(err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if affected == 0 {
+		http.Error(w, "Variant not found", http.StatusNotFound)
+		return
+	}
+
+	// Drop the cached responses that include the product
+	ph.cache.invalidate(r.Context(), id)
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+func TestCreateProductVariant(t *testing.T) {
+	price := cop(2500)
+	variant := ProductVariant{ID: 7, ProductID: 1, SKU: "MUG-BLUE-L", Options: map[string]string{"glaze": "blue", "size": "large"}, Price: &price, Images: []string{}, Stock: 2}
+	insert := regexp.QuoteMeta("INSERT INTO product_variants (product_id, sku, options, price, images, stock) VALUES ($1, $2, $3, $4, $5, $6) RETURNING " + variantColumns)
+	body := `{"sku":"MUG-BLUE-L","options":{"glaze":"blue","size":"large"},"price":"25","stock":2}`
+	expectedBody, _ := marshalLine(variant)
+
+	tests := []struct {
+		name           string
+		body           string
+		setup          func(mock sqlmock.Sqlmock)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "Created",
+			body: body,
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(insert).
+					WithArgs(1, "MUG-BLUE-L", `{"glaze":"blue","size":"large"}`, "25.00", textArray{}, 2).
+					WillReturnRows(getVariantRows([]ProductVariant{variant}))
+			},
+			expectedStatus: http.StatusCreated,
+			expectedBody:   expectedBody,
+		},
+		{
+			name: "Without price",
+			body: `{"sku":"MUG-BLUE-S"}`,
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(insert).
+					WithArgs(1, "MUG-BLUE-S", `{}`, nil, textArray{}, 0).
+					WillReturnRows(getVariantRows([]ProductVariant{variant}))
+			},
+			expectedStatus: http.StatusCreated,
+			expectedBody:   expectedBody,
+		},
+		{
+			name:           "Invalid body",
+			body:           `{"sku":"MUG","stock":-1}`,
+			setup:          func(mock sqlmock.Sqlmock) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "stock must not be negative\n",
+		},
+		{
+			name: "Product not found",
+			body: body,
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(insert).WillReturnError(&pq.Error{Code: foreignKeyViolation})
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   "Product not found\n",
+		},
+		{
+			name: "Repeated SKU",
+			body: body,
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(insert).WillReturnError(&pq.Error{Code: uniqueViolation})
+			},
+			expectedStatus: http.StatusConflict,
+			expectedBody:   "SKU already exists\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := getMockDB(t)
+			defer db.Close()
+			tt.setup(mock)
+
+			ph := ProductsHandler{db: db}
+			rr := serveProductRequest(t, ph.createProductVariant, http.MethodPost, "/products/1/variants", tt.body, map[string]string{"id": "1"})
+
+			checkResponseCode(t, rr.Code, tt.expectedStatus)
+			checkResponseBody(t, rr.Body.String(), tt.expectedBody, nil)
+			checkMockExpectations(t, mock)
+		})
+	}
+}
+
+func TestDeleteProductVariant(t *testing.T) {
+	deleteQuery := regexp.QuoteMeta("DELETE FROM product_variants WHERE id = $1 AND product_id = $2")
+	tests := []struct {
+		name           string
+		setup          func(mock sqlmock.Sqlmock)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "Deleted",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(deleteQuery).WithArgs(7, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			expectedStatus: http.StatusNoContent,
+			expectedBody:   "",
+		},
+		{
+			name: "Not found",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(deleteQuery).WithArgs(7, 1).WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   "Variant not found\n",
+		},
+		{
+			name: "Referenced by a shopping cart",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(deleteQuery).WithArgs(7, 1).WillReturnError(&pq.Error{Code: foreignKeyViolation})
+			},
+			expectedStatus: http.StatusConflict,
+			expectedBody:   "Variant is referenced by shopping carts\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := getMockDB(t)
+			defer db.Close()
+			tt.setup(mock)
+
+			ph := ProductsHandler{db: db}
+			rr := serveProductRequest(t, ph.deleteProductVariant, http.MethodDelete, "/products/1/variants/7", "", map[string]string{"id": "1", "variant_id": "7"})
+
+			checkResponseCode(t, rr.Code, tt.expectedStatus)
+			checkResponseBody(t, rr.Body.String(), tt.expectedBody, nil)
+			checkMockExpectations(t, mock)
+		})
+	}
+}
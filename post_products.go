@@ -0,0 +1,120 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// productInput is the request body accepted when creating or replacing a product.
+type productInput struct {
+	Name           string   `json:"name"`
+	Price          Money    `json:"price"`
+	Description    string   `json:"description"`
+	Categories     []string `json:"categories"`
+	Images         []string `json:"images"`
+	ReferencedName string   `json:"referenced_name"`
+	// Slug is generated from the name when creating a product without it, and kept when replacing one without it.
+	Slug string `json:"slug"`
+	// Status defaults to published. PublishAt and UnpublishAt optionally limit when a published product is shown.
+	Status      string     `json:"status"`
+	PublishAt   *time.Time `json:"publish_at"`
+	UnpublishAt *time.Time `json:"unpublish_at"`
+}
+
+// normalize replaces missing lists with empty ones so they are stored as empty arrays instead of NULL,
+// and a missing status with published.
+func (in *productInput) normalize() {
+	if in.Status == "" {
+		in.Status = statusPublished
+	}
+	if in.Categories == nil {
+		in.Categories = []string{}
+	}
+	if in.Images == nil {
+		in.Images = []string{}
+	}
+}
+
+// createProduct handles the HTTP request for adding a new product to the catalog.
+//
+// It decodes and validates the request body, inserts the product with the current time as its date added,
+// and returns the stored product as a JSON response with an HTTP 201 Created status.
+// Without a slug in the body, the product gets the slug of its name, numbered if it is already taken.
+// If the body is not valid or has categories that don't exist, it returns an HTTP 400 Bad Request error.
+// If the slug is already used by another product, it returns an HTTP 409 Conflict error.
+// If there is an error while inserting the product, it returns an HTTP 500 Internal Server Error.
+func (ph ProductsHandler) createProduct(w http.ResponseWriter, r *http.Request) {
+	var in productInput
+	err := decodeJSONBody(r, &in)
+	if err == nil {
+		err = in.validate()
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	in.normalize()
+
+	// Categories must exist in the categories table
+	err = checkCategoriesExist(ph.db, in.Categories)
+	var vErr validationError
+	if errors.As(err, &vErr) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		log.Println(err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Insert the product and read it back as stored. A slug generated from the name can be taken by another
+	// product between generating and inserting it, and then the next free one is tried.
+	sqlQuery := "INSERT INTO products (name, slug, price, description, categories, images, referenced_name, status, publish_at, unpublish_at, date_added) " +
+		"VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NOW()) RETURNING " + productColumns
+	p := Product{}
+	generateSlug := in.Slug == ""
+	var pqErr *pq.Error
+	for attempt := 1; ; attempt++ {
+		if generateSlug {
+			in.Slug, err = uniqueSlug(ph.db, slugify(in.Name))
+			if err != nil {
+				break
+			}
+		}
+		err = runAsActor(r.Context(), ph.db, requestActor(r), func(tx *sql.Tx) error {
+			row := tx.QueryRow(sqlQuery, in.Name, in.Slug, in.Price, in.Description, textArray(in.Categories), textArray(in.Images), in.ReferencedName,
+				in.Status, in.PublishAt, in.UnpublishAt)
+			return scanProduct(row, &p)
+		})
+		if !generateSlug || attempt == maxSlugAttempts || !errors.As(err, &pqErr) || pqErr.Code != uniqueViolation {
+			break
+		}
+	}
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		http.Error(w, "Slug already exists", http.StatusConflict)
+		return
+	} else if err != nil {
+		log.Println(err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Drop the cached responses that include the product
+	ph.cache.invalidate(r.Context(), p.ID)
+
+	// Encode and send response
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/products/"+strconv.Itoa(p.ID))
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(p)
+	if err != nil {
+		log.Println(err)
+	}
+}
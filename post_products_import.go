@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// importProducts handles the HTTP request for importing a catalog file of products and variants.
+//
+// The body is a CSV or JSON Lines file (see parseImportCSV and parseImportJSONL), with its format in the format
+// query parameter or the Content-Type header. Products are upserted by slug and variants by SKU in a single
+// transaction, and with dry_run=true the transaction is rolled back after checking every row (see runImport).
+// It returns the import report as a JSON response, with an HTTP 400 Bad Request status if any row is invalid,
+// in which case nothing is imported. The changes are recorded in the history of the products like any other change.
+// If the format or the dry_run parameter are not valid, it returns an HTTP 400 Bad Request error.
+// If the file is bigger than 10 MB, it returns an HTTP 413 Request Entity Too Large error.
+// If there is an error while importing the products, it returns an HTTP 500 Internal Server Error.
+func (ph ProductsHandler) importProducts(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = r.Header.Get("Content-Type")
+	}
+	format, err := parseImportFormat(format)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	dryRun := false
+	if v := r.URL.Query().Get("dry_run"); v != "" {
+		dryRun, err = strconv.ParseBool(v)
+		if err != nil {
+			http.Error(w, "dry_run must be true or false", http.StatusBadRequest)
+			return
+		}
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	report, err := runImport(r.Context(), ph.db, requestActor(r), r.Body, format, dryRun)
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		http.Error(w, fmt.Sprintf("request body must be at most %d MB", maxImportSize>>20), http.StatusRequestEntityTooLarge)
+		return
+	} else if err != nil {
+		log.Println(err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Drop the cached responses that include the imported products
+	if !report.DryRun && !report.failed() {
+		ph.cache.invalidate(r.Context(), report.productIDs...)
+	}
+
+	// Encode and send response
+	w.Header().Set("Content-Type", "application/json")
+	if report.failed() {
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	err = json.NewEncoder(w).Encode(report)
+	if err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/go-redis/redismock/v8"
+)
+
+// importTestFile is the CSV file of the rows of importTestRows.
+const importTestFile = "name,price,sku,options,stock\nTaza Azul,1,TAZA-AZUL-S,,\nTaza Azul,1,TAZA-AZUL-L,size=large,2\n"
+
+func TestImportProducts_Success(t *testing.T) {
+	client, redisMock := redismock.NewClientMock()
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	rows := importTestRows()
+	expectImportSKUs(mock, textArray{"TAZA-AZUL-S", "TAZA-AZUL-L"}, nil)
+	expectImportSlugs(mock, textArray{"taza-azul"}, nil)
+	expectActor(mock, "admin")
+	expectImportProduct(mock, rows[0], 7, true)
+	expectImportVariant(mock, rows[0], 7, nil)
+	expectImportProduct(mock, rows[1], 7, false)
+	expectImportVariant(mock, rows[1], 7, nil)
+	mock.ExpectCommit()
+	// The cached responses of the imported products are dropped
+	redisMock.ExpectDel(productKey(7)).SetVal(1)
+	redisMock.ExpectIncr(productListGenerationKey).SetVal(1)
+
+	ph := ProductsHandler{db: db, cache: productCache{client: client}}
+	rr := serveProductRequest(t, ph.importProducts, http.MethodPost, "/products/import?format=csv", importTestFile, nil)
+
+	checkResponseCode(t, rr.Code, http.StatusOK)
+	expectedBody, _ := marshalLine(importReport{Rows: 2, Products: importCounts{Created: 1}, Variants: importCounts{Created: 2}, Errors: []importRowError{}})
+	checkResponseBody(t, rr.Body.String(), expectedBody, nil)
+	checkMockExpectations(t, mock)
+	if err := redisMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestImportProducts_DryRun(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	// The file names the existing product by its slug
+	file := "slug,name,price,sku,options,stock\ntaza-azul,Taza Azul,1,TAZA-AZUL-S,,\ntaza-azul,Taza Azul,1,TAZA-AZUL-L,size=large,2\n"
+	rows := importTestRows()
+	expectImportSKUs(mock, textArray{"TAZA-AZUL-S", "TAZA-AZUL-L"}, map[string]string{"TAZA-AZUL-S": "taza-azul"})
+	expectImportSlugs(mock, textArray{"taza-azul"}, map[string]string{"taza-azul": "taza-azul"})
+	expectActor(mock, "admin")
+	expectImportProduct(mock, rows[0], 7, false)
+	expectImportVariant(mock, rows[0], 7, &ProductVariant{ID: 21, ProductID: 7})
+	expectImportProduct(mock, rows[1], 7, false)
+	expectImportVariant(mock, rows[1], 7, nil)
+	mock.ExpectRollback()
+
+	// Nothing is imported, so the cache is kept
+	ph := ProductsHandler{db: db}
+	rr := serveProductRequest(t, ph.importProducts, http.MethodPost, "/products/import?format=csv&dry_run=true", file, nil)
+
+	checkResponseCode(t, rr.Code, http.StatusOK)
+	expectedBody, _ := marshalLine(importReport{DryRun: true, Rows: 2, Products: importCounts{Updated: 1}, Variants: importCounts{Created: 1, Updated: 1}, Errors: []importRowError{}})
+	checkResponseBody(t, rr.Body.String(), expectedBody, nil)
+	checkMockExpectations(t, mock)
+}
+
+func TestImportProducts_InvalidRows(t *testing.T) {
+	ph := ProductsHandler{db: nil}
+	req := `{"name":"Mug","price":"1","stock":2}` + "\n" + `{"name":"Mug","price":"-1"}` + "\n"
+	rr := serveProductRequest(t, ph.importProducts, http.MethodPost, "/products/import?format=jsonl", req, nil)
+
+	checkResponseCode(t, rr.Code, http.StatusBadRequest)
+	expectedBody, _ := marshalLine(importReport{Rows: 2, Errors: []importRowError{
+		{Line: 1, Error: "options, variant_price and stock require a sku"},
+		{Line: 2, Error: "price must be greater than zero"},
+	}})
+	checkResponseBody(t, rr.Body.String(), expectedBody, nil)
+}
+
+func TestImportProducts_Errors(t *testing.T) {
+	tests := []struct {
+		name           string
+		target         string
+		body           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{name: "Missing format", target: "/products/import", body: importTestFile, expectedStatus: http.StatusBadRequest, expectedBody: "format must be csv or jsonl\n"},
+		{name: "Invalid dry_run", target: "/products/import?format=csv&dry_run=maybe", body: importTestFile, expectedStatus: http.StatusBadRequest, expectedBody: "dry_run must be true or false\n"},
+		{name: "Too large", target: "/products/import?format=jsonl", body: string(make([]byte, maxImportSize+1)), expectedStatus: http.StatusRequestEntityTooLarge, expectedBody: "request body must be at most 10 MB\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ph := ProductsHandler{db: nil}
+			rr := serveProductRequest(t, ph.importProducts, http.MethodPost, tt.target, tt.body, nil)
+
+			checkResponseCode(t, rr.Code, tt.expectedStatus)
+			checkResponseBody(t, rr.Body.String(), tt.expectedBody, nil)
+		})
+	}
+}
+
+func TestImportProducts_DBError(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	expectImportSKUs(mock, textArray{"TAZA-AZUL-S", "TAZA-AZUL-L"}, nil)
+	expectImportSlugs(mock, textArray{"taza-azul"}, nil)
+	expectActor(mock, "admin")
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO products")).WillReturnError(errors.New("some error"))
+	mock.ExpectRollback()
+
+	ph := ProductsHandler{db: db}
+	rr := serveProductRequest(t, ph.importProducts, http.MethodPost, "/products/import?format=csv", importTestFile, nil)
+
+	checkResponseCode(t, rr.Code, http.StatusInternalServerError)
+	checkResponseBody(t, rr.Body.String(), "Internal server error\n", nil)
+	checkMockExpectations(t, mock)
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+// serveProductRequest sends a request with the given body and URL variables to a ProductsHandler method
+// and returns the recorded response.
+func serveProductRequest(t *testing.T, handler http.HandlerFunc, method, target, body string, vars map[string]string) *httptest.ResponseRecorder {
+	req, err := http.NewRequest(method, target, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Failed to create HTTP request: %v", err)
+	}
+	if vars != nil {
+		req = mux.SetURLVars(req, vars)
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+// marshalLine returns the JSON encoding of v followed by a new line, as written by json.Encoder.
+func marshalLine(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b) + "\n", err
+}
+
+func TestCreateProduct_Success(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	expected := getExpectedProducts()[:1]
+	expectCategoriesExist(mock, "cat1", "cat2")
+	expectSlugsTaken(mock, "product-a")
+	expectActor(mock, "admin")
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO products (name, slug, price, description, categories, images, referenced_name, status, publish_at, unpublish_at, date_added) "+
+		"VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NOW()) RETURNING "+productColumns)).
+		WithArgs("Product A", "product-a", "10.00", "Product A description", textArray{"cat1", "cat2"}, textArray{"img1", "img2"}, "Product B", statusPublished, nil, nil).
+		WillReturnRows(getMockRows(expected))
+	mock.ExpectCommit()
+
+	ph := ProductsHandler{db: db}
+	body := `{"name":"Product A","price":10,"description":"Product A description","categories":["cat1","cat2"],"images":["img1","img2"],"referenced_name":"Product B"}`
+	rr := serveProductRequest(t, ph.createProduct, http.MethodPost, "/products", body, nil)
+
+	checkResponseCode(t, rr.Code, http.StatusCreated)
+	if location := rr.Header().Get("Location"); location != "/products/1" {
+		t.Errorf("handler returned wrong location: got %v want %v", location, "/products/1")
+	}
+	expectedBody, _ := marshalLine(expected[0])
+	checkResponseBody(t, rr.Body.String(), expectedBody, nil)
+	checkMockExpectations(t, mock)
+}
+
+func TestCreateProduct_EmptyListsAreStoredAsEmptyArrays(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	expectSlugsTaken(mock, "mug")
+	expectActor(mock, "admin")
+	mock.ExpectQuery("INSERT INTO products").
+		WithArgs("Mug", "mug", "5.50", "", textArray{}, textArray{}, "", statusPublished, nil, nil).
+		WillReturnRows(getMockRows(getExpectedProducts()[:1]))
+	mock.ExpectCommit()
+
+	ph := ProductsHandler{db: db}
+	rr := serveProductRequest(t, ph.createProduct, http.MethodPost, "/products", `{"name":"Mug","price":5.5}`, nil)
+
+	checkResponseCode(t, rr.Code, http.StatusCreated)
+	checkMockExpectations(t, mock)
+}
+
+func TestCreateProduct_InvalidBody(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		expectedBody string
+	}{
+		{name: "Malformed JSON", body: `{"name":`, expectedBody: "invalid request body: unexpected EOF\n"},
+		{name: "Unknown field", body: `{"name":"Mug","price":1,"color":"red"}`, expectedBody: "invalid request body: json: unknown field \"color\"\n"},
+		{name: "Missing name", body: `{"price":1}`, expectedBody: "name is required\n"},
+		{name: "Negative price", body: `{"name":"Mug","price":-1}`, expectedBody: "price must be greater than zero\n"},
+		{name: "Repeated category", body: `{"name":"Mug","price":1,"categories":["a","a"]}`, expectedBody: "category \"a\" is repeated\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ph := ProductsHandler{db: nil}
+			rr := serveProductRequest(t, ph.createProduct, http.MethodPost, "/products", tt.body, nil)
+
+			checkResponseCode(t, rr.Code, http.StatusBadRequest)
+			checkResponseBody(t, rr.Body.String(), tt.expectedBody, nil)
+		})
+	}
+}
+
+func TestCreateProduct_UnknownCategory(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT slug FROM categories WHERE slug = ANY($1)")).
+		WithArgs(textArray{"mugz"}).
+		WillReturnRows(sqlmock.NewRows([]string{"slug"}))
+
+	ph := ProductsHandler{db: db}
+	rr := serveProductRequest(t, ph.createProduct, http.MethodPost, "/products", `{"name":"Mug","price":1,"categories":["mugz"]}`, nil)
+
+	checkResponseCode(t, rr.Code, http.StatusBadRequest)
+	checkResponseBody(t, rr.Body.String(), "unknown categories: mugz\n", nil)
+	checkMockExpectations(t, mock)
+}
+
+func TestCreateProduct_SlugConflict(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	expectActor(mock, "admin")
+	mock.ExpectQuery("INSERT INTO products").
+		WithArgs("Mug", "blue-mug", "1.00", "", textArray{}, textArray{}, "", statusPublished, nil, nil).
+		WillReturnError(&pq.Error{Code: uniqueViolation})
+	mock.ExpectRollback()
+
+	ph := ProductsHandler{db: db}
+	rr := serveProductRequest(t, ph.createProduct, http.MethodPost, "/products", `{"name":"Mug","slug":"blue-mug","price":1}`, nil)
+
+	checkResponseCode(t, rr.Code, http.StatusConflict)
+	checkResponseBody(t, rr.Body.String(), "Slug already exists\n", nil)
+	checkMockExpectations(t, mock)
+}
+
+func TestCreateProduct_GeneratedSlugTaken(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	// Another product takes the generated slug before the insert, so the next free one is tried
+	expectSlugsTaken(mock, "mug")
+	expectActor(mock, "admin")
+	mock.ExpectQuery("INSERT INTO products").WithArgs("Mug", "mug", "1.00", "", textArray{}, textArray{}, "", statusPublished, nil, nil).
+		WillReturnError(&pq.Error{Code: uniqueViolation})
+	mock.ExpectRollback()
+	expectSlugsTaken(mock, "mug", "mug")
+	expectActor(mock, "admin")
+	mock.ExpectQuery("INSERT INTO products").WithArgs("Mug", "mug-2", "1.00", "", textArray{}, textArray{}, "", statusPublished, nil, nil).
+		WillReturnRows(getMockRows(getExpectedProducts()[:1]))
+	mock.ExpectCommit()
+
+	ph := ProductsHandler{db: db}
+	rr := serveProductRequest(t, ph.createProduct, http.MethodPost, "/products", `{"name":"Mug","price":1}`, nil)
+
+	checkResponseCode(t, rr.Code, http.StatusCreated)
+	checkMockExpectations(t, mock)
+}
+
+func TestCreateProduct_GeneratedSlugsTaken(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	// The insert is only tried maxSlugAttempts times
+	for i := 0; i < maxSlugAttempts; i++ {
+		expectSlugsTaken(mock, "mug")
+		expectActor(mock, "admin")
+		mock.ExpectQuery("INSERT INTO products").WillReturnError(&pq.Error{Code: uniqueViolation})
+		mock.ExpectRollback()
+	}
+
+	ph := ProductsHandler{db: db}
+	rr := serveProductRequest(t, ph.createProduct, http.MethodPost, "/products", `{"name":"Mug","price":1}`, nil)
+
+	checkResponseCode(t, rr.Code, http.StatusConflict)
+	checkResponseBody(t, rr.Body.String(), "Slug already exists\n", nil)
+	checkMockExpectations(t, mock)
+}
+
+func TestCreateProduct_DBError(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	expectSlugsTaken(mock, "mug")
+	expectActor(mock, "admin")
+	mock.ExpectQuery("INSERT INTO products").WillReturnError(errors.New("some error"))
+	mock.ExpectRollback()
+
+	ph := ProductsHandler{db: db}
+	rr := serveProductRequest(t, ph.createProduct, http.MethodPost, "/products", `{"name":"Mug","price":1}`, nil)
+
+	checkResponseCode(t, rr.Code, http.StatusInternalServerError)
+	checkResponseBody(t, rr.Body.String(), "Internal server error\n", nil)
+	checkMockExpectations(t, mock)
+}
@@ -9,8 +9,11 @@ import (
 // upsertShoppingCartHandler handles the HTTP request for upserting a shopping cart into Redis.
 //
 // It decodes the request body into a `ShoppingCart` struct, and then upserts it into Redis using the
-// `IPAddress` as the key. If the upsert succeeds, the function returns the saved shopping cart as a JSON
-// response. If any errors occur during decoding, upserting, or encoding the response, the function returns
+// `IPAddress` as the key. The stock of every item is reserved for as long as the cart lives, replacing the
+// previous reservations of the cart, and the items that could not be reserved, or are not live, are listed in the
+// `UnreservedItems` of the response (see reserveStock).
+// If the upsert succeeds, the function returns the saved shopping cart as a JSON
+// response. If any errors occur during decoding, reserving, upserting, or encoding the response, the function returns
 // an HTTP error with an appropriate status code and message.
 func (sch ShoppingCartsHandler) upsertShoppingCartHandler(w http.ResponseWriter, r *http.Request) {
 	var shoppingCart ShoppingCart
@@ -21,22 +24,49 @@ func (sch ShoppingCartsHandler) upsertShoppingCartHandler(w http.ResponseWriter,
 	}
 
 	ipAddress := shoppingCart.IPAddress
+	shoppingCart.UnreservedItems = nil
 
-	// Convert the shopping cart to a JSON string
+	// Reserve the stock of the items, the cart is only saved once the reservations are committed
+	tx, err := sch.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// The rollback is a no-op once the transaction is committed
+	defer tx.Rollback()
+
+	unreserved, changedProducts, err := reserveStock(tx, ipAddress, shoppingCart.ShoppingCartItems, time.Now().Add(shoppingCartTTL))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Convert the shopping cart to a JSON string, the unreserved items are only part of the response
 	shoppingCartJSON, err := json.Marshal(shoppingCart)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// Upsert the shopping cart record in Redis using the IP address as the key
-	err = sch.redisClient.Set(r.Context(), ipAddress, shoppingCartJSON, 24*time.Hour).Err()
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// The available quantity of the reserved and released products changed
+	productCache{client: sch.redisClient}.invalidate(r.Context(), changedProducts...)
+
+	// Upsert the shopping cart record in Redis using the IP address as the key, once its reservations are committed.
+	// If it fails, the reservations expire with the cart they were made for.
+	err = sch.redisClient.Set(r.Context(), ipAddress, shoppingCartJSON, shoppingCartTTL).Err()
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// Return the saved shopping cart
+	if len(unreserved) > 0 {
+		shoppingCart.UnreservedItems = unreserved
+	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(shoppingCart)
 }
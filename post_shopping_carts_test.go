@@ -34,8 +34,10 @@ var shoppingCart = ShoppingCart{
 }
 
 func TestUpsertShoppingCartSuccess(t *testing.T) {
-	// create a mock Redis DB
+	// create a mock Redis DB and a mock database
 	redisDB, mock := redismock.NewClientMock()
+	db, dbMock := getMockDB(t)
+	defer db.Close()
 
 	// marshal shopping cart to JSON
 	shoppingCartJSON, err := json.Marshal(shoppingCart)
@@ -43,8 +45,20 @@ func TestUpsertShoppingCartSuccess(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	// set expectations for the shopping cart being stored in the Redis DB with a TTL of 24 hours
+	// set expectations for the stock of the items being reserved
 	key := shoppingCart.IPAddress
+	dbMock.ExpectBegin()
+	expectReleasedReservations(dbMock, key, shoppingCart.ShoppingCartItems)
+	for _, item := range shoppingCart.ShoppingCartItems {
+		expectReservation(dbMock, key, item, 5, 0)
+	}
+	expectTouchedProducts(dbMock, "1", "2")
+
+	dbMock.ExpectCommit()
+	mock.ExpectDel(productKey(1), productKey(2)).SetVal(2)
+	mock.ExpectIncr(productListGenerationKey).SetVal(1)
+
+	// set expectations for the shopping cart being stored in the Redis DB with a TTL of 24 hours
 	expectedTTL := 24 * time.Hour
 	mockExpect := mock.ExpectSet(key, shoppingCartJSON, expectedTTL)
 	mockExpect.SetVal("OK")
@@ -57,7 +71,7 @@ func TestUpsertShoppingCartSuccess(t *testing.T) {
 
 	// create a new router and add the upsertShoppingCartHandler handler function
 	r := mux.NewRouter()
-	sch := ShoppingCartsHandler{db: nil, redisClient: redisDB}
+	sch := ShoppingCartsHandler{db: db, redisClient: redisDB}
 	r.HandleFunc("/shopping_carts", sch.upsertShoppingCartHandler).Methods(http.MethodPost)
 
 	// serve the request
@@ -90,12 +104,17 @@ func TestUpsertShoppingCartSuccess(t *testing.T) {
 	}
 
 	// wait for the expectations to be met
+	checkMockExpectations(t, dbMock)
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("redis expectations were not met: %s", err.Error())
 	}
 }
 
 func TestUpsertShoppingCartInvalidJSON(t *testing.T) {
+	// create a mock database, which is not used
+	db, dbMock := getMockDB(t)
+	defer db.Close()
+
 	// create a new request with invalid shopping cart JSON as the body
 	req := httptest.NewRequest(http.MethodPost, "/shopping_carts", strings.NewReader("invalid JSON"))
 
@@ -104,7 +123,7 @@ func TestUpsertShoppingCartInvalidJSON(t *testing.T) {
 
 	// create a new router and add the upsertShoppingCartHandler handler function
 	r := mux.NewRouter()
-	sch := ShoppingCartsHandler{db: nil, redisClient: nil}
+	sch := ShoppingCartsHandler{db: db, redisClient: nil}
 	r.HandleFunc("/shopping_carts", sch.upsertShoppingCartHandler).Methods(http.MethodPost)
 
 	// serve the request
@@ -114,11 +133,14 @@ func TestUpsertShoppingCartInvalidJSON(t *testing.T) {
 	if status := rr.Code; status != http.StatusBadRequest {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
 	}
+	checkMockExpectations(t, dbMock)
 }
 
 func TestUpsertShoppingCartRedisError(t *testing.T) {
-	// create a mock Redis DB
+	// create a mock Redis DB and a mock database
 	redisDB, mock := redismock.NewClientMock()
+	db, dbMock := getMockDB(t)
+	defer db.Close()
 
 	// marshal shopping cart to JSON
 	shoppingCartJSON, err := json.Marshal(shoppingCart)
@@ -132,15 +154,26 @@ func TestUpsertShoppingCartRedisError(t *testing.T) {
 	// create a new response recorder
 	rr := httptest.NewRecorder()
 
-	// set expectations for the shopping cart being stored in the Redis DB with a TTL of 24 hours
+	// set expectations for the stock of the items being reserved before the cart is saved
 	key := shoppingCart.IPAddress
+	dbMock.ExpectBegin()
+	expectReleasedReservations(dbMock, key, shoppingCart.ShoppingCartItems)
+	for _, item := range shoppingCart.ShoppingCartItems {
+		expectReservation(dbMock, key, item, 5, 0)
+	}
+	expectTouchedProducts(dbMock, "1", "2")
+	dbMock.ExpectCommit()
+	mock.ExpectDel(productKey(1), productKey(2)).SetVal(2)
+	mock.ExpectIncr(productListGenerationKey).SetVal(1)
+
+	// set expectations for the shopping cart being stored in the Redis DB with a TTL of 24 hours
 	expectedTTL := 24 * time.Hour
 	mockExpect := mock.ExpectSet(key, shoppingCartJSON, expectedTTL)
 	mockExpect.SetErr(errors.New("Redis command error"))
 
 	// create a new router and add the upsertShoppingCartHandler handler function
 	r := mux.NewRouter()
-	sch := ShoppingCartsHandler{db: nil, redisClient: redisDB}
+	sch := ShoppingCartsHandler{db: db, redisClient: redisDB}
 	r.HandleFunc("/shopping_carts", sch.upsertShoppingCartHandler).Methods(http.MethodPost)
 
 	// serve the request
@@ -150,4 +183,73 @@ func TestUpsertShoppingCartRedisError(t *testing.T) {
 	if status := rr.Code; status != http.StatusInternalServerError {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusInternalServerError)
 	}
+	checkMockExpectations(t, dbMock)
+}
+
+func TestUpsertShoppingCartReservesStock(t *testing.T) {
+	redisDB, redisMock := redismock.NewClientMock()
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	// The second item is sold out, so it is saved in the cart but reported as unreserved in the response only
+	expectedCart := shoppingCart
+	expectedCart.UnreservedItems = shoppingCart.ShoppingCartItems[1:]
+	savedJSON, err := json.Marshal(shoppingCart)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectBegin()
+	expectReleasedReservations(mock, shoppingCart.IPAddress, shoppingCart.ShoppingCartItems)
+	expectReservation(mock, shoppingCart.IPAddress, shoppingCart.ShoppingCartItems[0], 3, 0)
+	expectReservation(mock, shoppingCart.IPAddress, shoppingCart.ShoppingCartItems[1], 1, 1)
+	expectTouchedProducts(mock, "1")
+	mock.ExpectCommit()
+	// The cached responses of the reserved product are dropped
+	redisMock.ExpectDel(productKey(1)).SetVal(1)
+	redisMock.ExpectIncr(productListGenerationKey).SetVal(1)
+	redisMock.ExpectSet(shoppingCart.IPAddress, savedJSON, 24*time.Hour).SetVal("OK")
+
+	body, _ := json.Marshal(shoppingCart)
+	req := httptest.NewRequest(http.MethodPost, "/shopping_carts", strings.NewReader(string(body)))
+	rr := httptest.NewRecorder()
+	sch := ShoppingCartsHandler{db: db, redisClient: redisDB}
+	sch.upsertShoppingCartHandler(rr, req)
+
+	checkResponseCode(t, rr.Code, http.StatusOK)
+	checkResponseBody(t, rr.Body.String(), "", expectedCart)
+	checkMockExpectations(t, mock)
+	if err := redisMock.ExpectationsWereMet(); err != nil {
+		t.Errorf("redis expectations were not met: %s", err.Error())
+	}
+}
+
+func TestUpsertShoppingCartCommitError(t *testing.T) {
+	redisDB, redisMock := redismock.NewClientMock()
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	cart := ShoppingCart{IPAddress: "127.0.0.1", ShoppingCartItems: []ShoppingCartItem{{ProductID: 1, NumberOfProducts: 1}}}
+	cartJSON, err := json.Marshal(cart)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectBegin()
+	expectReleasedReservations(mock, cart.IPAddress, cart.ShoppingCartItems)
+	expectReservation(mock, cart.IPAddress, cart.ShoppingCartItems[0], 1, 0)
+	expectTouchedProducts(mock, "1")
+	// The cart is not saved when its reservations can't be committed
+	mock.ExpectCommit().WillReturnError(errors.New("some error"))
+
+	req := httptest.NewRequest(http.MethodPost, "/shopping_carts", strings.NewReader(string(cartJSON)))
+	rr := httptest.NewRecorder()
+	sch := ShoppingCartsHandler{db: db, redisClient: redisDB}
+	sch.upsertShoppingCartHandler(rr, req)
+
+	checkResponseCode(t, rr.Code, http.StatusInternalServerError)
+	checkMockExpectations(t, mock)
+	if err := redisMock.ExpectationsWereMet(); err != nil {
+		t.Errorf("redis expectations were not met: %s", err.Error())
+	}
 }
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"expvar"
+	"log"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	// productCacheTTL is how long a single product response is cached.
+	productCacheTTL = 5 * time.Minute
+	// productListCacheTTL is how long a products listing response is cached.
+	productListCacheTTL = time.Minute
+	// productListGenerationKey holds a counter that is part of every listing key. Incrementing it
+	// invalidates every cached listing at once, since any product can appear in any listing.
+	productListGenerationKey = "products:generation"
+)
+
+// productCacheStats counts the hits and misses of the product cache, published at /debug/vars.
+var productCacheStats = expvar.NewMap("product_cache")
+
+func init() {
+	productCacheStats.Set("hit_ratio", expvar.Func(func() interface{} {
+		hits, misses := expvarInt("hits"), expvarInt("misses")
+		if hits+misses == 0 {
+			return 0.0
+		}
+		return float64(hits) / float64(hits+misses)
+	}))
+}
+
+func expvarInt(name string) int64 {
+	if v, ok := productCacheStats.Get(name).(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
+
+// cachedResponse is a response body stored in the product cache.
+type cachedResponse struct {
+	Body         []byte    `json:"body"`
+	LastModified time.Time `json:"last_modified"`
+	// Locale is the locale a listing was served in, which can be the default one when none of its products is translated.
+	Locale string `json:"locale,omitempty"`
+}
+
+// productCache is a read-through cache of product responses in Redis.
+// The zero value, without a client, never finds anything and stores nothing.
+// Redis errors are logged and treated as misses, so the database is used when the cache is down.
+type productCache struct {
+	client *redis.Client
+}
+
+// productKey returns the cache key of a single product.
+func productKey(id int) string {
+	return "products:item:" + strconv.Itoa(id)
+}
+
+// listKey returns the cache key of a products listing for the given query parameters.
+// The parameters are normalized, so the same listing requested with its parameters in another order
+// shares the key.
+func (c productCache) listKey(ctx context.Context, query url.Values) (string, bool) {
+	if c.client == nil {
+		return "", false
+	}
+	generation, err := c.client.Get(ctx, productListGenerationKey).Result()
+	if err == redis.Nil {
+		generation = "0"
+	} else if err != nil {
+		log.Println(err)
+		return "", false
+	}
+
+	normalized := url.Values{}
+	for k, values := range query {
+		for _, v := range values {
+			if v != "" {
+				normalized.Add(k, v)
+			}
+		}
+		sort.Strings(normalized[k])
+	}
+	// Encode sorts the parameters by name
+	sum := sha256.Sum256([]byte(normalized.Encode()))
+	return "products:list:" + generation + ":" + hex.EncodeToString(sum[:]), true
+}
+
+// get returns the response cached under key, if there is one.
+func (c productCache) get(ctx context.Context, key string) (cachedResponse, bool) {
+	resp := cachedResponse{}
+	if c.client == nil {
+		return resp, false
+	}
+	data, err := c.client.Get(ctx, key).Bytes()
+	if err != nil || json.Unmarshal(data, &resp) != nil {
+		if err != nil && err != redis.Nil {
+			log.Println(err)
+		}
+		productCacheStats.Add("misses", 1)
+		return resp, false
+	}
+	productCacheStats.Add("hits", 1)
+	return resp, true
+}
+
+// set caches a response under key for ttl.
+func (c productCache) set(ctx context.Context, key string, resp cachedResponse, ttl time.Duration) {
+	if c.client == nil {
+		return
+	}
+	data, err := json.Marshal(resp)
+	if err == nil {
+		err = c.client.Set(ctx, key, data, ttl).Err()
+	}
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// invalidate removes the cached responses of the given products and every cached listing.
+// It must be called after every write that changes what a product response looks like.
+func (c productCache) invalidate(ctx context.Context, ids ...int) {
+	if c.client == nil {
+		return
+	}
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = productKey(id)
+	}
+	if len(keys) > 0 {
+		if err := c.client.Del(ctx, keys...).Err(); err != nil {
+			log.Println(err)
+		}
+	}
+	if err := c.client.Incr(ctx, productListGenerationKey).Err(); err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-redis/redismock/v8"
+)
+
+func TestProductCacheListKey(t *testing.T) {
+	client, mock := redismock.NewClientMock()
+	cache := productCache{client: client}
+	ctx := context.Background()
+
+	mock.ExpectGet(productListGenerationKey).RedisNil()
+	mock.ExpectGet(productListGenerationKey).SetVal("3")
+	mock.ExpectGet(productListGenerationKey).SetErr(errors.New("connection refused"))
+
+	first, ok := cache.listKey(ctx, url.Values{"categories": {"b", "a"}, "order": {"price_asc"}, "name": {""}})
+	if !ok || !strings.HasPrefix(first, "products:list:0:") {
+		t.Errorf("unexpected key: %v", first)
+	}
+	// The same parameters in another order share the listing, in the new generation
+	second, ok := cache.listKey(ctx, url.Values{"order": {"price_asc"}, "categories": {"a", "b"}})
+	if !ok || second != strings.Replace(first, ":0:", ":3:", 1) {
+		t.Errorf("unexpected key: got %v for %v", second, first)
+	}
+	// Listings are not cached when Redis fails
+	if _, ok := cache.listKey(ctx, url.Values{}); ok {
+		t.Error("expected no key when Redis fails")
+	}
+
+	if _, ok := (productCache{}).listKey(ctx, url.Values{}); ok {
+		t.Error("expected no key without a Redis client")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("redis expectations were not met: %s", err.Error())
+	}
+}
+
+func TestProductCacheGetAndSet(t *testing.T) {
+	client, mock := redismock.NewClientMock()
+	cache := productCache{client: client}
+	ctx := context.Background()
+
+	resp := cachedResponse{Body: []byte(`{"id":1}`), LastModified: time.Date(2023, 6, 6, 12, 0, 0, 0, time.UTC)}
+	data, _ := json.Marshal(resp)
+	hits, misses := expvarInt("hits"), expvarInt("misses")
+
+	mock.ExpectGet(productKey(1)).RedisNil()
+	mock.ExpectSet(productKey(1), data, productCacheTTL).SetVal("OK")
+	mock.ExpectGet(productKey(1)).SetVal(string(data))
+
+	if _, ok := cache.get(ctx, productKey(1)); ok {
+		t.Error("expected a miss")
+	}
+	cache.set(ctx, productKey(1), resp, productCacheTTL)
+	got, ok := cache.get(ctx, productKey(1))
+	if !ok || string(got.Body) != string(resp.Body) || !got.LastModified.Equal(resp.LastModified) {
+		t.Errorf("unexpected cached response: %+v", got)
+	}
+
+	if expvarInt("hits") != hits+1 || expvarInt("misses") != misses+1 {
+		t.Errorf("unexpected stats: %v", productCacheStats.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("redis expectations were not met: %s", err.Error())
+	}
+}
+
+func TestProductCacheInvalidate(t *testing.T) {
+	client, mock := redismock.NewClientMock()
+	mock.ExpectDel(productKey(1), productKey(2)).SetVal(2)
+	mock.ExpectIncr(productListGenerationKey).SetVal(4)
+
+	productCache{client: client}.invalidate(context.Background(), 1, 2)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("redis expectations were not met: %s", err.Error())
+	}
+}
+
+func TestGetProduct_Cached(t *testing.T) {
+	client, redisMock := redismock.NewClientMock()
+	db, mock := getMockDB(t)
+	defer db.Close()
+	ph := ProductsHandler{db: db, cache: productCache{client: client}}
+	vars := map[string]string{"id": "1"}
+
+	p := getExpectedProducts()[0]
+	body, _ := encodeJSON(p)
+	cached, _ := json.Marshal(cachedResponse{Body: body, LastModified: p.DateAdded})
+
+	// A miss reads the product from the database and caches it
+	redisMock.ExpectGet(productKey(1)).RedisNil()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT " + productColumns + ", updated_at FROM products WHERE id = $1 AND " + liveCondition)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "slug", "price", "description", "categories", "images", "referenced_name", "date_added", "status", "publish_at", "unpublish_at", "average_rating", "review_count", "stock_quantity", "updated_at"}).
+			AddRow(p.ID, p.Name, p.Slug, p.Price.String(), p.Description, sliceToPostgreSQLArray(p.Categories), sliceToPostgreSQLArray(p.Images), p.ReferencedName, p.DateAdded,
+				p.Status, p.PublishAt, p.UnpublishAt, p.AverageRating, p.ReviewCount, p.AvailableQuantity, p.DateAdded))
+	expectVariants(mock, []Product{p})
+	redisMock.ExpectSet(productKey(1), cached, productCacheTTL).SetVal("OK")
+
+	rr := serveProductRequest(t, ph.getProduct, http.MethodGet, "/products/1", "", vars)
+	checkResponseCode(t, rr.Code, http.StatusOK)
+	checkResponseBody(t, rr.Body.String(), string(body), nil)
+
+	// A hit doesn't touch the database
+	redisMock.ExpectGet(productKey(1)).SetVal(string(cached))
+
+	rr = serveProductRequest(t, ph.getProduct, http.MethodGet, "/products/1", "", vars)
+	checkResponseCode(t, rr.Code, http.StatusOK)
+	checkResponseBody(t, rr.Body.String(), string(body), nil)
+
+	checkMockExpectations(t, mock)
+	if err := redisMock.ExpectationsWereMet(); err != nil {
+		t.Errorf("redis expectations were not met: %s", err.Error())
+	}
+}
+
+func TestGetProducts_CachedCurrencyFallback(t *testing.T) {
+	client, redisMock := redismock.NewClientMock()
+	db, mock := getMockDB(t)
+	defer db.Close()
+	ph := ProductsHandler{db: db, cache: productCache{client: client}}
+	rateQuery := regexp.QuoteMeta("SELECT " + exchangeRateColumns + " FROM exchange_rates WHERE currency = $1")
+
+	// A negotiated currency without a rate falls back to COP, and is cached as the COP listing
+	sum := sha256.Sum256([]byte(""))
+	copKey := "products:list:0:" + hex.EncodeToString(sum[:])
+	products := getExpectedProducts()
+	mock.ExpectQuery(rateQuery).WithArgs("USD").WillReturnRows(getExchangeRateRows())
+	redisMock.ExpectGet(productListGenerationKey).RedisNil()
+	redisMock.ExpectGet(copKey).RedisNil()
+	query, args := expectedQuery("date_added DESC, id DESC", false, false, 0)
+	mock.ExpectQuery(query).WithArgs(args...).WillReturnRows(getMockRows(products))
+	expectVariants(mock, products)
+	redisMock.Regexp().ExpectSet(regexp.QuoteMeta(copKey), ".*", productListCacheTTL).SetVal("OK")
+
+	req := httptest.NewRequest(http.MethodGet, "/products", nil)
+	req.Header.Set("Accept-Currency", "USD")
+	rr := httptest.NewRecorder()
+	ph.getProducts(rr, req)
+	checkResponseCode(t, rr.Code, http.StatusOK)
+	checkResponseBody(t, rr.Body.String(), "", productPage{Products: products})
+
+	// An explicit request for the same currency doesn't get the COP listing from the cache
+	mock.ExpectQuery(rateQuery).WithArgs("USD").WillReturnRows(getExchangeRateRows())
+
+	req = httptest.NewRequest(http.MethodGet, "/products?currency=USD", nil)
+	rr = httptest.NewRecorder()
+	ph.getProducts(rr, req)
+	checkResponseCode(t, rr.Code, http.StatusBadRequest)
+	checkResponseBody(t, rr.Body.String(), "there is no exchange rate for USD\n", nil)
+
+	checkMockExpectations(t, mock)
+	if err := redisMock.ExpectationsWereMet(); err != nil {
+		t.Errorf("redis expectations were not met: %s", err.Error())
+	}
+}
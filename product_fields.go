@@ -0,0 +1,335 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// productField is a field of the product JSON that can be requested with the fields query parameter.
+type productField struct {
+	// column is the SQL expression selected for the field. It is empty for the search fields,
+	// which are selected with searchColumns.
+	column string
+	// dest returns the destination the column is scanned into.
+	dest func(p *Product) interface{}
+	// value returns the JSON value of the field.
+	value func(p Product) interface{}
+}
+
+// productFieldOrder lists every field accepted by the fields query parameter, in the order of productColumns.
+var productFieldOrder = []string{"id", "name", "slug", "price", "description", "categories", "images", "image", "referenced_name", "date_added", "status", "publish_at", "unpublish_at", "average_rating", "review_count", "available_quantity", "rank", "snippet"}
+
+// productFields maps every field accepted by the fields query parameter to its column and value.
+var productFields = map[string]productField{
+	"id": {
+		column: "id",
+		dest:   func(p *Product) interface{} { return &p.ID },
+		value:  func(p Product) interface{} { return p.ID },
+	},
+	"name": {
+		column: "name",
+		dest:   func(p *Product) interface{} { return &p.Name },
+		value:  func(p Product) interface{} { return p.Name },
+	},
+	"slug": {
+		column: "slug",
+		dest:   func(p *Product) interface{} { return &p.Slug },
+		value:  func(p Product) interface{} { return p.Slug },
+	},
+	"price": {
+		column: "price",
+		dest:   func(p *Product) interface{} { return &p.Price },
+		value:  func(p Product) interface{} { return p.Price },
+	},
+	"description": {
+		column: "description",
+		dest:   func(p *Product) interface{} { return &p.Description },
+		value:  func(p Product) interface{} { return p.Description },
+	},
+	"categories": {
+		column: "categories",
+		dest:   func(p *Product) interface{} { return (*textArray)(&p.Categories) },
+		value:  func(p Product) interface{} { return p.Categories },
+	},
+	"images": {
+		column: "images",
+		dest:   func(p *Product) interface{} { return (*textArray)(&p.Images) },
+		value:  func(p Product) interface{} { return p.Images },
+	},
+	// image is the first image only, for thumbnails. It is read from images when both are requested.
+	"image": {
+		column: "images[1:1]",
+		dest:   func(p *Product) interface{} { return (*textArray)(&p.Images) },
+		value: func(p Product) interface{} {
+			if len(p.Images) == 0 {
+				return nil
+			}
+			return p.Images[0]
+		},
+	},
+	"referenced_name": {
+		column: "referenced_name",
+		dest:   func(p *Product) interface{} { return &p.ReferencedName },
+		value:  func(p Product) interface{} { return p.ReferencedName },
+	},
+	"date_added": {
+		column: "date_added",
+		dest:   func(p *Product) interface{} { return &p.DateAdded },
+		value:  func(p Product) interface{} { return p.DateAdded },
+	},
+	"status": {
+		column: "status",
+		dest:   func(p *Product) interface{} { return &p.Status },
+		value:  func(p Product) interface{} { return p.Status },
+	},
+	"publish_at": {
+		column: "publish_at",
+		dest:   func(p *Product) interface{} { return &p.PublishAt },
+		value:  func(p Product) interface{} { return p.PublishAt },
+	},
+	"unpublish_at": {
+		column: "unpublish_at",
+		dest:   func(p *Product) interface{} { return &p.UnpublishAt },
+		value:  func(p Product) interface{} { return p.UnpublishAt },
+	},
+	"average_rating": {
+		column: "average_rating",
+		dest:   func(p *Product) interface{} { return &p.AverageRating },
+		value:  func(p Product) interface{} { return p.AverageRating },
+	},
+	"review_count": {
+		column: "review_count",
+		dest:   func(p *Product) interface{} { return &p.ReviewCount },
+		value:  func(p Product) interface{} { return p.ReviewCount },
+	},
+	"available_quantity": {
+		column: availableQuantity,
+		dest:   func(p *Product) interface{} { return &p.AvailableQuantity },
+		value:  func(p Product) interface{} { return p.AvailableQuantity },
+	},
+	"rank": {
+		value: func(p Product) interface{} { return p.Rank },
+	},
+	"snippet": {
+		value: func(p Product) interface{} { return p.Snippet },
+	},
+}
+
+// fieldSelection holds the fields and include query parameters of a product response.
+type fieldSelection struct {
+	// fields has the requested fields, or is nil for every field.
+	fields map[string]bool
+	// include has the related resources to embed: "categories" and/or "variants".
+	include map[string]bool
+}
+
+// parseFieldSelection reads the fields and include query parameters.
+//
+// fields is a comma separated list of product fields (see productFieldOrder), and the id is always returned.
+// include is a comma separated list of related resources to embed in every product: "categories" replaces
+// the category slugs with the categories, and "variants" adds the product variants, which are only returned
+// by default when every field is. It returns a validationError for unknown fields or resources.
+func parseFieldSelection(query url.Values) (fieldSelection, error) {
+	fs := fieldSelection{include: map[string]bool{}}
+
+	if v := query.Get("fields"); v != "" {
+		fs.fields = map[string]bool{"id": true}
+		for _, name := range strings.Split(v, ",") {
+			name = strings.TrimSpace(name)
+			if _, ok := productFields[name]; !ok {
+				return fs, validationError{fmt.Sprintf("unknown field %q", name)}
+			}
+			fs.fields[name] = true
+		}
+	}
+
+	if v := query.Get("include"); v != "" {
+		for _, name := range strings.Split(v, ",") {
+			name = strings.TrimSpace(name)
+			if name != "categories" && name != "variants" {
+				return fs, validationError{fmt.Sprintf("unknown include %q: must be categories or variants", name)}
+			}
+			fs.include[name] = true
+		}
+	}
+
+	return fs, nil
+}
+
+// sparse reports whether the response differs from the full Product JSON.
+func (fs fieldSelection) sparse() bool {
+	return fs.fields != nil || len(fs.include) > 0
+}
+
+// variants reports whether the variants of the products must be loaded.
+func (fs fieldSelection) variants() bool {
+	return fs.fields == nil || fs.include["variants"]
+}
+
+// selected reports whether the column of a field must be selected. sortField is the field the results
+// are ordered by, which is needed to build the page cursors.
+func (fs fieldSelection) selected(name, sortField string) bool {
+	if fs.fields == nil {
+		return name != "image"
+	}
+	switch name {
+	case sortField:
+		return true
+	case "categories":
+		return fs.fields[name] || fs.include["categories"]
+	case "image":
+		return fs.fields[name] && !fs.fields["images"]
+	}
+	return fs.fields[name]
+}
+
+// columns returns the SQL columns to select for the selection, and the destinations to scan them into.
+func (fs fieldSelection) columns(sortField string) (string, func(p *Product) []interface{}) {
+	if fs.fields == nil {
+		return productColumns, productDest
+	}
+
+	columns := []string{}
+	fields := []productField{}
+	for _, name := range productFieldOrder {
+		f := productFields[name]
+		if f.column != "" && fs.selected(name, sortField) {
+			columns = append(columns, f.column)
+			fields = append(fields, f)
+		}
+	}
+	return strings.Join(columns, ", "), func(p *Product) []interface{} {
+		dest := make([]interface{}, len(fields))
+		for i, f := range fields {
+			dest[i] = f.dest(p)
+		}
+		return dest
+	}
+}
+
+// render returns the JSON object of a product with the selected fields and included resources.
+// categories has the categories embedded with include=categories, by slug.
+func (fs fieldSelection) render(p Product, categories map[string]productCategory) map[string]interface{} {
+	out := map[string]interface{}{}
+	for _, name := range productFieldOrder {
+		if fs.fields == nil {
+			// Like the Product JSON: no image, the publishing window only when set, and the search fields only for searches
+			if name == "image" || (name == "publish_at" && p.PublishAt == nil) || (name == "unpublish_at" && p.UnpublishAt == nil) ||
+				(name == "rank" && p.Rank == 0) || (name == "snippet" && p.Snippet == "") {
+				continue
+			}
+		} else if !fs.fields[name] {
+			continue
+		}
+		out[name] = productFields[name].value(p)
+	}
+
+	if p.ExchangeRate != nil && (fs.fields == nil || fs.fields["price"]) {
+		out["exchange_rate"] = p.ExchangeRate
+	}
+
+	if fs.include["categories"] {
+		embedded := make([]productCategory, len(p.Categories))
+		for i, slug := range p.Categories {
+			c, ok := categories[slug]
+			if !ok {
+				c = productCategory{Slug: slug}
+			}
+			embedded[i] = c
+		}
+		out["categories"] = embedded
+	}
+
+	if fs.include["variants"] {
+		variants := p.Variants
+		if variants == nil {
+			variants = []ProductVariant{}
+		}
+		out["variants"] = variants
+	} else if fs.fields == nil && len(p.Variants) > 0 {
+		out["variants"] = p.Variants
+	}
+	return out
+}
+
+// productCategory is a category embedded in a product with include=categories.
+type productCategory struct {
+	Slug     string `json:"slug"`
+	Name     string `json:"name,omitempty"`
+	ParentID *int   `json:"parent_id,omitempty"`
+}
+
+// loadProductCategories returns the categories of the given products, by slug, with their names in the locale.
+func loadProductCategories(db *sql.DB, products []Product, locale string) (map[string]productCategory, error) {
+	slugs := textArray{}
+	seen := map[string]bool{}
+	for _, p := range products {
+		for _, slug := range p.Categories {
+			if !seen[slug] {
+				seen[slug] = true
+				slugs = append(slugs, slug)
+			}
+		}
+	}
+	categories := map[string]productCategory{}
+	if len(slugs) == 0 {
+		return categories, nil
+	}
+
+	rows, err := db.Query("SELECT slug, name, parent_id FROM categories WHERE slug = ANY($1)", slugs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		c := productCategory{}
+		if err := rows.Scan(&c.Slug, &c.Name, &c.ParentID); err != nil {
+			return nil, err
+		}
+		categories[c.Slug] = c
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	names, _, err := loadCategoryNames(db, locale)
+	if err != nil {
+		return nil, err
+	}
+	for slug, name := range names {
+		if c, ok := categories[slug]; ok {
+			c.Name = name
+			categories[slug] = c
+		}
+	}
+	return categories, nil
+}
+
+// sparseProductPage is the JSON response of a products listing with a field selection.
+// Its Products hide the ones of the embedded productPage.
+type sparseProductPage struct {
+	productPage
+	Products []map[string]interface{} `json:"products"`
+}
+
+// renderProducts returns the JSON objects of the products for a sparse field selection,
+// loading the categories to embed, with their names in the locale, if they were included.
+func renderProducts(db *sql.DB, fs fieldSelection, products []Product, locale string) ([]map[string]interface{}, error) {
+	var categories map[string]productCategory
+	if fs.include["categories"] {
+		var err error
+		categories, err = loadProductCategories(db, products, locale)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	out := make([]map[string]interface{}, len(products))
+	for i, p := range products {
+		out[i] = fs.render(p, categories)
+	}
+	return out, nil
+}
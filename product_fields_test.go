@@ -0,0 +1,120 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestParseFieldSelection_Errors(t *testing.T) {
+	tests := []struct {
+		query       url.Values
+		expectedErr string
+	}{
+		{query: url.Values{"fields": {"name,weight"}}, expectedErr: `unknown field "weight"`},
+		{query: url.Values{"include": {"reviews"}}, expectedErr: `unknown include "reviews": must be categories or variants`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expectedErr, func(t *testing.T) {
+			_, err := parseFieldSelection(tt.query)
+			if _, ok := err.(validationError); !ok || err.Error() != tt.expectedErr {
+				t.Errorf("unexpected error, expected %q but got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestFieldSelectionColumns(t *testing.T) {
+	tests := []struct {
+		name            string
+		query           url.Values
+		sortField       string
+		expectedColumns string
+	}{
+		{name: "Every field", query: url.Values{}, sortField: "date_added", expectedColumns: productColumns},
+		{name: "Grid fields", query: url.Values{"fields": {"name,price,image"}}, sortField: "price", expectedColumns: "id, name, price, images[1:1]"},
+		{name: "Sort field is selected", query: url.Values{"fields": {"name"}}, sortField: "date_added", expectedColumns: "id, name, date_added"},
+		{name: "Image from images", query: url.Values{"fields": {"images,image"}}, sortField: "rank", expectedColumns: "id, images"},
+		{name: "Included categories are selected", query: url.Values{"fields": {"name"}, "include": {"categories"}}, sortField: "", expectedColumns: "id, name, categories"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs, err := parseFieldSelection(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			columns, dest := fs.columns(tt.sortField)
+			if columns != tt.expectedColumns {
+				t.Errorf("unexpected columns: got %q want %q", columns, tt.expectedColumns)
+			}
+			if got, expected := len(dest(&Product{})), len(regexp.MustCompile(`, [a-z_]`).FindAllString(columns, -1))+1; got != expected {
+				t.Errorf("unexpected number of destinations: got %d want %d", got, expected)
+			}
+		})
+	}
+}
+
+func TestFieldSelectionRender(t *testing.T) {
+	p := getExpectedProducts()[0]
+	p.Variants = []ProductVariant{{ID: 1, ProductID: 1, SKU: "A-1"}}
+	parentID := 4
+	categories := map[string]productCategory{"cat1": {Slug: "cat1", Name: "Mugs", ParentID: &parentID}}
+
+	fs, _ := parseFieldSelection(url.Values{"fields": {"name,image"}, "include": {"categories"}})
+	expected := map[string]interface{}{
+		"id":         1,
+		"name":       "Product A",
+		"image":      "img1",
+		"categories": []productCategory{categories["cat1"], {Slug: "cat2"}},
+	}
+	if got := fs.render(p, categories); !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected render: got %v want %v", got, expected)
+	}
+
+	// Variants are only returned with every field or when included
+	fs, _ = parseFieldSelection(url.Values{"include": {"variants"}})
+	if got := fs.render(p, nil); !reflect.DeepEqual(got["variants"], p.Variants) || got["description"] != p.Description {
+		t.Errorf("unexpected render: %v", got)
+	}
+}
+
+func TestGetProducts_Fields(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	products := getExpectedProducts()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, name, price, categories, images[1:1], date_added FROM products WHERE " + liveCondition + " ORDER BY date_added DESC, id DESC LIMIT $1")).
+		WithArgs(defaultPageLimit + 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price", "categories", "images", "date_added"}).
+			AddRow(products[0].ID, products[0].Name, products[0].Price.String(), sliceToPostgreSQLArray(products[0].Categories), sliceToPostgreSQLArray(products[0].Images[:1]), products[0].DateAdded))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT slug, name, parent_id FROM categories WHERE slug = ANY($1)")).
+		WithArgs(textArray{"cat1", "cat2"}).
+		WillReturnRows(sqlmock.NewRows([]string{"slug", "name", "parent_id"}).AddRow("cat1", "Mugs", nil).AddRow("cat2", "Blue", 1))
+
+	rr := makeRequest(t, db, "/products?fields=name,price,image&include=categories")
+
+	parentID := 1
+	expected := sparseProductPage{Products: []map[string]interface{}{{
+		"id":         products[0].ID,
+		"name":       products[0].Name,
+		"price":      products[0].Price,
+		"image":      "img1",
+		"categories": []productCategory{{Slug: "cat1", Name: "Mugs"}, {Slug: "cat2", Name: "Blue", ParentID: &parentID}},
+	}}}
+	checkResponseCode(t, rr.Code, http.StatusOK)
+	checkResponseBody(t, rr.Body.String(), "", expected)
+	checkMockExpectations(t, mock)
+}
+
+func TestGetProducts_InvalidFields(t *testing.T) {
+	rr := makeRequest(t, nil, "/products?fields=weight")
+
+	checkResponseCode(t, rr.Code, http.StatusBadRequest)
+	checkResponseBody(t, rr.Body.String(), "unknown field \"weight\"\n", nil)
+}
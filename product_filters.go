@@ -0,0 +1,259 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// searchConfig is the PostgreSQL text search configuration used to build products.search_vector.
+	searchConfig = "spanish"
+	// searchColumns are the extra columns selected by a full-text search, scanned into Product.Rank and Product.Snippet.
+	searchColumns = "ts_rank(search_vector, query), ts_headline('" + searchConfig + "', description, query, 'StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=20, MinWords=5')"
+	// inStockCondition matches the products that have units available, themselves or in any of their variants.
+	inStockCondition = "(" + availableQuantity + " > 0 OR EXISTS (SELECT 1 FROM product_variants WHERE product_variants.product_id = products.id AND " + availableVariantStock + " > 0))"
+)
+
+// productFilter holds the optional filters that can be applied to a products listing.
+// The zero value matches every product.
+type productFilter struct {
+	// Query is a full-text search over the name, description, categories and referenced name.
+	Query          string
+	Name           string
+	ReferencedName string
+	Categories     []string
+	// CategoryGroups has, for every category in Categories, the slugs that satisfy it: the category itself
+	// and, once resolved with resolveCategories, all of its descendants.
+	CategoryGroups [][]string
+	// AllCategories requires products to belong to every category in Categories instead of any of them.
+	AllCategories bool
+	MinPrice      *Money
+	MaxPrice      *Money
+	AddedAfter    time.Time
+	AddedBefore   time.Time
+	// InStock, when set, keeps only the products that are (true) or are not (false) in stock.
+	InStock *bool
+	// Status keeps only the products with a status, or the scheduled ones (see statusConditions).
+	Status string
+	// LiveOnly keeps only the products visible to shoppers. It is set for every request that is not from an admin.
+	LiveOnly bool
+}
+
+// parseProductFilter reads the product filters from the URL query parameters.
+//
+// q is a full-text search written in the same syntax as web search engines (quoted phrases, "or" and -exclusions),
+// name and referenced_name are case-insensitive substring matches, and categories can be repeated
+// to match products that belong to any of the given categories, or to all of them with all_categories=true.
+// min_price and max_price are inclusive bounds in the store currency, and added_after (inclusive) and
+// added_before (exclusive) take RFC 3339 timestamps or dates (midnight UTC). in_stock=true keeps the products
+// with units on hand, in themselves or in any of their variants, and in_stock=false the sold out ones.
+// status is draft, published, archived or scheduled.
+//
+// It returns a validationError if any of the values is malformed.
+func parseProductFilter(query url.Values) (productFilter, error) {
+	f := productFilter{
+		Query:          strings.TrimSpace(query.Get("q")),
+		Name:           query.Get("name"),
+		ReferencedName: query.Get("referenced_name"),
+		Categories:     query["categories"],
+	}
+	for _, c := range f.Categories {
+		f.CategoryGroups = append(f.CategoryGroups, []string{c})
+	}
+
+	var err error
+	if v := query.Get("all_categories"); v != "" {
+		f.AllCategories, err = strconv.ParseBool(v)
+		if err != nil {
+			return f, validationError{"all_categories must be true or false"}
+		}
+	}
+
+	if v := query.Get("in_stock"); v != "" {
+		inStock, err := strconv.ParseBool(v)
+		if err != nil {
+			return f, validationError{"in_stock must be true or false"}
+		}
+		f.InStock = &inStock
+	}
+
+	if v := query.Get("status"); v != "" {
+		if _, ok := statusConditions[v]; !ok {
+			return f, validationError{"status must be draft, published, archived or scheduled"}
+		}
+		f.Status = v
+	}
+
+	if f.MinPrice, err = parsePriceParam(query, "min_price"); err != nil {
+		return f, err
+	}
+	if f.MaxPrice, err = parsePriceParam(query, "max_price"); err != nil {
+		return f, err
+	}
+	if f.MinPrice != nil && f.MaxPrice != nil && f.MinPrice.Amount > f.MaxPrice.Amount {
+		return f, validationError{"min_price must not be greater than max_price"}
+	}
+
+	if f.AddedAfter, err = parseTimeParam(query, "added_after"); err != nil {
+		return f, err
+	}
+	if f.AddedBefore, err = parseTimeParam(query, "added_before"); err != nil {
+		return f, err
+	}
+	if !f.AddedAfter.IsZero() && !f.AddedBefore.IsZero() && !f.AddedAfter.Before(f.AddedBefore) {
+		return f, validationError{"added_after must be before added_before"}
+	}
+
+	return f, nil
+}
+
+// parsePriceParam parses the named query parameter as a non-negative amount in the store currency.
+// It returns nil if the parameter is not set.
+func parsePriceParam(query url.Values, name string) (*Money, error) {
+	v := query.Get(name)
+	if v == "" {
+		return nil, nil
+	}
+	m, err := parseMoney(v, storeCurrency)
+	if err != nil || m.Amount < 0 {
+		return nil, validationError{fmt.Sprintf("%s must be a non-negative amount with at most %d decimals", name, priceScale)}
+	}
+	return &m, nil
+}
+
+// parseTimeParam parses the named query parameter as an RFC 3339 timestamp or a date.
+// It returns the zero time if the parameter is not set.
+func parseTimeParam(query url.Values, name string) (time.Time, error) {
+	v := query.Get(name)
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("2006-01-02", v); err == nil {
+		return t, nil
+	}
+	return time.Time{}, validationError{fmt.Sprintf("%s must be a date (2006-01-02) or an RFC 3339 timestamp", name)}
+}
+
+// selectProducts returns the SELECT and FROM clauses of a products listing of the given columns, usually
+// productColumns, binding its arguments to qb. When the filter has a full-text search query, the rank and
+// highlighted snippet are selected after the columns.
+func (f productFilter) selectProducts(qb *queryBuilder, columns string) string {
+	if f.Query == "" {
+		return "SELECT " + columns + " FROM " + f.fromProducts(qb)
+	}
+	return "SELECT " + columns + ", " + searchColumns + " FROM " + f.fromProducts(qb)
+}
+
+// fromProducts returns the tables of the FROM clause the filter conditions apply to, binding its arguments to qb.
+// When the filter has a full-text search query, the parsed query is joined as `query` so the conditions
+// and the relevance sort can refer to it.
+func (f productFilter) fromProducts(qb *queryBuilder) string {
+	if f.Query == "" {
+		return "products"
+	}
+	return "products, websearch_to_tsquery('" + searchConfig + "', " + qb.bind(f.Query) + ") query"
+}
+
+// apply adds a condition to the query builder for every filter that is set.
+// The query builder's base must have been built with selectProducts or fromProducts.
+func (f productFilter) apply(qb *queryBuilder) {
+	if f.Query != "" {
+		qb.where("search_vector @@ query")
+	}
+
+	if f.Name != "" {
+		qb.where("name ILIKE ?", "%"+escapeLike(f.Name)+"%")
+	}
+
+	if f.ReferencedName != "" {
+		qb.where("referenced_name ILIKE ?", "%"+escapeLike(f.ReferencedName)+"%")
+	}
+
+	// Products that share at least one category with the filter (array overlap), or that have all of them.
+	// With AllCategories, categories without descendants are matched at once (array containment)
+	// and every category with descendants needs its own overlap condition.
+	if len(f.CategoryGroups) > 0 {
+		if f.AllCategories {
+			contained := textArray{}
+			overlaps := []textArray{}
+			for _, group := range f.CategoryGroups {
+				if len(group) == 1 {
+					contained = append(contained, group[0])
+				} else {
+					overlaps = append(overlaps, textArray(group))
+				}
+			}
+			if len(contained) > 0 {
+				qb.where("categories @> ?", contained)
+			}
+			for _, group := range overlaps {
+				qb.where("categories && ?", group)
+			}
+		} else {
+			slugs := textArray{}
+			for _, group := range f.CategoryGroups {
+				slugs = append(slugs, group...)
+			}
+			qb.where("categories && ?", slugs)
+		}
+	}
+
+	if f.MinPrice != nil {
+		qb.where("price >= ?", *f.MinPrice)
+	}
+	if f.MaxPrice != nil {
+		qb.where("price <= ?", *f.MaxPrice)
+	}
+
+	if !f.AddedAfter.IsZero() {
+		qb.where("date_added >= ?", f.AddedAfter)
+	}
+	if !f.AddedBefore.IsZero() {
+		qb.where("date_added < ?", f.AddedBefore)
+	}
+
+	if f.InStock != nil {
+		if *f.InStock {
+			qb.where(inStockCondition)
+		} else {
+			qb.where("NOT " + inStockCondition)
+		}
+	}
+
+	if f.Status != "" {
+		qb.where(statusConditions[f.Status])
+	}
+	if f.LiveOnly {
+		qb.where(liveCondition)
+	}
+}
+
+// resolveCategories checks that every category of the filter exists and expands them to their descendants,
+// so filtering by a parent category also matches the products of its subcategories.
+// It returns a validationError if a category does not exist.
+func (f productFilter) resolveCategories(db *sql.DB) (productFilter, error) {
+	if len(f.Categories) == 0 {
+		return f, nil
+	}
+
+	tree, err := expandCategories(db, f.Categories)
+	if err != nil {
+		return f, err
+	}
+	if err := unknownCategoriesError(f.Categories, func(slug string) bool { return len(tree[slug]) > 0 }); err != nil {
+		return f, err
+	}
+
+	f.CategoryGroups = make([][]string, len(f.Categories))
+	for i, c := range f.Categories {
+		f.CategoryGroups[i] = tree[c]
+	}
+	return f, nil
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestProductFilterApply(t *testing.T) {
+	tests := []struct {
+		name          string
+		query         string
+		expectedQuery string
+		expectedArgs  []interface{}
+	}{
+		{
+			name:          "No filters",
+			query:         "",
+			expectedQuery: "SELECT id FROM products",
+			expectedArgs:  []interface{}{},
+		},
+		{
+			name:          "Name with wildcard characters",
+			query:         "name=100%25",
+			expectedQuery: "SELECT id FROM products WHERE name ILIKE $1",
+			expectedArgs:  []interface{}{`%100\%%`},
+		},
+		{
+			name:          "All filters",
+			query:         "name=mug&referenced_name=blue&categories=a&categories=b",
+			expectedQuery: "SELECT id FROM products WHERE name ILIKE $1 AND referenced_name ILIKE $2 AND categories && $3",
+			expectedArgs:  []interface{}{"%mug%", "%blue%", textArray{"a", "b"}},
+		},
+		{
+			name:          "All categories",
+			query:         "categories=a&categories=b&all_categories=true",
+			expectedQuery: "SELECT id FROM products WHERE categories @> $1",
+			expectedArgs:  []interface{}{textArray{"a", "b"}},
+		},
+		{
+			name:          "Price range",
+			query:         "min_price=10&max_price=50000.5",
+			expectedQuery: "SELECT id FROM products WHERE price >= $1 AND price <= $2",
+			expectedArgs:  []interface{}{Money{Amount: 1000, Currency: storeCurrency}, Money{Amount: 5000050, Currency: storeCurrency}},
+		},
+		{
+			name:          "Date added range",
+			query:         "added_after=2023-04-01&added_before=2023-04-08T10:00:00-05:00",
+			expectedQuery: "SELECT id FROM products WHERE date_added >= $1 AND date_added < $2",
+			expectedArgs: []interface{}{
+				time.Date(2023, 4, 1, 0, 0, 0, 0, time.UTC),
+				time.Date(2023, 4, 8, 10, 0, 0, 0, time.FixedZone("", -5*60*60)),
+			},
+		},
+		{
+			name:          "In stock",
+			query:         "in_stock=true",
+			expectedQuery: "SELECT id FROM products WHERE " + inStockCondition,
+			expectedArgs:  []interface{}{},
+		},
+		{
+			name:          "Sold out",
+			query:         "in_stock=false",
+			expectedQuery: "SELECT id FROM products WHERE NOT " + inStockCondition,
+			expectedArgs:  []interface{}{},
+		},
+		{
+			name:          "Scheduled",
+			query:         "status=scheduled",
+			expectedQuery: "SELECT id FROM products WHERE status = 'published' AND publish_at > NOW()",
+			expectedArgs:  []interface{}{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f, err := parseProductFilter(values)
+			if err != nil {
+				t.Fatal(err)
+			}
+			qb := newQueryBuilder("SELECT id FROM products")
+			f.apply(qb)
+			query, args := qb.build()
+			if query != tt.expectedQuery {
+				t.Errorf("unexpected query: got %q want %q", query, tt.expectedQuery)
+			}
+			if len(args) != len(tt.expectedArgs) {
+				t.Fatalf("unexpected args: got %v want %v", args, tt.expectedArgs)
+			}
+			for i := range args {
+				// Times from different locations are compared by instant
+				if expectedTime, ok := tt.expectedArgs[i].(time.Time); ok && expectedTime.Equal(args[i].(time.Time)) {
+					continue
+				}
+				if !reflect.DeepEqual(args[i], tt.expectedArgs[i]) {
+					t.Errorf("unexpected arg %d: got %v want %v", i, args[i], tt.expectedArgs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseProductFilter_Errors(t *testing.T) {
+	tests := []struct {
+		query       string
+		expectedErr string
+	}{
+		{query: "all_categories=maybe", expectedErr: "all_categories must be true or false"},
+		{query: "in_stock=yes", expectedErr: "in_stock must be true or false"},
+		{query: "status=deleted", expectedErr: "status must be draft, published, archived or scheduled"},
+		{query: "min_price=cheap", expectedErr: "min_price must be a non-negative amount with at most 2 decimals"},
+		{query: "max_price=-1", expectedErr: "max_price must be a non-negative amount with at most 2 decimals"},
+		{query: "min_price=1.001", expectedErr: "min_price must be a non-negative amount with at most 2 decimals"},
+		{query: "min_price=10&max_price=5", expectedErr: "min_price must not be greater than max_price"},
+		{query: "added_after=yesterday", expectedErr: "added_after must be a date (2006-01-02) or an RFC 3339 timestamp"},
+		{query: "added_after=2023-05-01&added_before=2023-04-01", expectedErr: "added_after must be before added_before"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			values, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = parseProductFilter(values)
+			if err == nil || err.Error() != tt.expectedErr {
+				t.Errorf("unexpected error, expected %q but got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestProductFilterSelectProducts_Search(t *testing.T) {
+	f, err := parseProductFilter(url.Values{"q": {" blue mug "}, "name": {"mug"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	qb := newQueryBuilder("")
+	qb.base = f.selectProducts(qb, productColumns)
+	f.apply(qb)
+	query, args := qb.build()
+
+	expectedQuery := "SELECT " + productColumns + ", " + searchColumns +
+		" FROM products, websearch_to_tsquery('spanish', $1) query WHERE search_vector @@ query AND name ILIKE $2"
+	if query != expectedQuery {
+		t.Errorf("unexpected query: got %q want %q", query, expectedQuery)
+	}
+	expectedArgs := []interface{}{"blue mug", "%mug%"}
+	if !reflect.DeepEqual(args, expectedArgs) {
+		t.Errorf("unexpected args: got %v want %v", args, expectedArgs)
+	}
+}
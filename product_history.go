@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultHistoryLimit = 20
+	maxHistoryLimit     = 100
+)
+
+// actorHeader is the header admin requests can name the person making a change with. The admin token is shared,
+// so the name is only as trustworthy as whoever has the token.
+const actorHeader = "X-Actor"
+
+// defaultActor is the actor of the admin requests without an actorHeader.
+const defaultActor = "admin"
+
+// Operations of the changes in the history of a product.
+const (
+	historyCreate = "create"
+	historyUpdate = "update"
+	historyDelete = "delete"
+)
+
+// historyFields are the product columns stored in the history, which the changes can be filtered by.
+var historyFields = map[string]bool{
+	"id": true, "name": true, "slug": true, "price": true, "description": true, "categories": true, "images": true, "referenced_name": true,
+	"date_added": true, "status": true, "publish_at": true, "unpublish_at": true, "average_rating": true, "review_count": true, "stock_quantity": true,
+}
+
+// ProductChange is a change in the history of a product, recorded by the database on every insert, update or delete.
+// OldValues and NewValues have the columns of the product as stored, so prices are amounts in the store currency.
+type ProductChange struct {
+	ID        int    `json:"id"`
+	ProductID int    `json:"product_id"`
+	Operation string `json:"operation"`
+	// OldValues is not set for creations, nor NewValues for deletions.
+	OldValues json.RawMessage `json:"old_values,omitempty"`
+	NewValues json.RawMessage `json:"new_values,omitempty"`
+	// Changed has the names of the columns whose values changed.
+	Changed   []string  `json:"changed"`
+	Actor     string    `json:"actor"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// productChangeColumns is the list of columns selected for every ProductChange, in the order expected by scanProductChange.
+const productChangeColumns = "id, product_id, operation, old_values, new_values, actor, changed_at"
+
+// scanProductChange scans a row selected with productChangeColumns into c, and lists the changed columns.
+func scanProductChange(rs rowScanner, c *ProductChange) error {
+	var oldValues, newValues []byte
+	err := rs.Scan(&c.ID, &c.ProductID, &c.Operation, &oldValues, &newValues, &c.Actor, &c.ChangedAt)
+	if err != nil {
+		return err
+	}
+	if oldValues != nil {
+		c.OldValues = oldValues
+	}
+	if newValues != nil {
+		c.NewValues = newValues
+	}
+	c.Changed, err = changedColumns(oldValues, newValues)
+	return err
+}
+
+// changedColumns returns the sorted names of the columns that have different values in two JSON objects of
+// product values, either of which can be missing.
+func changedColumns(oldValues, newValues []byte) ([]string, error) {
+	before, after := map[string]json.RawMessage{}, map[string]json.RawMessage{}
+	if oldValues != nil {
+		if err := json.Unmarshal(oldValues, &before); err != nil {
+			return nil, err
+		}
+	}
+	if newValues != nil {
+		if err := json.Unmarshal(newValues, &after); err != nil {
+			return nil, err
+		}
+	}
+
+	changed := []string{}
+	for name := range historyFields {
+		o, inOld := before[name]
+		n, inNew := after[name]
+		if inOld != inNew || !bytes.Equal(o, n) {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed, nil
+}
+
+// requestActor returns the actor of an admin request, the one named by its actorHeader or else defaultActor.
+func requestActor(r *http.Request) string {
+	if actor := strings.TrimSpace(r.Header.Get(actorHeader)); actor != "" {
+		return actor
+	}
+	return defaultActor
+}
+
+// runAsActor runs fn in a transaction where the changes to products are recorded in their history as made by
+// actor, and commits it if fn succeeds. The error of fn is returned unchanged, after rolling the transaction back.
+func runAsActor(ctx context.Context, db *sql.DB, actor string, fn func(tx *sql.Tx) error) error {
+	tx, err := beginAsActor(ctx, db, actor)
+	if err != nil {
+		return err
+	}
+	// The rollback is a no-op once the transaction is committed
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// beginAsActor begins a transaction where the changes to products are recorded in their history as made by actor.
+func beginAsActor(ctx context.Context, db *sql.DB, actor string) (*sql.Tx, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	// The setting is local to the transaction, so it doesn't leak to other requests through the pool
+	_, err = tx.ExecContext(ctx, "SELECT set_config('app.actor', $1, true)", actor)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	return tx, nil
+}
+
+// productVersionQuery returns the query of the given columns of the version of a product at a point in time, from
+// its history, followed by the time of the change that made it. It has no rows if the product did not exist then.
+// The columns are computed from the values of that version, except for the reservations in the available quantity,
+// which are the current ones.
+func productVersionQuery(columns string) string {
+	return "SELECT " + columns + ", version.changed_at FROM (SELECT new_values, changed_at FROM product_history " +
+		"WHERE product_id = $1 AND changed_at <= $2 ORDER BY changed_at DESC, id DESC LIMIT 1) version, " +
+		"jsonb_populate_record(NULL::products, version.new_values) products WHERE version.new_values IS NOT NULL"
+}
+
+// parseAsOf parses the as_of query parameter of a product, the time of the version to return. It returns the zero
+// time if the parameter is not set, and a validationError if it is malformed.
+func parseAsOf(query url.Values) (time.Time, error) {
+	return parseTimeParam(query, "as_of")
+}
+
+// historyPageRequest holds the pagination and filter query parameters of the history of a product.
+type historyPageRequest struct {
+	limit  int
+	cursor *productCursor
+	// field, when set, lists only the changes to the column with that name.
+	field string
+}
+
+// parseHistoryPageRequest reads the limit, cursor and field query parameters of the history of a product.
+//
+// Changes are listed from the newest, with keyset pagination on their date and ID like the products listing.
+// limit defaults to defaultHistoryLimit and can't be bigger than maxHistoryLimit, and field must be one of
+// historyFields. It returns a validationError if any of the values is malformed.
+func parseHistoryPageRequest(query url.Values) (historyPageRequest, error) {
+	pr := historyPageRequest{limit: defaultHistoryLimit}
+
+	if l := query.Get("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit < 1 || limit > maxHistoryLimit {
+			return pr, validationError{fmt.Sprintf("limit must be a number between 1 and %d", maxHistoryLimit)}
+		}
+		pr.limit = limit
+	}
+
+	if c := query.Get("cursor"); c != "" {
+		cursor, err := decodeProductCursor(c)
+		if err != nil {
+			return pr, err
+		}
+		if cursor.Order != "history" {
+			return pr, validationError{"invalid cursor"}
+		}
+		pr.cursor = &cursor
+	}
+
+	if f := query.Get("field"); f != "" {
+		if !historyFields[f] {
+			return pr, validationError{fmt.Sprintf("unknown field %q", f)}
+		}
+		pr.field = f
+	}
+
+	return pr, nil
+}
+
+// apply adds the conditions, the ORDER BY and the LIMIT of the page to the query builder.
+// One extra row is requested to know whether there is a next page.
+func (pr historyPageRequest) apply(qb *queryBuilder) {
+	if pr.field != "" {
+		qb.where("old_values -> ? IS DISTINCT FROM new_values -> ?", pr.field, pr.field)
+	}
+	if pr.cursor != nil {
+		qb.where("(changed_at, id) < (?::timestamptz, ?)", pr.cursor.Value, pr.cursor.ID)
+	}
+	qb.order("changed_at DESC", "id DESC")
+	qb.setLimit(pr.limit + 1)
+}
+
+// paginate trims the extra row requested by apply and builds the page with the cursor of the next one.
+func (pr historyPageRequest) paginate(changes []ProductChange) historyPage {
+	page := historyPage{Changes: changes}
+	if len(changes) > pr.limit {
+		page.Changes = changes[:pr.limit]
+		last := page.Changes[pr.limit-1]
+		page.NextCursor = productCursor{Order: "history", Value: last.ChangedAt.UTC().Format(time.RFC3339Nano), ID: last.ID}.encode()
+	}
+	return page
+}
+
+// historyPage is the JSON response of the history of a product.
+type historyPage struct {
+	Changes    []ProductChange `json:"changes"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
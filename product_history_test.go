@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// expectActor sets the expectation of beginning a transaction that records the changes to products as made by actor.
+func expectActor(mock sqlmock.Sqlmock, actor string) {
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("SELECT set_config('app.actor', $1, true)")).
+		WithArgs(actor).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func TestChangedColumns(t *testing.T) {
+	tests := []struct {
+		name      string
+		oldValues string
+		newValues string
+		expected  []string
+	}{
+		{name: "Price change", oldValues: `{"id": 1, "name": "Mug", "price": 35000.00}`, newValues: `{"id": 1, "name": "Mug", "price": 38000.00}`, expected: []string{"price"}},
+		{name: "Same values", oldValues: `{"id": 1, "price": 35000.00}`, newValues: `{"id": 1, "price": 35000.00}`, expected: []string{}},
+		{name: "Create", newValues: `{"id": 1, "name": "Mug"}`, expected: []string{"id", "name"}},
+		{name: "Delete", oldValues: `{"id": 1, "name": "Mug"}`, expected: []string{"id", "name"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var oldValues, newValues []byte
+			if tt.oldValues != "" {
+				oldValues = []byte(tt.oldValues)
+			}
+			if tt.newValues != "" {
+				newValues = []byte(tt.newValues)
+			}
+			changed, err := changedColumns(oldValues, newValues)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(changed, tt.expected) {
+				t.Errorf("unexpected changed columns: got %v want %v", changed, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseHistoryPageRequest(t *testing.T) {
+	changedAt := time.Date(2023, 7, 18, 10, 0, 0, 0, time.UTC)
+	cursor := productCursor{Order: "history", Value: changedAt.Format(time.RFC3339Nano), ID: 9}.encode()
+	pr, err := parseHistoryPageRequest(url.Values{"limit": {"5"}, "cursor": {cursor}, "field": {"price"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	qb := newQueryBuilder("SELECT id FROM product_history")
+	qb.where("product_id = ?", 1)
+	pr.apply(qb)
+	query, args := qb.build()
+
+	expectedQuery := "SELECT id FROM product_history WHERE product_id = $1 AND old_values -> $2 IS DISTINCT FROM new_values -> $3 " +
+		"AND (changed_at, id) < ($4::timestamptz, $5) ORDER BY changed_at DESC, id DESC LIMIT $6"
+	if query != expectedQuery {
+		t.Errorf("unexpected query: got %q want %q", query, expectedQuery)
+	}
+	expectedArgs := []interface{}{1, "price", "price", changedAt.Format(time.RFC3339Nano), 9, 6}
+	if !reflect.DeepEqual(args, expectedArgs) {
+		t.Errorf("unexpected args: got %v want %v", args, expectedArgs)
+	}
+}
+
+func TestParseHistoryPageRequest_Errors(t *testing.T) {
+	reviewsCursor := productCursor{Order: "reviews", Value: "2023-07-18T10:00:00Z", ID: 9}.encode()
+	tests := []struct {
+		query       url.Values
+		expectedErr string
+	}{
+		{query: url.Values{"limit": {"0"}}, expectedErr: "limit must be a number between 1 and 100"},
+		{query: url.Values{"limit": {"101"}}, expectedErr: "limit must be a number between 1 and 100"},
+		{query: url.Values{"cursor": {reviewsCursor}}, expectedErr: "invalid cursor"},
+		{query: url.Values{"field": {"search_vector"}}, expectedErr: `unknown field "search_vector"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query.Encode(), func(t *testing.T) {
+			_, err := parseHistoryPageRequest(tt.query)
+			if err == nil || err.Error() != tt.expectedErr {
+				t.Errorf("unexpected error, expected %q but got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestHistoryPageRequestPaginate(t *testing.T) {
+	changedAt := time.Date(2023, 7, 18, 10, 0, 0, 0, time.UTC)
+	changes := []ProductChange{{ID: 3, ChangedAt: changedAt.Add(time.Hour)}, {ID: 2, ChangedAt: changedAt}, {ID: 1, ChangedAt: changedAt}}
+	page := historyPageRequest{limit: 2}.paginate(changes)
+
+	if len(page.Changes) != 2 {
+		t.Fatalf("unexpected changes: %v", page.Changes)
+	}
+	cursor, err := decodeProductCursor(page.NextCursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := productCursor{Order: "history", Value: "2023-07-18T10:00:00Z", ID: 2}
+	if cursor != expected {
+		t.Errorf("unexpected cursor: got %+v want %+v", cursor, expected)
+	}
+}
+
+// getProductChangeRows returns a mock sqlmock.Rows object populated with the given changes.
+func getProductChangeRows(changes ...ProductChange) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"id", "product_id", "operation", "old_values", "new_values", "actor", "changed_at"})
+	for _, c := range changes {
+		var oldValues, newValues interface{}
+		if c.OldValues != nil {
+			oldValues = []byte(c.OldValues)
+		}
+		if c.NewValues != nil {
+			newValues = []byte(c.NewValues)
+		}
+		rows.AddRow(c.ID, c.ProductID, c.Operation, oldValues, newValues, c.Actor, c.ChangedAt)
+	}
+	return rows
+}
+
+func TestScanProductChange(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	expected := ProductChange{ID: 4, ProductID: 1, Operation: historyUpdate, OldValues: json.RawMessage(`{"id": 1, "price": 35000.00}`),
+		NewValues: json.RawMessage(`{"id": 1, "price": 38000.00}`), Changed: []string{"price"}, Actor: "ana", ChangedAt: time.Date(2023, 7, 18, 10, 0, 0, 0, time.UTC)}
+	mock.ExpectQuery("SELECT").WillReturnRows(getProductChangeRows(expected))
+
+	c := ProductChange{}
+	if err := scanProductChange(db.QueryRow("SELECT"), &c); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c, expected) {
+		t.Errorf("unexpected change:\n got %+v\nwant %+v", c, expected)
+	}
+	checkMockExpectations(t, mock)
+}
+
+func TestRequestActor(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPatch, "/products/1", nil)
+	if actor := requestActor(req); actor != defaultActor {
+		t.Errorf("unexpected actor: got %q want %q", actor, defaultActor)
+	}
+	req.Header.Set(actorHeader, " ana@example.com ")
+	if actor := requestActor(req); actor != "ana@example.com" {
+		t.Errorf("unexpected actor: got %q want %q", actor, "ana@example.com")
+	}
+}
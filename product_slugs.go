@@ -0,0 +1,90 @@
+package main
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// maxSlugLength is the longest slug that is generated or accepted.
+const maxSlugLength = 100
+
+// maxSlugAttempts is the number of times a product is inserted with a generated slug, when other products take
+// the slugs first.
+const maxSlugAttempts = 3
+
+// slugTransliterations replaces the accented letters of Spanish, and a few other common ones, with plain ASCII.
+var slugTransliterations = strings.NewReplacer(
+	"á", "a", "é", "e", "í", "i", "ó", "o", "ú", "u", "ü", "u", "ñ", "n",
+	"à", "a", "è", "e", "ì", "i", "ò", "o", "ù", "u",
+	"â", "a", "ê", "e", "î", "i", "ô", "o", "û", "u",
+	"ä", "a", "ë", "e", "ï", "i", "ö", "o", "ç", "c",
+)
+
+// slugify returns the slug of a product name: lowercase ASCII letters and digits separated by single hyphens,
+// with the accents removed. Names without letters or digits get the slug "product".
+func slugify(name string) string {
+	s := slugTransliterations.Replace(strings.ToLower(name))
+
+	var b strings.Builder
+	hyphen := false
+	for _, r := range s {
+		if r < unicode.MaxASCII && (unicode.IsLower(r) || unicode.IsDigit(r)) {
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			hyphen = false
+		} else {
+			hyphen = true
+		}
+	}
+
+	slug := b.String()
+	if len(slug) > maxSlugLength {
+		// Cut at a word boundary when there is one
+		slug = slug[:maxSlugLength]
+		if i := strings.LastIndexByte(slug, '-'); i > 0 {
+			slug = slug[:i]
+		}
+	}
+	if slug == "" {
+		return "product"
+	}
+	return slug
+}
+
+// uniqueSlug returns base, or base followed by the first free number from 2, so it is not used by any product,
+// now or in the slug history.
+func uniqueSlug(db *sql.DB, base string) (string, error) {
+	rows, err := db.Query("SELECT slug FROM products WHERE slug = $1 OR slug LIKE $2 "+
+		"UNION SELECT slug FROM product_slug_history WHERE slug = $1 OR slug LIKE $2", base, escapeLike(base)+"-%")
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	taken := map[string]bool{}
+	for rows.Next() {
+		var slug string
+		if err := rows.Scan(&slug); err != nil {
+			return "", err
+		}
+		taken[slug] = true
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	slug := base
+	for n := 2; taken[slug]; n++ {
+		suffix := "-" + strconv.Itoa(n)
+		if len(base)+len(suffix) > maxSlugLength {
+			slug = strings.TrimRight(base[:maxSlugLength-len(suffix)], "-") + suffix
+		} else {
+			slug = base + suffix
+		}
+	}
+	return slug, nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// expectSlugsTaken sets up the mock to expect the query of uniqueSlug for base, which finds the taken slugs.
+func expectSlugsTaken(mock sqlmock.Sqlmock, base string, taken ...string) {
+	rows := sqlmock.NewRows([]string{"slug"})
+	for _, slug := range taken {
+		rows.AddRow(slug)
+	}
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT slug FROM products WHERE slug = $1 OR slug LIKE $2 UNION SELECT slug FROM product_slug_history")).
+		WithArgs(base, escapeLike(base)+"-%").
+		WillReturnRows(rows)
+}
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{name: "Taza azul", expected: "taza-azul"},
+		{name: "Plato de cerámica pequeño", expected: "plato-de-ceramica-pequeno"},
+		{name: "  ¡Jarrón ÚNICO! (edición 2023)  ", expected: "jarron-unico-edicion-2023"},
+		{name: "Pingüino_de-barro", expected: "pinguino-de-barro"},
+		{name: "陶器", expected: "product"},
+		{name: strings.Repeat("taza ", 30), expected: strings.TrimSuffix(strings.Repeat("taza-", 20), "-")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := slugify(tt.name); got != tt.expected {
+				t.Errorf("unexpected slug: got %q want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestUniqueSlug(t *testing.T) {
+	tests := []struct {
+		name     string
+		taken    []string
+		expected string
+	}{
+		{name: "Free", taken: nil, expected: "taza"},
+		{name: "Taken", taken: []string{"taza", "taza-azul"}, expected: "taza-2"},
+		{name: "Taken by old slugs", taken: []string{"taza", "taza-2", "taza-3"}, expected: "taza-4"},
+		{name: "Free number", taken: []string{"taza", "taza-3"}, expected: "taza-2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := getMockDB(t)
+			defer db.Close()
+			expectSlugsTaken(mock, "taza", tt.taken...)
+
+			slug, err := uniqueSlug(db, "taza")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if slug != tt.expected {
+				t.Errorf("unexpected slug: got %q want %q", slug, tt.expected)
+			}
+			checkMockExpectations(t, mock)
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Product statuses. Only published products are shown to shoppers, and only inside their publishing window:
+// a published product with a publish_at in the future is scheduled, and one with an unpublish_at in the past
+// is hidden again. Drafts and archived products are only visible to admins.
+const (
+	statusDraft     = "draft"
+	statusPublished = "published"
+	statusArchived  = "archived"
+	// statusScheduled is not stored: it filters the published products whose publish_at is in the future.
+	statusScheduled = "scheduled"
+)
+
+// liveCondition matches the products that are visible to shoppers right now.
+const liveCondition = "status = 'published' AND (publish_at IS NULL OR publish_at <= NOW()) AND (unpublish_at IS NULL OR unpublish_at > NOW())"
+
+// statusConditions maps every value accepted by the status filter to its SQL condition.
+var statusConditions = map[string]string{
+	statusDraft:     "status = 'draft'",
+	statusPublished: "status = 'published'",
+	statusArchived:  "status = 'archived'",
+	statusScheduled: "status = 'published' AND publish_at > NOW()",
+}
+
+// liveTTL shortens ttl so a cached live product expires when it is unpublished.
+func liveTTL(p Product, ttl time.Duration) time.Duration {
+	if p.UnpublishAt == nil {
+		return ttl
+	}
+	left := time.Until(*p.UnpublishAt)
+	if left < time.Second {
+		// A TTL of zero would keep the key forever
+		return time.Second
+	} else if left < ttl {
+		return left
+	}
+	return ttl
+}
+
+// optionalTime is a nullable timestamp of a patch body. Set tells a field sent as null, to clear it,
+// apart from an absent field.
+type optionalTime struct {
+	Set  bool
+	Time *time.Time
+}
+
+// UnmarshalJSON is only called for fields present in the body, so it marks the field as set.
+// Implements the encoding/json Unmarshaler interface.
+func (o *optionalTime) UnmarshalJSON(b []byte) error {
+	o.Set = true
+	return json.Unmarshal(b, &o.Time)
+}
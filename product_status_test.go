@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-redis/redismock/v8"
+	"github.com/gorilla/mux"
+)
+
+// serveAdminRequest sends a GET request with a valid admin token to a ProductsHandler method
+// and returns the recorded response.
+func serveAdminRequest(t *testing.T, ph ProductsHandler, handler http.HandlerFunc, target string, vars map[string]string) *httptest.ResponseRecorder {
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		t.Fatalf("Failed to create HTTP request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+ph.admin.token)
+	if vars != nil {
+		req = mux.SetURLVars(req, vars)
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestGetProducts_Admin(t *testing.T) {
+	client, redisMock := redismock.NewClientMock()
+	db, mock := getMockDB(t)
+	defer db.Close()
+	ph := ProductsHandler{db: db, cache: productCache{client: client}, admin: adminAuth{token: "secret"}}
+
+	// Admins see the products that are not live, aren't served from the cache and can filter by status
+	expected := getExpectedProducts()[1:]
+	expected[0].Status = statusDraft
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT " + productColumns + " FROM products WHERE status = 'draft' ORDER BY date_added DESC, id DESC LIMIT $1")).
+		WithArgs(defaultPageLimit + 1).
+		WillReturnRows(getMockRows(expected))
+	expectVariants(mock, expected)
+
+	rr := serveAdminRequest(t, ph, ph.getProducts, "/products?status=draft", nil)
+
+	checkResponseCode(t, rr.Code, http.StatusOK)
+	checkResponseBody(t, rr.Body.String(), "", productPage{Products: expected})
+	if got := rr.Header().Get("Cache-Control"); got != privateCacheControl {
+		t.Errorf("unexpected cache control: %v", got)
+	}
+	checkMockExpectations(t, mock)
+	if err := redisMock.ExpectationsWereMet(); err != nil {
+		t.Errorf("redis expectations were not met: %s", err.Error())
+	}
+}
+
+func TestGetProduct_NotLive(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+	ph := ProductsHandler{db: db, admin: adminAuth{token: "secret"}}
+	vars := map[string]string{"id": "2"}
+
+	// Shoppers get a 404 for a product that is not live
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT " + productColumns + ", updated_at FROM products WHERE id = $1 AND " + liveCondition)).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	rr := serveProductRequest(t, ph.getProduct, http.MethodGet, "/products/2", "", vars)
+	checkResponseCode(t, rr.Code, http.StatusNotFound)
+
+	// Admins get it
+	p := getExpectedProducts()[1]
+	p.Status = statusArchived
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT " + productColumns + ", updated_at FROM products WHERE id = $1")).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "slug", "price", "description", "categories", "images", "referenced_name", "date_added", "status", "publish_at", "unpublish_at", "average_rating", "review_count", "stock_quantity", "updated_at"}).
+			AddRow(p.ID, p.Name, p.Slug, p.Price.String(), p.Description, sliceToPostgreSQLArray(p.Categories), sliceToPostgreSQLArray(p.Images), p.ReferencedName, p.DateAdded,
+				p.Status, nil, nil, p.AverageRating, p.ReviewCount, p.AvailableQuantity, p.DateAdded))
+	expectVariants(mock, []Product{p})
+
+	rr = serveAdminRequest(t, ph, ph.getProduct, "/products/2", vars)
+	checkResponseCode(t, rr.Code, http.StatusOK)
+	expectedBody, _ := marshalLine(p)
+	checkResponseBody(t, rr.Body.String(), expectedBody, nil)
+	checkMockExpectations(t, mock)
+}
+
+func TestLiveTTL(t *testing.T) {
+	soon := time.Now().Add(time.Minute)
+	past := time.Now().Add(-time.Minute)
+	later := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name        string
+		unpublishAt *time.Time
+		expectedMax time.Duration
+		expectedMin time.Duration
+	}{
+		{name: "No unpublish date", unpublishAt: nil, expectedMin: productCacheTTL, expectedMax: productCacheTTL},
+		{name: "Unpublished soon", unpublishAt: &soon, expectedMin: 55 * time.Second, expectedMax: time.Minute},
+		{name: "Unpublished later", unpublishAt: &later, expectedMin: productCacheTTL, expectedMax: productCacheTTL},
+		{name: "Already unpublished", unpublishAt: &past, expectedMin: time.Second, expectedMax: time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ttl := liveTTL(Product{UnpublishAt: tt.unpublishAt}, productCacheTTL)
+			if ttl < tt.expectedMin || ttl > tt.expectedMax {
+				t.Errorf("unexpected ttl %v, expected between %v and %v", ttl, tt.expectedMin, tt.expectedMax)
+			}
+		})
+	}
+}
+
+func TestOptionalTime(t *testing.T) {
+	var patch productPatch
+	if err := json.Unmarshal([]byte(`{"publish_at":null,"unpublish_at":"2023-07-01T00:00:00Z"}`), &patch); err != nil {
+		t.Fatal(err)
+	}
+	if !patch.PublishAt.Set || patch.PublishAt.Time != nil {
+		t.Errorf("expected a cleared publish_at, got %+v", patch.PublishAt)
+	}
+	if !patch.UnpublishAt.Set || patch.UnpublishAt.Time == nil || !patch.UnpublishAt.Time.Equal(time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected unpublish_at: %+v", patch.UnpublishAt)
+	}
+
+	patch = productPatch{}
+	if err := json.Unmarshal([]byte(`{"name":"Mug"}`), &patch); err != nil {
+		t.Fatal(err)
+	}
+	if patch.PublishAt.Set {
+		t.Error("expected an absent publish_at not to be set")
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// putCategoryTranslation handles the HTTP request for translating the name of a category.
+//
+// It expects the slug of the category and a locale other than the default one as URL parameters, and a body with
+// the translated name. The translation is created or replaced. It returns the stored translation as a JSON response.
+// If the locale or the body are not valid, it returns an HTTP 400 Bad Request error.
+// If the category is not found, it returns an HTTP 404 Not Found error.
+// If there is an error while storing the translation, it returns an HTTP 500 Internal Server Error.
+func (ch CategoriesHandler) putCategoryTranslation(w http.ResponseWriter, r *http.Request) {
+	// Extract category slug and locale from URL parameters
+	vars := mux.Vars(r)
+	t := categoryTranslation{Slug: vars["slug"], Locale: strings.ToLower(vars["locale"])}
+
+	var in categoryTranslationInput
+	err := validateTranslationLocale(t.Locale)
+	if err == nil {
+		err = decodeJSONBody(r, &in)
+	}
+	if err == nil {
+		err = in.validate()
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Store the translation only if the category exists
+	sqlQuery := "INSERT INTO category_translations (category_id, locale, name) SELECT id, $2, $3 FROM categories WHERE slug = $1 " +
+		"ON CONFLICT (category_id, locale) DO UPDATE SET name = EXCLUDED.name RETURNING name"
+	err = ch.db.QueryRow(sqlQuery, t.Slug, t.Locale, in.Name).Scan(&t.Name)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Category not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Println(err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Drop the cached listings, which may embed the category names
+	ch.cache.invalidate(r.Context())
+
+	// Encode and send response
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(t)
+	if err != nil {
+		log.Println(err)
+	}
+}
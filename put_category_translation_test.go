@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-redis/redismock/v8"
+)
+
+func TestPutCategoryTranslation(t *testing.T) {
+	tests := []struct {
+		name           string
+		locale         string
+		body           string
+		setup          func(mock sqlmock.Sqlmock)
+		expectedStatus int
+		expectedBody   string
+		// invalidated tells whether the cached listings are dropped
+		invalidated bool
+	}{
+		{name: "Translated", locale: "en", body: `{"name":"Mugs"}`, setup: func(mock sqlmock.Sqlmock) {
+			mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO category_translations (category_id, locale, name) SELECT id, $2, $3 FROM categories WHERE slug = $1 "+
+				"ON CONFLICT (category_id, locale) DO UPDATE SET name = EXCLUDED.name RETURNING name")).
+				WithArgs("tazas", "en", "Mugs").
+				WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("Mugs"))
+		}, expectedStatus: http.StatusOK, expectedBody: `{"slug":"tazas","locale":"en","name":"Mugs"}` + "\n", invalidated: true},
+		{name: "Unsupported locale", locale: "fr", body: `{"name":"Tasses"}`, setup: func(mock sqlmock.Sqlmock) {},
+			expectedStatus: http.StatusBadRequest, expectedBody: "locale must be one of en, es\n"},
+		{name: "Missing name", locale: "en", body: `{}`, setup: func(mock sqlmock.Sqlmock) {},
+			expectedStatus: http.StatusBadRequest, expectedBody: "name is required\n"},
+		{name: "Not found", locale: "en", body: `{"name":"Mugs"}`, setup: func(mock sqlmock.Sqlmock) {
+			mock.ExpectQuery("INSERT INTO category_translations").WillReturnRows(sqlmock.NewRows([]string{"name"}))
+		}, expectedStatus: http.StatusNotFound, expectedBody: "Category not found\n"},
+		{name: "Database error", locale: "en", body: `{"name":"Mugs"}`, setup: func(mock sqlmock.Sqlmock) {
+			mock.ExpectQuery("INSERT INTO category_translations").WillReturnError(errors.New("some error"))
+		}, expectedStatus: http.StatusInternalServerError, expectedBody: "Internal server error\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, redisMock := redismock.NewClientMock()
+			db, mock := getMockDB(t)
+			defer db.Close()
+			tt.setup(mock)
+			if tt.invalidated {
+				redisMock.ExpectIncr(productListGenerationKey).SetVal(1)
+			}
+
+			ch := CategoriesHandler{db: db, cache: productCache{client: client}}
+			rr := serveProductRequest(t, ch.putCategoryTranslation, http.MethodPut, "/categories/tazas/translations/"+tt.locale, tt.body,
+				map[string]string{"slug": "tazas", "locale": tt.locale})
+
+			checkResponseCode(t, rr.Code, tt.expectedStatus)
+			checkResponseBody(t, rr.Body.String(), tt.expectedBody, nil)
+			checkMockExpectations(t, mock)
+			if err := redisMock.ExpectationsWereMet(); err != nil {
+				t.Errorf("redis expectations were not met: %s", err.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// putExchangeRate handles the HTTP request for setting the exchange rate of a currency.
+//
+// It expects the currency code as a URL parameter and a body with the rate, the price of one unit of the currency
+// in the store currency, and optionally the rounding_increment and rounding_mode of the converted prices, which
+// default to the minor unit of the currency and half_up. The rate is created or replaced, and the cached product
+// listings are dropped so they are converted with the new rate. It returns the stored rate as a JSON response.
+// If the currency or the body are not valid, it returns an HTTP 400 Bad Request error.
+// If there is an error while storing the rate, it returns an HTTP 500 Internal Server Error.
+func (eh ExchangeRatesHandler) putExchangeRate(w http.ResponseWriter, r *http.Request) {
+	currency := strings.ToUpper(mux.Vars(r)["currency"])
+
+	var in exchangeRateInput
+	err := decodeJSONBody(r, &in)
+	if err == nil {
+		in.normalize(currency)
+		err = in.validate(currency)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sqlQuery := "INSERT INTO exchange_rates (currency, rate, rounding_increment, rounding_mode, updated_at) VALUES ($1, $2, $3, $4, NOW()) " +
+		"ON CONFLICT (currency) DO UPDATE SET rate = EXCLUDED.rate, rounding_increment = EXCLUDED.rounding_increment, " +
+		"rounding_mode = EXCLUDED.rounding_mode, updated_at = EXCLUDED.updated_at RETURNING " + exchangeRateColumns
+	er := ExchangeRate{}
+	err = scanExchangeRate(eh.db.QueryRow(sqlQuery, currency, in.Rate.String(), in.RoundingIncrement.String(), in.RoundingMode), &er)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Drop the cached listings, which may have prices converted with the old rate
+	eh.cache.invalidate(r.Context())
+
+	// Encode and send response
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(er)
+	if err != nil {
+		log.Println(err)
+	}
+}
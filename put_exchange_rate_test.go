@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redismock/v8"
+)
+
+func TestPutExchangeRate_Success(t *testing.T) {
+	client, redisMock := redismock.NewClientMock()
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	expected := ExchangeRate{Currency: "USD", Rate: "4150.25", RoundingIncrement: "0.01", RoundingMode: roundHalfUp, UpdatedAt: time.Date(2023, 7, 4, 16, 0, 0, 0, time.UTC)}
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO exchange_rates (currency, rate, rounding_increment, rounding_mode, updated_at) VALUES ($1, $2, $3, $4, NOW()) "+
+		"ON CONFLICT (currency) DO UPDATE SET rate = EXCLUDED.rate, rounding_increment = EXCLUDED.rounding_increment, "+
+		"rounding_mode = EXCLUDED.rounding_mode, updated_at = EXCLUDED.updated_at RETURNING "+exchangeRateColumns)).
+		WithArgs("USD", "4150.25", "0.01", roundHalfUp).
+		WillReturnRows(getExchangeRateRows(expected))
+	// The cached listings are dropped
+	redisMock.ExpectIncr(productListGenerationKey).SetVal(1)
+
+	eh := ExchangeRatesHandler{db: db, cache: productCache{client: client}}
+	rr := serveProductRequest(t, eh.putExchangeRate, http.MethodPut, "/exchange_rates/usd", `{"rate":4150.25}`, map[string]string{"currency": "usd"})
+
+	checkResponseCode(t, rr.Code, http.StatusOK)
+	expectedBody, _ := marshalLine(expected)
+	checkResponseBody(t, rr.Body.String(), expectedBody, nil)
+	checkMockExpectations(t, mock)
+	if err := redisMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPutExchangeRate_Errors(t *testing.T) {
+	tests := []struct {
+		name           string
+		currency       string
+		body           string
+		dbErr          error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{name: "Malformed JSON", currency: "USD", body: `{"rate":`, expectedStatus: http.StatusBadRequest, expectedBody: "invalid request body: unexpected EOF\n"},
+		{name: "Store currency", currency: "COP", body: `{"rate":1}`, expectedStatus: http.StatusBadRequest, expectedBody: "the store currency has no exchange rate\n"},
+		{name: "Invalid rounding mode", currency: "USD", body: `{"rate":"4000","rounding_mode":"even"}`, expectedStatus: http.StatusBadRequest, expectedBody: "rounding_mode must be half_up, up or down\n"},
+		{name: "Database error", currency: "EUR", body: `{"rate":"4500","rounding_increment":"0.05"}`, dbErr: errors.New("some error"),
+			expectedStatus: http.StatusInternalServerError, expectedBody: "Internal server error\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := getMockDB(t)
+			defer db.Close()
+			if tt.dbErr != nil {
+				mock.ExpectQuery("INSERT INTO exchange_rates").WithArgs(tt.currency, "4500", "0.05", roundHalfUp).WillReturnError(tt.dbErr)
+			}
+
+			eh := ExchangeRatesHandler{db: db}
+			rr := serveProductRequest(t, eh.putExchangeRate, http.MethodPut, "/exchange_rates/"+tt.currency, tt.body, map[string]string{"currency": tt.currency})
+
+			checkResponseCode(t, rr.Code, tt.expectedStatus)
+			checkResponseBody(t, rr.Body.String(), tt.expectedBody, nil)
+			checkMockExpectations(t, mock)
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+// updateProduct handles the HTTP request for replacing every editable field of a product.
+//
+// It expects the ID of the product as a URL parameter and a full product as the request body,
+// and returns the stored product as a JSON response. The date added is kept unchanged, as is the slug unless
+// the body has one, and a missing status is set to published. Old slugs keep redirecting to the product.
+// If the ID or the body are not valid, or the body has categories that don't exist, it returns an HTTP 400 Bad Request error.
+// If the product does not exist, it returns an HTTP 404 Not Found error.
+// If the slug is already used by another product, it returns an HTTP 409 Conflict error.
+// If there is an error while updating the product, it returns an HTTP 500 Internal Server Error.
+func (ph ProductsHandler) updateProduct(w http.ResponseWriter, r *http.Request) {
+	// Extract product ID from URL parameter
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+
+	var in productInput
+	err = decodeJSONBody(r, &in)
+	if err == nil {
+		err = in.validate()
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	in.normalize()
+
+	// Categories must exist in the categories table
+	err = checkCategoriesExist(ph.db, in.Categories)
+	var vErr validationError
+	if errors.As(err, &vErr) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		log.Println(err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Replace the product and read it back as stored
+	sqlQuery := "UPDATE products SET name = $1, price = $2, description = $3, categories = $4, images = $5, referenced_name = $6, " +
+		"status = $7, publish_at = $8, unpublish_at = $9, slug = COALESCE($10, slug) WHERE id = $11 RETURNING " + productColumns
+	slug := sql.NullString{String: in.Slug, Valid: in.Slug != ""}
+	p := Product{}
+	err = runAsActor(r.Context(), ph.db, requestActor(r), func(tx *sql.Tx) error {
+		row := tx.QueryRow(sqlQuery, in.Name, in.Price, in.Description, textArray(in.Categories), textArray(in.Images), in.ReferencedName,
+			in.Status, in.PublishAt, in.UnpublishAt, slug, id)
+		return scanProduct(row, &p)
+	})
+	var pqErr *pq.Error
+	if err == sql.ErrNoRows {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	} else if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		http.Error(w, "Slug already exists", http.StatusConflict)
+		return
+	} else if err != nil {
+		log.Println(err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Drop the cached responses that include the product
+	ph.cache.invalidate(r.Context(), p.ID)
+
+	// Encode and send response
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(p)
+	if err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestUpdateProduct_Success(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	expected := getExpectedProducts()[:1]
+	publishAt := time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC)
+	expected[0].PublishAt = &publishAt
+	expectCategoriesExist(mock, "cat1")
+	expectActor(mock, "admin")
+	mock.ExpectQuery(regexp.QuoteMeta("UPDATE products SET name = $1, price = $2, description = $3, categories = $4, images = $5, referenced_name = $6, "+
+		"status = $7, publish_at = $8, unpublish_at = $9, slug = COALESCE($10, slug) WHERE id = $11 RETURNING "+productColumns)).
+		WithArgs("Product A", "10.00", "", textArray{"cat1"}, textArray{}, "", statusPublished, publishAt, nil, nil, 1).
+		WillReturnRows(getMockRows(expected))
+	mock.ExpectCommit()
+
+	ph := ProductsHandler{db: db}
+	body := `{"name":"Product A","price":10,"categories":["cat1"],"publish_at":"2023-07-01T00:00:00Z"}`
+	rr := serveProductRequest(t, ph.updateProduct, http.MethodPut, "/products/1", body, map[string]string{"id": "1"})
+
+	checkResponseCode(t, rr.Code, http.StatusOK)
+	expectedBody, _ := marshalLine(expected[0])
+	checkResponseBody(t, rr.Body.String(), expectedBody, nil)
+	checkMockExpectations(t, mock)
+}
+
+func TestUpdateProduct_NotFound(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	expectActor(mock, "admin")
+	mock.ExpectQuery("UPDATE products").WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	ph := ProductsHandler{db: db}
+	rr := serveProductRequest(t, ph.updateProduct, http.MethodPut, "/products/7", `{"name":"Mug","price":1}`, map[string]string{"id": "7"})
+
+	checkResponseCode(t, rr.Code, http.StatusNotFound)
+	checkResponseBody(t, rr.Body.String(), "Product not found\n", nil)
+	checkMockExpectations(t, mock)
+}
+
+func TestUpdateProduct_InvalidRequest(t *testing.T) {
+	ph := ProductsHandler{db: nil}
+
+	rr := serveProductRequest(t, ph.updateProduct, http.MethodPut, "/products/x", `{"name":"Mug","price":1}`, map[string]string{"id": "x"})
+	checkResponseCode(t, rr.Code, http.StatusBadRequest)
+	checkResponseBody(t, rr.Body.String(), "Invalid product ID\n", nil)
+
+	rr = serveProductRequest(t, ph.updateProduct, http.MethodPut, "/products/1", `{"name":"Mug","price":1.234}`, map[string]string{"id": "1"})
+	checkResponseCode(t, rr.Code, http.StatusBadRequest)
+	checkResponseBody(t, rr.Body.String(), "invalid request body: invalid amount \"1.234\": must be a number with at most 2 decimals\n", nil)
+}
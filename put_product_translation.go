@@ -0,0 +1,69 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// putProductTranslation handles the HTTP request for translating the name and description of a product.
+//
+// It expects the ID of the product and a locale other than the default one as URL parameters, and a body with the
+// translated name and, optionally, description. Without a description the product keeps showing the one in the
+// default locale. The translation is created or replaced. It returns the stored translation as a JSON response.
+// If the ID, the locale or the body are not valid, it returns an HTTP 400 Bad Request error.
+// If the product is not found, it returns an HTTP 404 Not Found error.
+// If there is an error while storing the translation, it returns an HTTP 500 Internal Server Error.
+func (ph ProductsHandler) putProductTranslation(w http.ResponseWriter, r *http.Request) {
+	// Extract product ID and locale from URL parameters
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+	locale := strings.ToLower(vars["locale"])
+
+	var in productTranslationInput
+	err = validateTranslationLocale(locale)
+	if err == nil {
+		err = decodeJSONBody(r, &in)
+	}
+	if err == nil {
+		err = in.validate()
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Store the translation only if the product exists
+	sqlQuery := "INSERT INTO product_translations (product_id, locale, name, description) SELECT id, $2, $3, $4 FROM products WHERE id = $1 " +
+		"ON CONFLICT (product_id, locale) DO UPDATE SET name = EXCLUDED.name, description = EXCLUDED.description " +
+		"RETURNING product_id, locale, name, description"
+	t := productTranslation{}
+	err = ph.db.QueryRow(sqlQuery, id, locale, in.Name, in.Description).Scan(&t.ProductID, &t.Locale, &t.Name, &t.Description)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Println(err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Drop the cached responses that include the product
+	ph.cache.invalidate(r.Context(), id)
+
+	// Encode and send response
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(t)
+	if err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-redis/redismock/v8"
+)
+
+func TestPutProductTranslation_Success(t *testing.T) {
+	client, redisMock := redismock.NewClientMock()
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	description := "A blue mug"
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO product_translations (product_id, locale, name, description) SELECT id, $2, $3, $4 FROM products WHERE id = $1 "+
+		"ON CONFLICT (product_id, locale) DO UPDATE SET name = EXCLUDED.name, description = EXCLUDED.description "+
+		"RETURNING product_id, locale, name, description")).
+		WithArgs(1, "en", "Blue mug", description).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "locale", "name", "description"}).AddRow(1, "en", "Blue mug", description))
+	// The cached responses of the product are dropped
+	redisMock.ExpectDel(productKey(1)).SetVal(1)
+	redisMock.ExpectIncr(productListGenerationKey).SetVal(1)
+
+	ph := ProductsHandler{db: db, cache: productCache{client: client}}
+	rr := serveProductRequest(t, ph.putProductTranslation, http.MethodPut, "/products/1/translations/EN", `{"name":"Blue mug","description":"A blue mug"}`,
+		map[string]string{"id": "1", "locale": "EN"})
+
+	checkResponseCode(t, rr.Code, http.StatusOK)
+	expectedBody, _ := marshalLine(productTranslation{ProductID: 1, Locale: "en", Name: "Blue mug", Description: &description})
+	checkResponseBody(t, rr.Body.String(), expectedBody, nil)
+	checkMockExpectations(t, mock)
+	if err := redisMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPutProductTranslation_Errors(t *testing.T) {
+	tests := []struct {
+		name           string
+		id             string
+		locale         string
+		body           string
+		setup          func(mock sqlmock.Sqlmock)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{name: "Invalid ID", id: "abc", locale: "en", body: `{"name":"Mug"}`, setup: func(mock sqlmock.Sqlmock) {},
+			expectedStatus: http.StatusBadRequest, expectedBody: "Invalid product ID\n"},
+		{name: "Default locale", id: "1", locale: "es", body: `{"name":"Taza"}`, setup: func(mock sqlmock.Sqlmock) {},
+			expectedStatus: http.StatusBadRequest, expectedBody: "es is the default locale, it is stored in the product or category itself\n"},
+		{name: "Missing name", id: "1", locale: "en", body: `{"description":"A mug"}`, setup: func(mock sqlmock.Sqlmock) {},
+			expectedStatus: http.StatusBadRequest, expectedBody: "name is required\n"},
+		{name: "Not found", id: "1", locale: "en", body: `{"name":"Mug"}`, setup: func(mock sqlmock.Sqlmock) {
+			mock.ExpectQuery("INSERT INTO product_translations").WithArgs(1, "en", "Mug", nil).
+				WillReturnRows(sqlmock.NewRows([]string{"product_id", "locale", "name", "description"}))
+		}, expectedStatus: http.StatusNotFound, expectedBody: "Product not found\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := getMockDB(t)
+			defer db.Close()
+			tt.setup(mock)
+
+			ph := ProductsHandler{db: db}
+			rr := serveProductRequest(t, ph.putProductTranslation, http.MethodPut, "/products/"+tt.id+"/translations/"+tt.locale, tt.body,
+				map[string]string{"id": tt.id, "locale": tt.locale})
+
+			checkResponseCode(t, rr.Code, tt.expectedStatus)
+			checkResponseBody(t, rr.Body.String(), tt.expectedBody, nil)
+			checkMockExpectations(t, mock)
+		})
+	}
+}
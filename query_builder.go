@@ -0,0 +1,97 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// queryBuilder assembles a SQL statement from a base SELECT and a set of optional clauses.
+//
+// Every value is sent to the database as a bound argument: conditions are written with `?` markers,
+// which are rewritten to PostgreSQL positional placeholders ($1, $2, ...) in the order they are added.
+// User input must never be concatenated into the strings passed to the builder.
+type queryBuilder struct {
+	base       string
+	conditions []string
+	groupBy    []string
+	orderBy    []string
+	limit      int
+	args       []interface{}
+}
+
+// newQueryBuilder returns a queryBuilder for the given base statement, e.g. "SELECT id FROM products".
+func newQueryBuilder(base string) *queryBuilder {
+	return &queryBuilder{base: base}
+}
+
+// bind registers arg as a bound argument and returns the placeholder that references it.
+func (qb *queryBuilder) bind(arg interface{}) string {
+	qb.args = append(qb.args, arg)
+	return "$" + strconv.Itoa(len(qb.args))
+}
+
+// where adds a condition that is joined to the others with AND.
+// Each `?` in cond is replaced by a placeholder bound to the next value in args.
+func (qb *queryBuilder) where(cond string, args ...interface{}) {
+	var sb strings.Builder
+	next := 0
+	for _, r := range cond {
+		if r == '?' && next < len(args) {
+			sb.WriteString(qb.bind(args[next]))
+			next++
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	qb.conditions = append(qb.conditions, sb.String())
+}
+
+// group appends expressions to the GROUP BY clause.
+func (qb *queryBuilder) group(exprs ...string) {
+	qb.groupBy = append(qb.groupBy, exprs...)
+}
+
+// order appends expressions to the ORDER BY clause.
+func (qb *queryBuilder) order(exprs ...string) {
+	qb.orderBy = append(qb.orderBy, exprs...)
+}
+
+// setLimit sets the LIMIT of the statement. A value lower than 1 means no limit.
+func (qb *queryBuilder) setLimit(limit int) {
+	qb.limit = limit
+}
+
+// build returns the final SQL statement and the arguments to execute it with.
+func (qb *queryBuilder) build() (string, []interface{}) {
+	var sb strings.Builder
+	sb.WriteString(qb.base)
+
+	if len(qb.conditions) > 0 {
+		sb.WriteString(" WHERE ")
+		sb.WriteString(strings.Join(qb.conditions, " AND "))
+	}
+
+	if len(qb.groupBy) > 0 {
+		sb.WriteString(" GROUP BY ")
+		sb.WriteString(strings.Join(qb.groupBy, ", "))
+	}
+
+	if len(qb.orderBy) > 0 {
+		sb.WriteString(" ORDER BY ")
+		sb.WriteString(strings.Join(qb.orderBy, ", "))
+	}
+
+	// Copy the arguments so build can be called more than once
+	args := append([]interface{}{}, qb.args...)
+	if qb.limit > 0 {
+		args = append(args, qb.limit)
+		sb.WriteString(" LIMIT $" + strconv.Itoa(len(args)))
+	}
+
+	return sb.String(), args
+}
+
+// escapeLike escapes the LIKE/ILIKE wildcard characters in s so it is matched literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
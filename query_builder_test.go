@@ -0,0 +1,80 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQueryBuilder(t *testing.T) {
+	tests := []struct {
+		name          string
+		setup         func(qb *queryBuilder)
+		expectedQuery string
+		expectedArgs  []interface{}
+	}{
+		{
+			name:          "No clauses",
+			setup:         func(qb *queryBuilder) {},
+			expectedQuery: "SELECT id FROM products",
+			expectedArgs:  []interface{}{},
+		},
+		{
+			name: "Conditions are joined with AND and numbered in order",
+			setup: func(qb *queryBuilder) {
+				qb.where("name ILIKE ?", "%a%")
+				qb.where("price BETWEEN ? AND ?", 1, 2)
+			},
+			expectedQuery: "SELECT id FROM products WHERE name ILIKE $1 AND price BETWEEN $2 AND $3",
+			expectedArgs:  []interface{}{"%a%", 1, 2},
+		},
+		{
+			name: "Order and limit",
+			setup: func(qb *queryBuilder) {
+				qb.where("id > ?", 10)
+				qb.order("price DESC", "id DESC")
+				qb.setLimit(5)
+			},
+			expectedQuery: "SELECT id FROM products WHERE id > $1 ORDER BY price DESC, id DESC LIMIT $2",
+			expectedArgs:  []interface{}{10, 5},
+		},
+		{
+			name: "Bound values are never inlined",
+			setup: func(qb *queryBuilder) {
+				qb.where("name = ?", "'; DROP TABLE products; --")
+			},
+			expectedQuery: "SELECT id FROM products WHERE name = $1",
+			expectedArgs:  []interface{}{"'; DROP TABLE products; --"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qb := newQueryBuilder("SELECT id FROM products")
+			tt.setup(qb)
+			query, args := qb.build()
+			if query != tt.expectedQuery {
+				t.Errorf("unexpected query: got %q want %q", query, tt.expectedQuery)
+			}
+			if !reflect.DeepEqual(args, tt.expectedArgs) {
+				t.Errorf("unexpected args: got %v want %v", args, tt.expectedArgs)
+			}
+		})
+	}
+}
+
+func TestQueryBuilder_BuildTwice(t *testing.T) {
+	qb := newQueryBuilder("SELECT id FROM products")
+	qb.where("id = ?", 1)
+	qb.setLimit(1)
+	first, firstArgs := qb.build()
+	second, secondArgs := qb.build()
+	if first != second || !reflect.DeepEqual(firstArgs, secondArgs) {
+		t.Errorf("build is not idempotent: %q %v != %q %v", first, firstArgs, second, secondArgs)
+	}
+}
+
+func TestEscapeLike(t *testing.T) {
+	if got := escapeLike(`50%_off\`); got != `50\%\_off\\` {
+		t.Errorf("unexpected escaped value: %q", got)
+	}
+}
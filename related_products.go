@@ -0,0 +1,211 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultRelatedLimit = 8
+	maxRelatedLimit     = 50
+	// maxRelatedCandidates is the number of products outside the series, the ones that share the most categories
+	// and are closest in price first, that are scored to pick the related ones.
+	maxRelatedCandidates = 200
+
+	// Weights of the related products score. A shared reference outweighs any number of shared categories,
+	// which outweigh a close price.
+	sameReferenceScore     = 10
+	referencedScore        = 8
+	sharedCategoryScore    = 2
+	maxPriceProximityScore = 1
+	// priceProximityRange is the relative price difference, from the price of the product, within which
+	// products are considered close in price.
+	priceProximityRange = 0.5
+)
+
+// relatedProduct is a product related to another one, with the score it got.
+type relatedProduct struct {
+	Product
+	Score float64 `json:"score"`
+}
+
+// relatedProductsPage is the JSON response of the related products of a product.
+type relatedProductsPage struct {
+	Products []relatedProduct `json:"products"`
+}
+
+// parseRelatedLimit reads the limit query parameter of the related products, defaulting to defaultRelatedLimit.
+// It returns a validationError if it is not a number between 1 and maxRelatedLimit.
+func parseRelatedLimit(query url.Values) (int, error) {
+	v := query.Get("limit")
+	if v == "" {
+		return defaultRelatedLimit, nil
+	}
+	limit, err := strconv.Atoi(v)
+	if err != nil || limit < 1 || limit > maxRelatedLimit {
+		return 0, validationError{fmt.Sprintf("limit must be a number between 1 and %d", maxRelatedLimit)}
+	}
+	return limit, nil
+}
+
+// sameReference reports whether two non-empty references name the same series or product.
+func sameReference(a, b string) bool {
+	a, b = strings.TrimSpace(a), strings.TrimSpace(b)
+	return a != "" && strings.EqualFold(a, b)
+}
+
+// relatedScore scores how related candidate is to p. Pieces of the same series (the same referenced name)
+// score the highest, then a piece and the product it references, by name, in either direction. Every shared
+// category adds to the score, and so does a price within priceProximityRange of the price of p, more the closer
+// it is. Unrelated products score zero.
+func relatedScore(p, candidate Product) float64 {
+	score := 0.0
+
+	if sameReference(p.ReferencedName, candidate.ReferencedName) {
+		score += sameReferenceScore
+	} else if sameReference(p.ReferencedName, candidate.Name) || sameReference(candidate.ReferencedName, p.Name) {
+		score += referencedScore
+	}
+
+	categories := make(map[string]bool, len(p.Categories))
+	for _, c := range p.Categories {
+		categories[c] = true
+	}
+	for _, c := range candidate.Categories {
+		if categories[c] {
+			score += sharedCategoryScore
+		}
+	}
+
+	if p.Price.Amount > 0 {
+		diff := float64(candidate.Price.Amount - p.Price.Amount)
+		if diff < 0 {
+			diff = -diff
+		}
+		if proximity := 1 - diff/(float64(p.Price.Amount)*priceProximityRange); proximity > 0 {
+			score += maxPriceProximityScore * proximity
+		}
+	}
+	return score
+}
+
+// rankRelated scores the candidates against p and returns the limit ones with the highest score, leaving out
+// the unrelated ones. Ties keep the order of the candidates.
+func rankRelated(p Product, candidates []Product, limit int) []relatedProduct {
+	related := []relatedProduct{}
+	for _, c := range candidates {
+		if c.ID == p.ID {
+			continue
+		}
+		if score := relatedScore(p, c); score > 0 {
+			related = append(related, relatedProduct{Product: c, Score: score})
+		}
+	}
+	sort.SliceStable(related, func(i, j int) bool {
+		return related[i].Score > related[j].Score
+	})
+	if len(related) > limit {
+		related = related[:limit]
+	}
+	return related
+}
+
+// relatedCandidates returns the live products that could be related to p. The ones that share its reference or
+// reference it are all returned first, since they score the highest. Then come the maxRelatedCandidates that
+// score the highest of the ones that share a category or are close in price, which are far more.
+func relatedCandidates(db *sql.DB, p Product) ([]Product, error) {
+	conds := []string{}
+	condArgs := []interface{}{}
+	if ref := strings.TrimSpace(p.ReferencedName); ref != "" {
+		conds = append(conds, "lower(referenced_name) = lower(?)", "lower(name) = lower(?)")
+		condArgs = append(condArgs, ref, ref)
+	}
+	conds = append(conds, "lower(referenced_name) = lower(?)")
+	condArgs = append(condArgs, strings.TrimSpace(p.Name))
+	candidates, err := queryRelatedCandidates(db, p, conds, condArgs, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	minPrice := Money{Amount: p.Price.Amount - int64(float64(p.Price.Amount)*priceProximityRange), Currency: p.Price.Currency}
+	maxPrice := Money{Amount: p.Price.Amount + int64(float64(p.Price.Amount)*priceProximityRange), Currency: p.Price.Currency}
+	conds = []string{}
+	condArgs = []interface{}{}
+	if len(p.Categories) > 0 {
+		conds = append(conds, "categories && ?")
+		condArgs = append(condArgs, textArray(p.Categories))
+	}
+	conds = append(conds, "price BETWEEN ? AND ?")
+	condArgs = append(condArgs, minPrice, maxPrice)
+	others, err := queryRelatedCandidates(db, p, conds, condArgs, maxRelatedCandidates)
+	if err != nil {
+		return nil, err
+	}
+
+	// A product of the series can also share a category or be close in price
+	seen := make(map[int]bool, len(candidates))
+	for _, c := range candidates {
+		seen[c.ID] = true
+	}
+	for _, c := range others {
+		if !seen[c.ID] {
+			candidates = append(candidates, c)
+		}
+	}
+	return candidates, nil
+}
+
+// relatedCandidateScore returns the SQL expression of what shared categories and a close price add to the
+// relatedScore of a product, binding the values of p to qb, or an empty string if p has neither categories nor a
+// price.
+func relatedCandidateScore(qb *queryBuilder, p Product) string {
+	terms := []string{}
+	if len(p.Categories) > 0 {
+		terms = append(terms, fmt.Sprintf("%d * (SELECT COUNT(*) FROM unnest(categories) AS category WHERE category = ANY(%s))",
+			sharedCategoryScore, qb.bind(textArray(p.Categories))))
+	}
+	if p.Price.Amount > 0 {
+		proximityRange := Money{Amount: int64(float64(p.Price.Amount) * priceProximityRange), Currency: p.Price.Currency}
+		terms = append(terms, fmt.Sprintf("%d * GREATEST(0, 1 - ABS(price - %s) / %s)",
+			maxPriceProximityScore, qb.bind(p.Price), qb.bind(proximityRange)))
+	}
+	return strings.Join(terms, " + ")
+}
+
+// queryRelatedCandidates returns the live products other than p that match any of the conditions, the newest first.
+// At most limit products are returned, the ones that share the most categories and are closest in price first (see
+// relatedCandidateScore), or all of them if limit is zero.
+func queryRelatedCandidates(db *sql.DB, p Product, conds []string, condArgs []interface{}, limit int) ([]Product, error) {
+	qb := newQueryBuilder("SELECT " + productColumns + " FROM products")
+	qb.where("id <> ?", p.ID)
+	qb.where("("+strings.Join(conds, " OR ")+")", condArgs...)
+	qb.where(liveCondition)
+	if limit > 0 {
+		if score := relatedCandidateScore(qb, p); score != "" {
+			qb.order(score + " DESC")
+		}
+	}
+	qb.order("date_added DESC", "id DESC")
+	qb.setLimit(limit)
+	sqlQuery, args := qb.build()
+
+	rows, err := db.Query(sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	candidates := []Product{}
+	for rows.Next() {
+		c := Product{}
+		if err := scanProduct(rows, &c); err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates, rows.Err()
+}
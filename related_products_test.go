@@ -0,0 +1,157 @@
+package main
+
+import (
+	"net/url"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// expectRelatedCandidates sets up the mock to expect the candidates queries of relatedCandidates for a product
+// with a referenced name, categories and a price of 100.00, and to return the given products of its series and others.
+func expectRelatedCandidates(mock sqlmock.Sqlmock, p Product, series, others []Product) {
+	query := "SELECT " + productColumns + " FROM products WHERE id <> $1 AND " +
+		"(lower(referenced_name) = lower($2) OR lower(name) = lower($3) OR lower(referenced_name) = lower($4)) AND " +
+		liveCondition + " ORDER BY date_added DESC, id DESC"
+	mock.ExpectQuery("^"+regexp.QuoteMeta(query)+"$").
+		WithArgs(p.ID, p.ReferencedName, p.ReferencedName, p.Name).
+		WillReturnRows(getMockRows(series))
+	// The others are ranked by the categories they share and how close their price is before they are cut
+	query = "SELECT " + productColumns + " FROM products WHERE id <> $1 AND (categories && $2 OR price BETWEEN $3 AND $4) AND " +
+		liveCondition + " ORDER BY 2 * (SELECT COUNT(*) FROM unnest(categories) AS category WHERE category = ANY($5)) + " +
+		"1 * GREATEST(0, 1 - ABS(price - $6) / $7) DESC, date_added DESC, id DESC LIMIT $8"
+	mock.ExpectQuery(regexp.QuoteMeta(query)).
+		WithArgs(p.ID, textArray(p.Categories), "50.00", "150.00", textArray(p.Categories), "100.00", "50.00", maxRelatedCandidates).
+		WillReturnRows(getMockRows(others))
+}
+
+func TestRelatedScore(t *testing.T) {
+	p := Product{ID: 1, Name: "Taza Mar", ReferencedName: "Serie Mar", Categories: []string{"mugs", "blue"}, Price: cop(10000)}
+
+	tests := []struct {
+		name      string
+		candidate Product
+		expected  float64
+	}{
+		{name: "Unrelated", candidate: Product{Name: "Plato", Categories: []string{"plates"}, Price: cop(100000)}, expected: 0},
+		{name: "Same series", candidate: Product{Name: "Plato Mar", ReferencedName: " serie mar", Price: cop(100000)}, expected: sameReferenceScore},
+		{name: "The referenced product", candidate: Product{Name: "Serie Mar", Price: cop(100000)}, expected: referencedScore},
+		{name: "References the product", candidate: Product{Name: "Plato", ReferencedName: "Taza Mar", Price: cop(100000)}, expected: referencedScore},
+		{name: "Shared categories", candidate: Product{Name: "Taza Sol", Categories: []string{"blue", "mugs", "large"}, Price: cop(100000)}, expected: 2 * sharedCategoryScore},
+		{name: "Same price", candidate: Product{Name: "Jarrón", Price: cop(10000)}, expected: maxPriceProximityScore},
+		{name: "Close price", candidate: Product{Name: "Jarrón", Price: cop(12500)}, expected: maxPriceProximityScore * 0.5},
+		{name: "Everything", candidate: Product{Name: "Plato Mar", ReferencedName: "Serie Mar", Categories: []string{"blue"}, Price: cop(10000)},
+			expected: sameReferenceScore + sharedCategoryScore + maxPriceProximityScore},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := relatedScore(p, tt.candidate); got != tt.expected {
+				t.Errorf("unexpected score: got %v want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRankRelated(t *testing.T) {
+	p := Product{ID: 1, Name: "Taza Mar", ReferencedName: "Serie Mar", Categories: []string{"mugs"}, Price: cop(10000)}
+	candidates := []Product{
+		{ID: 2, Name: "Plato", Categories: []string{"plates"}, Price: cop(100000)},
+		{ID: 3, Name: "Taza Sol", Categories: []string{"mugs"}, Price: cop(100000)},
+		{ID: 4, Name: "Plato Mar", ReferencedName: "Serie Mar", Price: cop(100000)},
+		{ID: 5, Name: "Taza Luna", Categories: []string{"mugs"}, Price: cop(100000)},
+		{ID: 1, Name: "Taza Mar", ReferencedName: "Serie Mar", Categories: []string{"mugs"}, Price: cop(10000)},
+	}
+
+	related := rankRelated(p, candidates, 2)
+	if len(related) != 2 || related[0].ID != 4 || related[1].ID != 3 {
+		t.Errorf("unexpected related products: %+v", related)
+	}
+
+	// Unrelated products and the product itself are left out, and ties keep the order of the candidates
+	related = rankRelated(p, candidates, 10)
+	ids := []int{}
+	for _, r := range related {
+		ids = append(ids, r.ID)
+	}
+	if len(ids) != 3 || ids[0] != 4 || ids[1] != 3 || ids[2] != 5 {
+		t.Errorf("unexpected related products: %v", ids)
+	}
+}
+
+func TestParseRelatedLimit(t *testing.T) {
+	tests := []struct {
+		query         string
+		expectedLimit int
+		expectedErr   string
+	}{
+		{query: "", expectedLimit: defaultRelatedLimit},
+		{query: "limit=3", expectedLimit: 3},
+		{query: "limit=0", expectedErr: "limit must be a number between 1 and 50"},
+		{query: "limit=51", expectedErr: "limit must be a number between 1 and 50"},
+		{query: "limit=many", expectedErr: "limit must be a number between 1 and 50"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			values, _ := url.ParseQuery(tt.query)
+			limit, err := parseRelatedLimit(values)
+			if tt.expectedErr != "" {
+				if err == nil || err.Error() != tt.expectedErr {
+					t.Errorf("unexpected error, expected %q but got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil || limit != tt.expectedLimit {
+				t.Errorf("unexpected limit %d (error %v), expected %d", limit, err, tt.expectedLimit)
+			}
+		})
+	}
+}
+
+func TestRelatedCandidates(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	// The products of the series come first, and are not repeated when they are also close in price
+	p := Product{ID: 3, Name: "Taza Mar", ReferencedName: "Product B", Categories: []string{"cat3"}, Price: cop(10000)}
+	products := getExpectedProducts()
+	expectRelatedCandidates(mock, p, products[1:], products)
+
+	candidates, err := relatedCandidates(db, p)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(candidates) != 2 || candidates[0].ID != 2 || candidates[1].ID != 1 {
+		t.Errorf("unexpected candidates: %+v", candidates)
+	}
+	checkMockExpectations(t, mock)
+}
+
+func TestRelatedCandidates_WithoutReference(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	// Without a referenced name or categories only the products that reference it or are close in price are candidates
+	p := Product{ID: 1, Name: "Taza", Categories: []string{}, Price: cop(10000)}
+	query := "SELECT " + productColumns + " FROM products WHERE id <> $1 AND (lower(referenced_name) = lower($2)) AND " +
+		liveCondition + " ORDER BY date_added DESC, id DESC"
+	mock.ExpectQuery("^"+regexp.QuoteMeta(query)+"$").
+		WithArgs(1, "Taza").
+		WillReturnRows(getMockRows(getExpectedProducts()[:1]))
+	query = "SELECT " + productColumns + " FROM products WHERE id <> $1 AND (price BETWEEN $2 AND $3) AND " + liveCondition +
+		" ORDER BY 1 * GREATEST(0, 1 - ABS(price - $4) / $5) DESC, date_added DESC, id DESC LIMIT $6"
+	mock.ExpectQuery(regexp.QuoteMeta(query)).
+		WithArgs(1, "50.00", "150.00", "100.00", "50.00", maxRelatedCandidates).
+		WillReturnRows(getMockRows(getExpectedProducts()[1:]))
+
+	candidates, err := relatedCandidates(db, p)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(candidates) != 2 {
+		t.Errorf("unexpected candidates: %+v", candidates)
+	}
+	checkMockExpectations(t, mock)
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	maxReviewTitleLength  = 150
+	maxReviewBodyLength   = 5000
+	maxReviewAuthorLength = 100
+	defaultReviewLimit    = 10
+	maxReviewLimit        = 50
+)
+
+// Review statuses. New reviews are pending until an admin approves or rejects them.
+const (
+	reviewPending  = "pending"
+	reviewApproved = "approved"
+	reviewRejected = "rejected"
+)
+
+// Review is a customer review of a product.
+type Review struct {
+	ID        int    `json:"id"`
+	ProductID int    `json:"product_id"`
+	Rating    int    `json:"rating"`
+	Title     string `json:"title"`
+	Body      string `json:"body"`
+	Author    string `json:"author"`
+	// VerifiedPurchase is set by admins for the reviews of customers that bought the product.
+	VerifiedPurchase bool      `json:"verified_purchase"`
+	Status           string    `json:"status"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// reviewColumns is the list of columns selected for every Review, in the order expected by scanReview.
+const reviewColumns = "id, product_id, rating, title, body, author, verified_purchase, status, created_at"
+
+// scanReview scans a row selected with reviewColumns into r.
+func scanReview(rs rowScanner, r *Review) error {
+	return rs.Scan(&r.ID, &r.ProductID, &r.Rating, &r.Title, &r.Body, &r.Author, &r.VerifiedPurchase, &r.Status, &r.CreatedAt)
+}
+
+// reviewInput is the request body accepted when reviewing a product.
+type reviewInput struct {
+	Rating int    `json:"rating"`
+	Title  string `json:"title"`
+	Body   string `json:"body"`
+	Author string `json:"author"`
+	// VerifiedPurchase can only be sent by admins.
+	VerifiedPurchase *bool `json:"verified_purchase"`
+}
+
+// validate checks every field of a review input.
+func (in reviewInput) validate() error {
+	if in.Rating < 1 || in.Rating > 5 {
+		return validationError{"rating must be a number between 1 and 5"}
+	}
+	if strings.TrimSpace(in.Author) == "" {
+		return validationError{"author is required"}
+	}
+	if len(in.Author) > maxReviewAuthorLength {
+		return validationError{fmt.Sprintf("author must be at most %d characters", maxReviewAuthorLength)}
+	}
+	if len(in.Title) > maxReviewTitleLength {
+		return validationError{fmt.Sprintf("title must be at most %d characters", maxReviewTitleLength)}
+	}
+	if len(in.Body) > maxReviewBodyLength {
+		return validationError{fmt.Sprintf("body must be at most %d characters", maxReviewBodyLength)}
+	}
+	return nil
+}
+
+// reviewModeration is the request body accepted when moderating a review.
+type reviewModeration struct {
+	Status           string `json:"status"`
+	VerifiedPurchase *bool  `json:"verified_purchase"`
+}
+
+// validate checks that the moderation sets a known status.
+func (m reviewModeration) validate() error {
+	switch m.Status {
+	case reviewPending, reviewApproved, reviewRejected:
+		return nil
+	}
+	return validationError{"status must be pending, approved or rejected"}
+}
+
+// reviewsPage is the JSON response of the reviews of a product.
+type reviewsPage struct {
+	Reviews    []Review `json:"reviews"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+}
+
+// reviewPageRequest holds the pagination and status query parameters of the reviews of a product.
+type reviewPageRequest struct {
+	limit  int
+	cursor *productCursor
+	// status is the status of the listed reviews, approved unless an admin asks for another one.
+	status string
+}
+
+// parseReviewPageRequest reads the limit, cursor and status query parameters of the reviews of a product.
+//
+// Reviews are listed from the newest, with keyset pagination on their creation date and ID like the products listing.
+// limit defaults to defaultReviewLimit and can't be bigger than maxReviewLimit. Only admins can list the reviews
+// of a status other than approved. It returns a validationError if any of the values is malformed.
+func parseReviewPageRequest(query url.Values, admin bool) (reviewPageRequest, error) {
+	pr := reviewPageRequest{limit: defaultReviewLimit, status: reviewApproved}
+
+	if l := query.Get("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit < 1 || limit > maxReviewLimit {
+			return pr, validationError{fmt.Sprintf("limit must be a number between 1 and %d", maxReviewLimit)}
+		}
+		pr.limit = limit
+	}
+
+	if c := query.Get("cursor"); c != "" {
+		cursor, err := decodeProductCursor(c)
+		if err != nil {
+			return pr, err
+		}
+		if cursor.Order != "reviews" {
+			return pr, validationError{"invalid cursor"}
+		}
+		pr.cursor = &cursor
+	}
+
+	if s := query.Get("status"); s != "" {
+		if err := (reviewModeration{Status: s}).validate(); err != nil {
+			return pr, err
+		}
+		if s != reviewApproved && !admin {
+			return pr, validationError{"only admins can list reviews that are not approved"}
+		}
+		pr.status = s
+	}
+
+	return pr, nil
+}
+
+// apply adds the conditions, the ORDER BY and the LIMIT of the page to the query builder.
+// One extra row is requested to know whether there is a next page.
+func (pr reviewPageRequest) apply(qb *queryBuilder) {
+	qb.where("status = ?", pr.status)
+	if pr.cursor != nil {
+		qb.where("(created_at, id) < (?::timestamptz, ?)", pr.cursor.Value, pr.cursor.ID)
+	}
+	qb.order("created_at DESC", "id DESC")
+	qb.setLimit(pr.limit + 1)
+}
+
+// paginate trims the extra row requested by apply and builds the page with the cursor of the next one.
+func (pr reviewPageRequest) paginate(reviews []Review) reviewsPage {
+	page := reviewsPage{Reviews: reviews}
+	if len(reviews) > pr.limit {
+		page.Reviews = reviews[:pr.limit]
+		last := page.Reviews[pr.limit-1]
+		page.NextCursor = productCursor{Order: "reviews", Value: last.CreatedAt.UTC().Format(time.RFC3339Nano), ID: last.ID}.encode()
+	}
+	return page
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReviewInputValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       reviewInput
+		expectedErr string
+	}{
+		{name: "Valid", input: reviewInput{Rating: 5, Title: "Lovely", Body: "Great glaze", Author: "Ana"}, expectedErr: ""},
+		{name: "Missing rating", input: reviewInput{Author: "Ana"}, expectedErr: "rating must be a number between 1 and 5"},
+		{name: "Rating too high", input: reviewInput{Rating: 6, Author: "Ana"}, expectedErr: "rating must be a number between 1 and 5"},
+		{name: "Blank author", input: reviewInput{Rating: 4, Author: " "}, expectedErr: "author is required"},
+		{name: "Long author", input: reviewInput{Rating: 4, Author: strings.Repeat("a", maxReviewAuthorLength+1)}, expectedErr: "author must be at most 100 characters"},
+		{name: "Long title", input: reviewInput{Rating: 4, Author: "Ana", Title: strings.Repeat("a", maxReviewTitleLength+1)}, expectedErr: "title must be at most 150 characters"},
+		{name: "Long body", input: reviewInput{Rating: 4, Author: "Ana", Body: strings.Repeat("a", maxReviewBodyLength+1)}, expectedErr: "body must be at most 5000 characters"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.input.validate()
+			if tt.expectedErr == "" && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if tt.expectedErr != "" && (err == nil || err.Error() != tt.expectedErr) {
+				t.Errorf("unexpected error, expected %q but got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestParseReviewPageRequest_Errors(t *testing.T) {
+	productsCursor := productCursor{Order: "price", Value: "10.00", ID: 1}.encode()
+	tests := []struct {
+		query       string
+		admin       bool
+		expectedErr string
+	}{
+		{query: "limit=0", expectedErr: "limit must be a number between 1 and 50"},
+		{query: "limit=51", expectedErr: "limit must be a number between 1 and 50"},
+		{query: "cursor=abc", expectedErr: "invalid cursor"},
+		{query: "cursor=" + productsCursor, expectedErr: "invalid cursor"},
+		{query: "status=hidden", admin: true, expectedErr: "status must be pending, approved or rejected"},
+		{query: "status=pending", expectedErr: "only admins can list reviews that are not approved"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			values, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = parseReviewPageRequest(values, tt.admin)
+			if err == nil || err.Error() != tt.expectedErr {
+				t.Errorf("unexpected error, expected %q but got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestReviewPageRequestPaginate(t *testing.T) {
+	createdAt := time.Date(2023, 6, 27, 15, 0, 0, 0, time.UTC)
+	reviews := []Review{
+		{ID: 3, CreatedAt: createdAt},
+		{ID: 2, CreatedAt: createdAt.Add(-time.Hour)},
+		{ID: 1, CreatedAt: createdAt.Add(-2 * time.Hour)},
+	}
+
+	pr := reviewPageRequest{limit: 2, status: reviewApproved}
+	page := pr.paginate(reviews)
+	if len(page.Reviews) != 2 {
+		t.Fatalf("unexpected reviews: got %d want 2", len(page.Reviews))
+	}
+	expectedCursor := productCursor{Order: "reviews", Value: "2023-06-27T14:00:00Z", ID: 2}.encode()
+	if page.NextCursor != expectedCursor {
+		t.Errorf("unexpected next cursor: got %q want %q", page.NextCursor, expectedCursor)
+	}
+
+	// The last page has no next cursor
+	if page := pr.paginate(reviews[:2]); page.NextCursor != "" {
+		t.Errorf("unexpected next cursor on the last page: %q", page.NextCursor)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"database/sql/driver"
 	"fmt"
 	"strings"
 )
@@ -29,6 +30,12 @@ func (ta *textArray) Scan(value interface{}) error {
 	// Remove the opening and closing braces from the string.
 	str = strings.Trim(str, "{}")
 
+	// An empty array has no values to split.
+	if str == "" {
+		*ta = []string{}
+		return nil
+	}
+
 	// Split the string into individual values (which are surrounded by quotes).
 	values := strings.Split(str, ",")
 
@@ -42,3 +49,44 @@ func (ta *textArray) Scan(value interface{}) error {
 	*ta = ss
 	return nil
 }
+
+// Value converts a text array to a PostgreSQL array literal so it can be sent as a bound argument.
+// Implements the database/sql/driver Valuer interface.
+func (ta textArray) Value() (driver.Value, error) {
+	if ta == nil {
+		return nil, nil
+	}
+
+	// Quote every element, escaping backslashes and double quotes.
+	quoted := make([]string, len(ta))
+	for i, v := range ta {
+		v = strings.ReplaceAll(v, `\`, `\\`)
+		v = strings.ReplaceAll(v, `"`, `\"`)
+		quoted[i] = `"` + v + `"`
+	}
+
+	return "{" + strings.Join(quoted, ",") + "}", nil
+}
+
+// availableQuantity is the SQL expression of the units of a product that are on hand and not reserved by a shopping cart.
+const availableQuantity = "stock_quantity - (SELECT COALESCE(SUM(quantity), 0) FROM stock_reservations " +
+	"WHERE stock_reservations.product_id = products.id AND stock_reservations.variant_id IS NULL AND stock_reservations.expires_at > NOW())"
+
+// productColumns is the list of columns selected for every Product, in the order expected by scanProduct.
+const productColumns = "id, name, slug, price, description, categories, images, referenced_name, date_added, status, publish_at, unpublish_at, average_rating, review_count, " + availableQuantity
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanProduct scans a row selected with productColumns into p.
+// extra are the destinations of any column selected after productColumns.
+func scanProduct(rs rowScanner, p *Product, extra ...interface{}) error {
+	return rs.Scan(append(productDest(p), extra...)...)
+}
+
+// productDest returns the destinations of the columns of productColumns.
+func productDest(p *Product) []interface{} {
+	return []interface{}{&p.ID, &p.Name, &p.Slug, &p.Price, &p.Description, (*textArray)(&p.Categories), (*textArray)(&p.Images), &p.ReferencedName, &p.DateAdded, &p.Status, &p.PublishAt, &p.UnpublishAt, &p.AverageRating, &p.ReviewCount, &p.AvailableQuantity}
+}
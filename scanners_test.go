@@ -24,6 +24,12 @@ func TestScan(t *testing.T) {
 			expected:    nil,
 			expectedErr: fmt.Errorf("failed to scan text array field: value is not []uint8"),
 		},
+		// Test case where value is an empty text array
+		{
+			value:       []uint8("{}"),
+			expected:    []string{},
+			expectedErr: nil,
+		},
 		// Test case where value is a valid text array
 		{
 			value:       []uint8("{foo,bar,baz}"),
@@ -45,3 +51,24 @@ func TestScan(t *testing.T) {
 		}
 	}
 }
+
+func TestValue(t *testing.T) {
+	tests := []struct {
+		value    textArray
+		expected interface{}
+	}{
+		{value: nil, expected: nil},
+		{value: textArray{}, expected: "{}"},
+		{value: textArray{"Category A", `say "hi"`, `back\slash`}, expected: `{"Category A","say \"hi\"","back\\slash"}`},
+	}
+
+	for _, tc := range tests {
+		v, err := tc.value.Value()
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(v, tc.expected) {
+			t.Errorf("unexpected result, expected %v but got %v", tc.expected, v)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"database/sql"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// shoppingCartTTL is how long a shopping cart is kept in Redis, and how long its stock reservations last.
+const shoppingCartTTL = 24 * time.Hour
+
+// lockReservedProductsQuery locks the products of the items of a cart, and of the reservations that reserveStock
+// releases, in the order of their IDs.
+const lockReservedProductsQuery = "SELECT id FROM products WHERE id = ANY($2::int[]) " +
+	"OR id IN (SELECT product_id FROM stock_reservations WHERE cart_key = $1 OR expires_at <= NOW()) ORDER BY id FOR UPDATE"
+
+// touchProductsQuery bumps the updated_at of products whose reservations changed, locking them in the order of
+// their IDs. Reservations don't touch their products with a trigger, which would lock them in any order.
+const touchProductsQuery = "UPDATE products SET updated_at = NOW() WHERE id IN (SELECT id FROM products WHERE id = ANY($1::int[]) ORDER BY id FOR UPDATE)"
+
+// reserveStock replaces the stock reservations of the cart stored under cartKey with one reservation per item,
+// valid until expiresAt. Reservations of items that are no longer in the cart are released, and expired
+// reservations of every cart are cleaned up.
+//
+// An item is only reserved if its product is live and it has enough units that are not reserved by other carts.
+// The product or variant row is locked while checking it, so two carts can't reserve the same units. Every
+// product involved is locked first, in the order of the IDs, and then the items in the order of their product and
+// variant IDs, so two carts can't deadlock. The products whose reservations changed are touched at the end.
+// It returns the items that could not be reserved, and the IDs of the products whose reservations changed.
+func reserveStock(tx *sql.Tx, cartKey string, items []ShoppingCartItem, expiresAt time.Time) ([]ShoppingCartItem, []int, error) {
+	ids := textArray{}
+	for _, item := range items {
+		if item.NumberOfProducts > 0 {
+			ids = append(ids, strconv.Itoa(item.ProductID))
+		}
+	}
+	if _, err := tx.Exec(lockReservedProductsQuery, cartKey, ids); err != nil {
+		return nil, nil, err
+	}
+
+	rows, err := tx.Query("DELETE FROM stock_reservations WHERE cart_key = $1 OR expires_at <= NOW() RETURNING product_id", cartKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	changed := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, nil, err
+		}
+		changed = append(changed, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	sorted := append([]ShoppingCartItem{}, items...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].ProductID != sorted[j].ProductID {
+			return sorted[i].ProductID < sorted[j].ProductID
+		}
+		return sorted[i].VariantID < sorted[j].VariantID
+	})
+
+	unreserved := []ShoppingCartItem{}
+	for _, item := range sorted {
+		if item.NumberOfProducts <= 0 {
+			continue
+		}
+		reserved, err := reserveItem(tx, cartKey, item, expiresAt)
+		if err != nil {
+			return nil, nil, err
+		}
+		if reserved {
+			changed = append(changed, item.ProductID)
+		} else {
+			unreserved = append(unreserved, item)
+		}
+	}
+
+	if len(changed) > 0 {
+		touched := make(textArray, len(changed))
+		for i, id := range changed {
+			touched[i] = strconv.Itoa(id)
+		}
+		if _, err := tx.Exec(touchProductsQuery, touched); err != nil {
+			return nil, nil, err
+		}
+	}
+	return unreserved, changed, nil
+}
+
+// reserveItem reserves the units of a single cart item, and reports whether there were enough of them.
+func reserveItem(tx *sql.Tx, cartKey string, item ShoppingCartItem, expiresAt time.Time) (bool, error) {
+	// Lock the product or variant until the transaction ends. Products that are not live, and their variants, are
+	// not found, so they can't be reserved.
+	var stock int
+	var variantID interface{}
+	var err error
+	if item.VariantID != 0 {
+		variantID = item.VariantID
+		err = tx.QueryRow("SELECT stock FROM product_variants WHERE id = $1 AND product_id = $2 AND "+
+			"EXISTS (SELECT 1 FROM products WHERE products.id = product_variants.product_id AND "+liveCondition+") FOR UPDATE",
+			item.VariantID, item.ProductID).Scan(&stock)
+	} else {
+		err = tx.QueryRow("SELECT stock_quantity FROM products WHERE id = $1 AND "+liveCondition+" FOR UPDATE", item.ProductID).Scan(&stock)
+	}
+	if err == sql.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	var reserved int
+	err = tx.QueryRow("SELECT COALESCE(SUM(quantity), 0) FROM stock_reservations "+
+		"WHERE product_id = $1 AND variant_id IS NOT DISTINCT FROM $2 AND expires_at > NOW()", item.ProductID, variantID).Scan(&reserved)
+	if err != nil {
+		return false, err
+	}
+	if stock-reserved < item.NumberOfProducts {
+		return false, nil
+	}
+
+	_, err = tx.Exec("INSERT INTO stock_reservations (cart_key, product_id, variant_id, quantity, expires_at) VALUES ($1, $2, $3, $4, $5)",
+		cartKey, item.ProductID, variantID, item.NumberOfProducts, expiresAt)
+	return err == nil, err
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// lockProductQuery and lockVariantQuery are the queries reserveItem locks the product or variant of an item with.
+const (
+	lockProductQuery = "SELECT stock_quantity FROM products WHERE id = $1 AND " + liveCondition + " FOR UPDATE"
+	lockVariantQuery = "SELECT stock FROM product_variants WHERE id = $1 AND product_id = $2 AND " +
+		"EXISTS (SELECT 1 FROM products WHERE products.id = product_variants.product_id AND " + liveCondition + ") FOR UPDATE"
+)
+
+// expectReservation sets the expectations of reserveItem checking an item with the given stock and
+// reserved units, and reserving it when there are enough units left.
+func expectReservation(mock sqlmock.Sqlmock, cartKey string, item ShoppingCartItem, stock, reserved int) {
+	var variantID interface{}
+	if item.VariantID != 0 {
+		variantID = item.VariantID
+		mock.ExpectQuery(regexp.QuoteMeta(lockVariantQuery)).
+			WithArgs(item.VariantID, item.ProductID).
+			WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(stock))
+	} else {
+		mock.ExpectQuery(regexp.QuoteMeta(lockProductQuery)).
+			WithArgs(item.ProductID).
+			WillReturnRows(sqlmock.NewRows([]string{"stock_quantity"}).AddRow(stock))
+	}
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COALESCE(SUM(quantity), 0) FROM stock_reservations WHERE product_id = $1 AND variant_id IS NOT DISTINCT FROM $2 AND expires_at > NOW()")).
+		WithArgs(item.ProductID, variantID).
+		WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(reserved))
+	if stock-reserved >= item.NumberOfProducts {
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO stock_reservations (cart_key, product_id, variant_id, quantity, expires_at) VALUES ($1, $2, $3, $4, $5)")).
+			WithArgs(cartKey, item.ProductID, variantID, item.NumberOfProducts, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+	}
+}
+
+// expectReleasedReservations sets the expectations of reserveStock locking the products of the cart items and
+// releasing the previous reservations of the cart, which were reservations of the given products.
+func expectReleasedReservations(mock sqlmock.Sqlmock, cartKey string, items []ShoppingCartItem, productIDs ...int) {
+	ids := textArray{}
+	for _, item := range items {
+		if item.NumberOfProducts > 0 {
+			ids = append(ids, strconv.Itoa(item.ProductID))
+		}
+	}
+	mock.ExpectExec(regexp.QuoteMeta(lockReservedProductsQuery)).
+		WithArgs(cartKey, ids).
+		WillReturnResult(sqlmock.NewResult(0, int64(len(ids))))
+	rows := sqlmock.NewRows([]string{"product_id"})
+	for _, id := range productIDs {
+		rows.AddRow(id)
+	}
+	mock.ExpectQuery(regexp.QuoteMeta("DELETE FROM stock_reservations WHERE cart_key = $1 OR expires_at <= NOW() RETURNING product_id")).
+		WithArgs(cartKey).
+		WillReturnRows(rows)
+}
+
+// expectTouchedProducts sets the expectation of reserveStock touching the products whose reservations changed.
+func expectTouchedProducts(mock sqlmock.Sqlmock, productIDs ...string) {
+	mock.ExpectExec(regexp.QuoteMeta(touchProductsQuery)).
+		WithArgs(textArray(productIDs)).
+		WillReturnResult(sqlmock.NewResult(0, int64(len(productIDs))))
+}
+
+func TestReserveStock(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	items := []ShoppingCartItem{
+		{ProductID: 1, NumberOfProducts: 1},
+		{ProductID: 2, VariantID: 5, NumberOfProducts: 2},
+		{ProductID: 3, NumberOfProducts: 1},
+		{ProductID: 4, NumberOfProducts: 0},
+	}
+	mock.ExpectBegin()
+	expectReleasedReservations(mock, "127.0.0.1", items, 9)
+	expectReservation(mock, "127.0.0.1", items[0], 1, 0)
+	// Only one unit of the variant is left, the other one is in another cart
+	expectReservation(mock, "127.0.0.1", items[1], 2, 1)
+	mock.ExpectQuery(regexp.QuoteMeta(lockProductQuery)).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"stock_quantity"}))
+	expectTouchedProducts(mock, "9", "1")
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	unreserved, changed, err := reserveStock(tx, "127.0.0.1", items, time.Now().Add(shoppingCartTTL))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := items[1:3]; !reflect.DeepEqual(unreserved, expected) {
+		t.Errorf("unexpected unreserved items: got %v want %v", unreserved, expected)
+	}
+	// The released reservation and the new one
+	if expected := []int{9, 1}; !reflect.DeepEqual(changed, expected) {
+		t.Errorf("unexpected changed products: got %v want %v", changed, expected)
+	}
+	checkMockExpectations(t, mock)
+}
+
+func TestReserveStock_NotLive(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	// Draft products and their variants are not found by the live condition, so they are not reserved
+	items := []ShoppingCartItem{{ProductID: 1, NumberOfProducts: 1}, {ProductID: 1, VariantID: 2, NumberOfProducts: 1}}
+	mock.ExpectBegin()
+	expectReleasedReservations(mock, "127.0.0.1", items)
+	mock.ExpectQuery(regexp.QuoteMeta(lockProductQuery)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"stock_quantity"}))
+	mock.ExpectQuery(regexp.QuoteMeta(lockVariantQuery)).
+		WithArgs(2, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"stock"}))
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	unreserved, changed, err := reserveStock(tx, "127.0.0.1", items, time.Now().Add(shoppingCartTTL))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(unreserved, items) {
+		t.Errorf("unexpected unreserved items: got %v want %v", unreserved, items)
+	}
+	if len(changed) != 0 {
+		t.Errorf("unexpected changed products: %v", changed)
+	}
+	checkMockExpectations(t, mock)
+}
+
+func TestReserveStock_LockOrder(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	// The items are locked in the order of their product and variant IDs, whatever their order in the cart
+	items := []ShoppingCartItem{
+		{ProductID: 3, NumberOfProducts: 1},
+		{ProductID: 1, VariantID: 7, NumberOfProducts: 1},
+		{ProductID: 1, VariantID: 2, NumberOfProducts: 1},
+	}
+	mock.ExpectBegin()
+	expectReleasedReservations(mock, "127.0.0.1", items)
+	expectReservation(mock, "127.0.0.1", items[2], 1, 0)
+	expectReservation(mock, "127.0.0.1", items[1], 1, 0)
+	expectReservation(mock, "127.0.0.1", items[0], 1, 0)
+	expectTouchedProducts(mock, "1", "1", "3")
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	unreserved, changed, err := reserveStock(tx, "127.0.0.1", items, time.Now().Add(shoppingCartTTL))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(unreserved) != 0 {
+		t.Errorf("unexpected unreserved items: %v", unreserved)
+	}
+	if expected := []int{1, 1, 3}; !reflect.DeepEqual(changed, expected) {
+		t.Errorf("unexpected changed products: got %v want %v", changed, expected)
+	}
+	checkMockExpectations(t, mock)
+}
+
+func TestReserveStock_DBError(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	expectReleasedReservations(mock, "127.0.0.1", []ShoppingCartItem{{ProductID: 1, NumberOfProducts: 1}})
+	mock.ExpectQuery(regexp.QuoteMeta(lockProductQuery)).
+		WithArgs(1).
+		WillReturnError(errors.New("some error"))
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = reserveStock(tx, "127.0.0.1", []ShoppingCartItem{{ProductID: 1, NumberOfProducts: 1}}, time.Now())
+	if err == nil {
+		t.Error("expected the query error")
+	}
+	checkMockExpectations(t, mock)
+}
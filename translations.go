@@ -0,0 +1,213 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// defaultLocale is the locale of the names and descriptions stored in the products and categories tables.
+const defaultLocale = "es"
+
+// supportedLocales are the locales the catalog is published in. The ones other than defaultLocale
+// are stored in the product_translations and category_translations tables.
+var supportedLocales = []string{"en", "es"}
+
+// isSupportedLocale reports whether the catalog is published in the locale.
+func isSupportedLocale(locale string) bool {
+	for _, l := range supportedLocales {
+		if l == locale {
+			return true
+		}
+	}
+	return false
+}
+
+// primaryLanguage returns the lowercase primary subtag of a language tag, e.g. "en" for "en-US".
+func primaryLanguage(tag string) string {
+	lang, _, _ := strings.Cut(strings.TrimSpace(tag), "-")
+	return strings.ToLower(lang)
+}
+
+// parseLocale reads the locale of a catalog response: the lang query parameter, or else the best supported
+// match of the Accept-Language header (e.g. "en-US,en;q=0.9,es;q=0.5"), or else the default locale.
+// Language tags match a locale by their primary subtag. It returns a validationError if the lang query
+// parameter is not a supported locale.
+func parseLocale(r *http.Request) (string, error) {
+	if lang := r.URL.Query().Get("lang"); lang != "" {
+		locale := primaryLanguage(lang)
+		if !isSupportedLocale(locale) {
+			return "", validationError{"lang must be one of " + strings.Join(supportedLocales, ", ")}
+		}
+		return locale, nil
+	}
+
+	type weightedTag struct {
+		tag string
+		q   float64
+	}
+	tags := []weightedTag{}
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		wt := weightedTag{tag: strings.TrimSpace(tag), q: 1}
+		if params = strings.TrimSpace(params); strings.HasPrefix(params, "q=") {
+			q, err := strconv.ParseFloat(strings.TrimPrefix(params, "q="), 64)
+			if err != nil {
+				continue
+			}
+			wt.q = q
+		}
+		if wt.tag != "" && wt.q > 0 {
+			tags = append(tags, wt)
+		}
+	}
+	// The most preferred first, and in the order of the header when they are equally preferred
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+	for _, wt := range tags {
+		if wt.tag == "*" {
+			return defaultLocale, nil
+		}
+		if locale := primaryLanguage(wt.tag); isSupportedLocale(locale) {
+			return locale, nil
+		}
+	}
+	return defaultLocale, nil
+}
+
+// setLocaleHeaders reports the locale a catalog response was served in, and that it depends on the Accept-Language header.
+func setLocaleHeaders(w http.ResponseWriter, locale string) {
+	w.Header().Set("Content-Language", locale)
+	w.Header().Add("Vary", "Accept-Language")
+}
+
+// translateProducts replaces the names and descriptions of the products with their translations to the locale,
+// fetched with a single query. Products without a translation, and descriptions without one, keep the content in
+// the default locale. It returns the locale the products were served in: the default locale if none of them
+// had a translation, and the given locale otherwise.
+func translateProducts(db *sql.DB, products []Product, locale string) (string, error) {
+	if locale == defaultLocale || len(products) == 0 {
+		return locale, nil
+	}
+
+	ids := make(textArray, len(products))
+	byID := make(map[int]*Product, len(products))
+	for i := range products {
+		ids[i] = strconv.Itoa(products[i].ID)
+		byID[products[i].ID] = &products[i]
+	}
+
+	rows, err := db.Query("SELECT product_id, name, description FROM product_translations WHERE product_id = ANY($1::int[]) AND locale = $2", ids, locale)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	translated := false
+	for rows.Next() {
+		var id int
+		var name string
+		var description sql.NullString
+		if err := rows.Scan(&id, &name, &description); err != nil {
+			return "", err
+		}
+		if p, ok := byID[id]; ok {
+			translated = true
+			p.Name = name
+			if description.Valid {
+				p.Description = description.String
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	if !translated {
+		return defaultLocale, nil
+	}
+	return locale, nil
+}
+
+// loadCategoryNames returns the names of the categories translated to the locale, by category slug.
+// Categories without a translation are not in the map. It is empty for the default locale.
+// It also returns the locale the names are served in, like translateProducts: the default locale if no category
+// has a translation, and the given locale otherwise.
+func loadCategoryNames(db *sql.DB, locale string) (map[string]string, string, error) {
+	names := map[string]string{}
+	if locale == defaultLocale {
+		return names, locale, nil
+	}
+
+	rows, err := db.Query("SELECT c.slug, t.name FROM category_translations t JOIN categories c ON c.id = t.category_id WHERE t.locale = $1", locale)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var slug, name string
+		if err := rows.Scan(&slug, &name); err != nil {
+			return nil, "", err
+		}
+		names[slug] = name
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+	if len(names) == 0 {
+		return names, defaultLocale, nil
+	}
+	return names, locale, nil
+}
+
+// productTranslation is a stored translation of the name and description of a product.
+type productTranslation struct {
+	ProductID int    `json:"product_id"`
+	Locale    string `json:"locale"`
+	Name      string `json:"name"`
+	// Description is nil when the product shows its description in the default locale.
+	Description *string `json:"description"`
+}
+
+// productTranslationInput is the request body accepted when translating a product.
+type productTranslationInput struct {
+	Name        string  `json:"name"`
+	Description *string `json:"description"`
+}
+
+// validateTranslationLocale checks that a translation can be stored for the locale.
+func validateTranslationLocale(locale string) error {
+	if locale == defaultLocale {
+		return validationError{defaultLocale + " is the default locale, it is stored in the product or category itself"}
+	}
+	if !isSupportedLocale(locale) {
+		return validationError{"locale must be one of " + strings.Join(supportedLocales, ", ")}
+	}
+	return nil
+}
+
+// validate checks every field of a product translation input.
+func (in productTranslationInput) validate() error {
+	return validateName(in.Name)
+}
+
+// categoryTranslation is a stored translation of the name of a category.
+type categoryTranslation struct {
+	Slug   string `json:"slug"`
+	Locale string `json:"locale"`
+	Name   string `json:"name"`
+}
+
+// categoryTranslationInput is the request body accepted when translating a category.
+type categoryTranslationInput struct {
+	Name string `json:"name"`
+}
+
+// validate checks the fields of a category translation input.
+func (in categoryTranslationInput) validate() error {
+	if strings.TrimSpace(in.Name) == "" {
+		return validationError{"name is required"}
+	}
+	return nil
+}
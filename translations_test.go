@@ -0,0 +1,161 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// expectProductTranslations sets the expectation of loading the translations of the products to the locale.
+func expectProductTranslations(mock sqlmock.Sqlmock, products []Product, locale string, translations ...productTranslation) {
+	ids := textArray{}
+	for _, p := range products {
+		ids = append(ids, strconv.Itoa(p.ID))
+	}
+	rows := sqlmock.NewRows([]string{"product_id", "name", "description"})
+	for _, t := range translations {
+		rows.AddRow(t.ProductID, t.Name, t.Description)
+	}
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT product_id, name, description FROM product_translations WHERE product_id = ANY($1::int[]) AND locale = $2")).
+		WithArgs(ids, locale).
+		WillReturnRows(rows)
+}
+
+// expectCategoryNames sets the expectation of loading the names of the categories in the locale.
+func expectCategoryNames(mock sqlmock.Sqlmock, locale string, names map[string]string) {
+	rows := sqlmock.NewRows([]string{"slug", "name"})
+	for slug, name := range names {
+		rows.AddRow(slug, name)
+	}
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT c.slug, t.name FROM category_translations t JOIN categories c ON c.id = t.category_id WHERE t.locale = $1")).
+		WithArgs(locale).
+		WillReturnRows(rows)
+}
+
+func TestParseLocale(t *testing.T) {
+	tests := []struct {
+		name           string
+		target         string
+		acceptLanguage string
+		expected       string
+		expectedErr    string
+	}{
+		{name: "Default", target: "/products", expected: defaultLocale},
+		{name: "Query parameter", target: "/products?lang=EN-us", acceptLanguage: "es", expected: "en"},
+		{name: "Header", target: "/products", acceptLanguage: "en-US,en;q=0.9", expected: "en"},
+		{name: "Header by preference", target: "/products", acceptLanguage: "fr;q=0.9, en;q=0.5, es;q=0.8", expected: "es"},
+		{name: "Unsupported header", target: "/products", acceptLanguage: "fr-FR, de", expected: defaultLocale},
+		{name: "Not acceptable", target: "/products", acceptLanguage: "en;q=0", expected: defaultLocale},
+		{name: "Wildcard", target: "/products", acceptLanguage: "fr, *;q=0.5, en;q=0.1", expected: defaultLocale},
+		{name: "Unsupported query parameter", target: "/products?lang=fr", expectedErr: "lang must be one of en, es"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, tt.target, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.acceptLanguage != "" {
+				req.Header.Set("Accept-Language", tt.acceptLanguage)
+			}
+			locale, err := parseLocale(req)
+			if tt.expectedErr != "" {
+				if err == nil || err.Error() != tt.expectedErr {
+					t.Errorf("unexpected error, expected %q but got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if locale != tt.expected {
+				t.Errorf("unexpected locale: got %q want %q", locale, tt.expected)
+			}
+		})
+	}
+}
+
+func TestTranslateProducts(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	// Product A has its name translated, and keeps its description in the default locale
+	products := getExpectedProducts()
+	expectProductTranslations(mock, products, "en", productTranslation{ProductID: 1, Name: "Product A in English"})
+
+	locale, err := translateProducts(db, products, "en")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if locale != "en" {
+		t.Errorf("unexpected locale: got %q want %q", locale, "en")
+	}
+	expected := getExpectedProducts()
+	if products[0].Name != "Product A in English" || products[0].Description != expected[0].Description {
+		t.Errorf("unexpected product A: %+v", products[0])
+	}
+	if products[1].Name != expected[1].Name || products[1].Description != expected[1].Description {
+		t.Errorf("unexpected product B: %+v", products[1])
+	}
+	checkMockExpectations(t, mock)
+}
+
+func TestTranslateProducts_Untranslated(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	products := getExpectedProducts()
+	expectProductTranslations(mock, products, "en")
+
+	locale, err := translateProducts(db, products, "en")
+	if err != nil || locale != defaultLocale {
+		t.Errorf("unexpected result: %q, %v", locale, err)
+	}
+
+	// The default locale needs no query
+	locale, err = translateProducts(db, products, defaultLocale)
+	if err != nil || locale != defaultLocale {
+		t.Errorf("unexpected result: %q, %v", locale, err)
+	}
+	checkMockExpectations(t, mock)
+}
+
+func TestTranslateProducts_DBError(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	mock.ExpectQuery("FROM product_translations").WillReturnError(errors.New("some error"))
+
+	if _, err := translateProducts(db, getExpectedProducts(), "en"); err == nil {
+		t.Error("expected an error")
+	}
+	checkMockExpectations(t, mock)
+}
+
+func TestValidateTranslationLocale(t *testing.T) {
+	tests := []struct {
+		locale      string
+		expectedErr string
+	}{
+		{locale: "en", expectedErr: ""},
+		{locale: "es", expectedErr: "es is the default locale, it is stored in the product or category itself"},
+		{locale: "fr", expectedErr: "locale must be one of en, es"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.locale, func(t *testing.T) {
+			err := validateTranslationLocale(tt.locale)
+			if tt.expectedErr == "" && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if tt.expectedErr != "" && (err == nil || err.Error() != tt.expectedErr) {
+				t.Errorf("unexpected error, expected %q but got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
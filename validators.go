@@ -0,0 +1,243 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	maxNameLength     = 255
+	maxCategoryLength = 100
+	maxImageLength    = 2048
+	maxSKULength      = 64
+	maxOptionLength   = 100
+	maxVariantOptions = 10
+	// maxPriceAmount is the biggest amount, in minor units, that fits in the DECIMAL(10,2) price column.
+	maxPriceAmount = 9999999999
+)
+
+// validationError is returned when a product payload is not acceptable.
+// Its message is safe to send back to the client.
+type validationError struct {
+	msg string
+}
+
+func (e validationError) Error() string {
+	return e.msg
+}
+
+// validateName checks that a product name is present and not too long.
+func validateName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return validationError{"name is required"}
+	}
+	if len(name) > maxNameLength {
+		return validationError{fmt.Sprintf("name must be at most %d characters", maxNameLength)}
+	}
+	return nil
+}
+
+// validatePrice checks that a price is in the store currency, is positive and fits in the price column.
+// The number of decimals is already checked when the price is decoded.
+func validatePrice(price Money) error {
+	if price == (Money{}) {
+		return validationError{"price is required"}
+	}
+	if price.Currency != storeCurrency {
+		return validationError{fmt.Sprintf("price must be in %s", storeCurrency)}
+	}
+	if price.Amount <= 0 {
+		return validationError{"price must be greater than zero"}
+	}
+	if price.Amount > maxPriceAmount {
+		return validationError{"price must be at most " + Money{Amount: maxPriceAmount, Currency: storeCurrency}.String()}
+	}
+	return nil
+}
+
+// validateCategories checks that every category is a non-empty string and that none is repeated.
+func validateCategories(categories []string) error {
+	seen := make(map[string]bool, len(categories))
+	for _, c := range categories {
+		if strings.TrimSpace(c) == "" {
+			return validationError{"categories must not be empty"}
+		}
+		if len(c) > maxCategoryLength {
+			return validationError{fmt.Sprintf("categories must be at most %d characters", maxCategoryLength)}
+		}
+		if seen[c] {
+			return validationError{fmt.Sprintf("category %q is repeated", c)}
+		}
+		seen[c] = true
+	}
+	return nil
+}
+
+// validateImages checks that every image is a non-empty file name or URL without whitespace.
+func validateImages(images []string) error {
+	for _, img := range images {
+		if img == "" || strings.ContainsAny(img, " \t\r\n") {
+			return validationError{"images must be non-empty and must not contain whitespace"}
+		}
+		if len(img) > maxImageLength {
+			return validationError{fmt.Sprintf("images must be at most %d characters", maxImageLength)}
+		}
+	}
+	return nil
+}
+
+// validateSlug checks that a slug is made of lowercase ASCII letters and digits separated by single hyphens,
+// and is not too long.
+func validateSlug(slug string) error {
+	if slug == "" || len(slug) > maxSlugLength || slug != slugify(slug) {
+		return validationError{fmt.Sprintf("slug must have at most %d lowercase letters, digits and single hyphens between them", maxSlugLength)}
+	}
+	return nil
+}
+
+// validateStatus checks that a product status is draft, published or archived.
+func validateStatus(status string) error {
+	switch status {
+	case statusDraft, statusPublished, statusArchived:
+		return nil
+	}
+	return validationError{"status must be draft, published or archived"}
+}
+
+// validatePublishWindow checks that a product is unpublished after it is published, when both dates are set.
+func validatePublishWindow(publishAt, unpublishAt *time.Time) error {
+	if publishAt != nil && unpublishAt != nil && !unpublishAt.After(*publishAt) {
+		return validationError{"unpublish_at must be after publish_at"}
+	}
+	return nil
+}
+
+// validateSKU checks that a SKU is present, not too long and has no whitespace.
+func validateSKU(sku string) error {
+	if sku == "" {
+		return validationError{"sku is required"}
+	}
+	if strings.ContainsAny(sku, " \t\r\n") {
+		return validationError{"sku must not contain whitespace"}
+	}
+	if len(sku) > maxSKULength {
+		return validationError{fmt.Sprintf("sku must be at most %d characters", maxSKULength)}
+	}
+	return nil
+}
+
+// validateOptions checks that a variant has a few options with non-empty names and values.
+func validateOptions(options map[string]string) error {
+	if len(options) > maxVariantOptions {
+		return validationError{fmt.Sprintf("options must have at most %d values", maxVariantOptions)}
+	}
+	for name, value := range options {
+		if strings.TrimSpace(name) == "" || strings.TrimSpace(value) == "" {
+			return validationError{"options must have non-empty names and values"}
+		}
+		if len(name) > maxOptionLength || len(value) > maxOptionLength {
+			return validationError{fmt.Sprintf("options names and values must be at most %d characters", maxOptionLength)}
+		}
+	}
+	return nil
+}
+
+// validate checks every field of a product input.
+func (in productInput) validate() error {
+	if err := validateName(in.Name); err != nil {
+		return err
+	}
+	if err := validatePrice(in.Price); err != nil {
+		return err
+	}
+	if err := validateCategories(in.Categories); err != nil {
+		return err
+	}
+	if err := validateImages(in.Images); err != nil {
+		return err
+	}
+	if in.Slug != "" {
+		if err := validateSlug(in.Slug); err != nil {
+			return err
+		}
+	}
+	if in.Status != "" {
+		if err := validateStatus(in.Status); err != nil {
+			return err
+		}
+	}
+	return validatePublishWindow(in.PublishAt, in.UnpublishAt)
+}
+
+// validate checks the fields that are present in a product patch.
+func (p productPatch) validate() error {
+	if p.Name != nil {
+		if err := validateName(*p.Name); err != nil {
+			return err
+		}
+	}
+	if p.Price != nil {
+		if err := validatePrice(*p.Price); err != nil {
+			return err
+		}
+	}
+	if p.Categories != nil {
+		if err := validateCategories(*p.Categories); err != nil {
+			return err
+		}
+	}
+	if p.Images != nil {
+		if err := validateImages(*p.Images); err != nil {
+			return err
+		}
+	}
+	if p.Slug != nil {
+		if err := validateSlug(*p.Slug); err != nil {
+			return err
+		}
+	}
+	if p.Status != nil {
+		if err := validateStatus(*p.Status); err != nil {
+			return err
+		}
+	}
+	return validatePublishWindow(p.PublishAt.Time, p.UnpublishAt.Time)
+}
+
+// validate checks every field of a variant input. The price is optional.
+func (in variantInput) validate() error {
+	if err := validateSKU(in.SKU); err != nil {
+		return err
+	}
+	if err := validateOptions(in.Options); err != nil {
+		return err
+	}
+	if in.Price != nil {
+		if err := validatePrice(*in.Price); err != nil {
+			return err
+		}
+	}
+	if err := validateImages(in.Images); err != nil {
+		return err
+	}
+	if in.Stock < 0 {
+		return validationError{"stock must not be negative"}
+	}
+	return nil
+}
+
+// decodeJSONBody decodes the JSON request body into v, rejecting unknown fields and trailing data.
+func decodeJSONBody(r *http.Request, v interface{}) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return validationError{"invalid request body: " + err.Error()}
+	}
+	if dec.More() {
+		return validationError{"invalid request body: unexpected data after JSON object"}
+	}
+	return nil
+}
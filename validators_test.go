@@ -0,0 +1,84 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// cop returns an amount of Colombian pesos in minor units.
+func cop(amount int64) Money {
+	return Money{Amount: amount, Currency: storeCurrency}
+}
+
+func TestProductInputValidate(t *testing.T) {
+	publishAt := time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC)
+	unpublishAt := publishAt.AddDate(0, 1, 0)
+	tests := []struct {
+		name        string
+		input       productInput
+		expectedErr string
+	}{
+		{name: "Valid", input: productInput{Name: "Mug", Price: cop(1250), Categories: []string{"Mugs"}, Images: []string{"mug.jpg"}}, expectedErr: ""},
+		{name: "Blank name", input: productInput{Name: "  ", Price: cop(100)}, expectedErr: "name is required"},
+		{name: "Long name", input: productInput{Name: strings.Repeat("a", maxNameLength+1), Price: cop(100)}, expectedErr: "name must be at most 255 characters"},
+		{name: "Missing price", input: productInput{Name: "Mug"}, expectedErr: "price is required"},
+		{name: "Zero price", input: productInput{Name: "Mug", Price: cop(0)}, expectedErr: "price must be greater than zero"},
+		{name: "Price too big", input: productInput{Name: "Mug", Price: cop(10000000000)}, expectedErr: "price must be at most 99999999.99"},
+		{name: "Price in another currency", input: productInput{Name: "Mug", Price: Money{Amount: 100, Currency: "USD"}}, expectedErr: "price must be in COP"},
+		{name: "Empty category", input: productInput{Name: "Mug", Price: cop(100), Categories: []string{""}}, expectedErr: "categories must not be empty"},
+		{name: "Empty image", input: productInput{Name: "Mug", Price: cop(100), Images: []string{""}}, expectedErr: "images must be non-empty and must not contain whitespace"},
+		{name: "Slug", input: productInput{Name: "Mug", Price: cop(100), Slug: "blue-mug-2"}, expectedErr: ""},
+		{name: "Slug with accents", input: productInput{Name: "Mug", Price: cop(100), Slug: "taza-pequeña"}, expectedErr: "slug must have at most 100 lowercase letters, digits and single hyphens between them"},
+		{name: "Slug with a trailing hyphen", input: productInput{Name: "Mug", Price: cop(100), Slug: "mug-"}, expectedErr: "slug must have at most 100 lowercase letters, digits and single hyphens between them"},
+		{name: "Draft", input: productInput{Name: "Mug", Price: cop(100), Status: statusDraft}, expectedErr: ""},
+		{name: "Unknown status", input: productInput{Name: "Mug", Price: cop(100), Status: "deleted"}, expectedErr: "status must be draft, published or archived"},
+		{name: "Scheduled is not stored", input: productInput{Name: "Mug", Price: cop(100), Status: statusScheduled}, expectedErr: "status must be draft, published or archived"},
+		{name: "Publishing window", input: productInput{Name: "Mug", Price: cop(100), PublishAt: &publishAt, UnpublishAt: &unpublishAt}, expectedErr: ""},
+		{name: "Unpublished before published", input: productInput{Name: "Mug", Price: cop(100), PublishAt: &unpublishAt, UnpublishAt: &publishAt}, expectedErr: "unpublish_at must be after publish_at"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.input.validate()
+			if tt.expectedErr == "" && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if tt.expectedErr != "" && (err == nil || err.Error() != tt.expectedErr) {
+				t.Errorf("unexpected error, expected %q but got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestVariantInputValidate(t *testing.T) {
+	price := cop(2500)
+	zero := cop(0)
+	tests := []struct {
+		name        string
+		input       variantInput
+		expectedErr string
+	}{
+		{name: "Valid", input: variantInput{SKU: "MUG-BLUE-L", Options: map[string]string{"glaze": "blue", "size": "large"}, Price: &price, Stock: 2}, expectedErr: ""},
+		{name: "Without price", input: variantInput{SKU: "MUG-BLUE-S"}, expectedErr: ""},
+		{name: "Missing SKU", input: variantInput{}, expectedErr: "sku is required"},
+		{name: "SKU with spaces", input: variantInput{SKU: "MUG BLUE"}, expectedErr: "sku must not contain whitespace"},
+		{name: "Long SKU", input: variantInput{SKU: strings.Repeat("A", maxSKULength+1)}, expectedErr: "sku must be at most 64 characters"},
+		{name: "Empty option value", input: variantInput{SKU: "MUG", Options: map[string]string{"glaze": " "}}, expectedErr: "options must have non-empty names and values"},
+		{name: "Zero price", input: variantInput{SKU: "MUG", Price: &zero}, expectedErr: "price must be greater than zero"},
+		{name: "Empty image", input: variantInput{SKU: "MUG", Images: []string{""}}, expectedErr: "images must be non-empty and must not contain whitespace"},
+		{name: "Negative stock", input: variantInput{SKU: "MUG", Stock: -1}, expectedErr: "stock must not be negative"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.input.validate()
+			if tt.expectedErr == "" && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if tt.expectedErr != "" && (err == nil || err.Error() != tt.expectedErr) {
+				t.Errorf("unexpected error, expected %q but got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// ProductVariant is a purchasable version of a product, such as a glaze or a size, with its own SKU and stock.
+type ProductVariant struct {
+	ID        int    `json:"id"`
+	ProductID int    `json:"product_id"`
+	SKU       string `json:"sku"`
+	// Options are the values that tell the variant apart, e.g. {"glaze": "celadon", "size": "large"}.
+	Options map[string]string `json:"options"`
+	// Price is nil when the variant costs the same as its product.
+	Price  *Money   `json:"price,omitempty"`
+	Images []string `json:"images"`
+	// Stock is the number of units on hand that are not reserved by a shopping cart.
+	Stock int `json:"stock"`
+}
+
+// availableVariantStock is the SQL expression of the units of a variant that are on hand and not reserved by a
+// shopping cart, like availableQuantity for products.
+const availableVariantStock = "product_variants.stock - (SELECT COALESCE(SUM(quantity), 0) FROM stock_reservations " +
+	"WHERE stock_reservations.variant_id = product_variants.id AND stock_reservations.expires_at > NOW())"
+
+// variantColumns is the list of columns selected for every ProductVariant, in the order expected by scanVariant.
+const variantColumns = "id, product_id, sku, options, price, images, " + availableVariantStock
+
+// scanVariant scans a row selected with variantColumns into v.
+func scanVariant(rs rowScanner, v *ProductVariant) error {
+	var options []byte
+	err := rs.Scan(&v.ID, &v.ProductID, &v.SKU, &options, &v.Price, (*textArray)(&v.Images), &v.Stock)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(options, &v.Options); err != nil {
+		return fmt.Errorf("failed to scan variant options: %w", err)
+	}
+	return nil
+}
+
+// loadVariants fetches the variants of the given products with a single query and sets them on every product,
+// ordered by ID. Products without variants are left with a nil Variants.
+func loadVariants(db *sql.DB, products []Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+
+	ids := make(textArray, len(products))
+	byID := make(map[int]*Product, len(products))
+	for i := range products {
+		ids[i] = strconv.Itoa(products[i].ID)
+		byID[products[i].ID] = &products[i]
+	}
+
+	rows, err := db.Query("SELECT "+variantColumns+" FROM product_variants WHERE product_id = ANY($1::int[]) ORDER BY product_id, id", ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		v := ProductVariant{}
+		if err := scanVariant(rows, &v); err != nil {
+			return err
+		}
+		if p, ok := byID[v.ProductID]; ok {
+			p.Variants = append(p.Variants, v)
+		}
+	}
+	return rows.Err()
+}
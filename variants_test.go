@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// getVariantRows returns a mock sqlmock.Rows object populated with the given variants.
+func getVariantRows(variants []ProductVariant) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"id", "product_id", "sku", "options", "price", "images", "stock"})
+	for _, v := range variants {
+		options, _ := json.Marshal(v.Options)
+		var price interface{}
+		if v.Price != nil {
+			price = v.Price.String()
+		}
+		rows.AddRow(v.ID, v.ProductID, v.SKU, options, price, sliceToPostgreSQLArray(v.Images), v.Stock)
+	}
+	return rows
+}
+
+// expectVariants sets the expectation of loadVariants fetching the variants of the given products.
+func expectVariants(mock sqlmock.Sqlmock, products []Product, variants ...ProductVariant) {
+	ids := textArray{}
+	for _, p := range products {
+		ids = append(ids, strconv.Itoa(p.ID))
+	}
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT " + variantColumns + " FROM product_variants WHERE product_id = ANY($1::int[]) ORDER BY product_id, id")).
+		WithArgs(ids).
+		WillReturnRows(getVariantRows(variants))
+}
+
+func TestLoadVariants(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	price := cop(1500)
+	variants := []ProductVariant{
+		{ID: 1, ProductID: 1, SKU: "MUG-BLUE-S", Options: map[string]string{"glaze": "blue", "size": "small"}, Images: []string{"blue.jpg"}, Stock: 3},
+		{ID: 2, ProductID: 1, SKU: "MUG-BLUE-L", Options: map[string]string{"glaze": "blue", "size": "large"}, Price: &price, Images: []string{}, Stock: 0},
+		{ID: 3, ProductID: 2, SKU: "PLATE-WHITE", Options: map[string]string{"glaze": "white"}, Images: []string{}, Stock: 1},
+	}
+	products := getExpectedProducts()
+	expectVariants(mock, products, variants...)
+
+	if err := loadVariants(db, products); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, _ := json.Marshal([][]ProductVariant{products[0].Variants, products[1].Variants})
+	expected, _ := json.Marshal([][]ProductVariant{variants[:2], variants[2:]})
+	if string(got) != string(expected) {
+		t.Errorf("unexpected variants: got %s want %s", got, expected)
+	}
+	checkMockExpectations(t, mock)
+}
+
+func TestLoadVariants_NoProducts(t *testing.T) {
+	// No query is made for an empty page
+	if err := loadVariants(nil, []Product{}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestLoadVariants_Errors(t *testing.T) {
+	db, mock := getMockDB(t)
+	defer db.Close()
+
+	products := getExpectedProducts()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT " + variantColumns + " FROM product_variants")).
+		WillReturnError(errors.New("some error"))
+	if err := loadVariants(db, products); err == nil {
+		t.Error("expected the query error")
+	}
+
+	rows := sqlmock.NewRows([]string{"id", "product_id", "sku", "options", "price", "images", "stock"}).
+		AddRow(1, 1, "MUG", []byte("not json"), nil, []byte("{}"), 0)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT " + variantColumns + " FROM product_variants")).WillReturnRows(rows)
+	if err := loadVariants(db, products); err == nil {
+		t.Error("expected an error for invalid options")
+	}
+	checkMockExpectations(t, mock)
+}